@@ -0,0 +1,202 @@
+package main
+
+import (
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// arrayOps are the operators that compare two arrays of the same type
+var arrayOps = map[string]bool{
+	"@>": true, "<@": true, "&&": true,
+}
+
+// checkArrayOperator checks the array sides of ANY/ALL and the array
+// operators have element types that fit the other side.
+func checkArrayOperator(fn Call, scope *Scope, expr pgnodes.A_Expr) []error {
+	switch expr.Kind {
+	case pgnodes.AEXPR_OP_ANY, pgnodes.AEXPR_OP_ALL:
+		quantifier := "ANY"
+		if expr.Kind == pgnodes.AEXPR_OP_ALL {
+			quantifier = "ALL"
+		}
+		op := operatorName(expr) + " " + quantifier
+
+		right, rightCol := comparedColumn(scope, expr.Rexpr)
+		if rightCol == nil {
+			return nil
+		}
+		elem := arrayElemDBType(rightCol.DBType)
+		if len(elem) == 0 {
+			if len(dbTypeCategory(rightCol.DBType)) == 0 {
+				return nil
+			}
+			return []error{OperatorErr{
+				Operator: op,
+				Operand:  right,
+				DBType:   rightCol.DBType,
+				Location: expr.Location,
+				Fn:       fn,
+			}}
+		}
+
+		left, leftCol := comparedColumn(scope, expr.Lexpr)
+		if leftCol == nil || dbTypesCompatible(leftCol.DBType, elem) {
+			return nil
+		}
+		return []error{CompareErr{
+			Left:      left,
+			LeftType:  leftCol.DBType,
+			Right:     right,
+			RightType: rightCol.DBType,
+			Operator:  op,
+			Location:  expr.Location,
+			Fn:        fn,
+		}}
+	case pgnodes.AEXPR_OP:
+	default:
+		return nil
+	}
+
+	op := operatorName(expr)
+	if !arrayOps[op] || expr.Lexpr == nil {
+		return nil
+	}
+
+	left, leftCol := comparedColumn(scope, expr.Lexpr)
+	right, rightCol := comparedColumn(scope, expr.Rexpr)
+	if leftCol == nil {
+		return nil
+	}
+
+	// @> and <@ on things that aren't arrays are checked with the json
+	// operators, && is only for arrays, ranges and geometric types
+	if len(arrayElemDBType(leftCol.DBType)) == 0 {
+		switch dbTypeCategory(leftCol.DBType) {
+		case "", "G":
+			return nil
+		}
+		if op != "&&" {
+			return nil
+		}
+		return []error{OperatorErr{
+			Operator: op,
+			Operand:  left,
+			DBType:   leftCol.DBType,
+			Location: expr.Location,
+			Fn:       fn,
+		}}
+	}
+
+	if rightCol == nil || dbTypesCompatible(leftCol.DBType, rightCol.DBType) {
+		return nil
+	}
+	return []error{CompareErr{
+		Left:      left,
+		LeftType:  leftCol.DBType,
+		Right:     right,
+		RightType: rightCol.DBType,
+		Operator:  op,
+		Location:  expr.Location,
+		Fn:        fn,
+	}}
+}
+
+// inferArrayParams infers parameters used with the array operators to be
+// the same array type as the other side.
+func inferArrayParams(scope *Scope, expr pgnodes.A_Expr) {
+	if !arrayOps[operatorName(expr)] || expr.Lexpr == nil {
+		return
+	}
+
+	isArray := func(n pgnodes.Node) bool {
+		col := exprType(scope, n)
+		return col != nil && len(arrayElemDBType(col.DBType)) != 0
+	}
+
+	if isArray(expr.Lexpr) {
+		expectSameType(scope, expr.Lexpr, expr.Rexpr)
+	}
+	if isArray(expr.Rexpr) {
+		expectSameType(scope, expr.Rexpr, expr.Lexpr)
+	}
+}
+
+// subscriptCategories are the type categories that can't be subscripted.
+// Geometric types can be (point[0]) and user types are left to
+// subscriptable.
+var subscriptCategories = map[string]bool{
+	"N": true, "S": true, "B": true, "D": true, "T": true, "I": true, "V": true, "E": true,
+}
+
+// subscriptable checks if a db type could be subscripted, types that aren't
+// known like hstore are assumed to be.
+func subscriptable(dbType string) bool {
+	switch dbTypeCategory(dbType) {
+	case "U":
+		return dbType == "json" || dbType == "jsonb"
+	}
+
+	return !subscriptCategories[dbTypeCategory(dbType)]
+}
+
+// checkSubscript checks subscripts are only used on types that can be
+// subscripted and that array indexes are integers.
+func checkSubscript(fn Call, scope *Scope, ind pgnodes.A_Indirection) (errs []error) {
+	var indices []pgnodes.A_Indices
+	for _, i := range ind.Indirection.Items {
+		if idx, ok := i.(pgnodes.A_Indices); ok {
+			indices = append(indices, idx)
+		}
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+
+	arg := exprType(scope, ind.Arg)
+	if arg == nil {
+		return nil
+	}
+	if len(arrayElemDBType(arg.DBType)) != 0 {
+		for _, idx := range indices {
+			expectDBType(scope, idx.Lidx, "integer", "array subscript", coerceAssignment)
+			expectDBType(scope, idx.Uidx, "integer", "array subscript", coerceAssignment)
+		}
+		return nil
+	}
+	if subscriptable(arg.DBType) {
+		return nil
+	}
+
+	operand, _ := comparedColumn(scope, ind.Arg)
+	return []error{OperatorErr{
+		Operator: "[]",
+		Operand:  operand,
+		DBType:   arg.DBType,
+		Location: exprLocation(ind.Arg),
+		Fn:       fn,
+	}}
+}
+
+// assignedDBType returns the type of value an update must give a column that
+// is assigned through indirection like tags[1], it's the element type unless
+// the array is sliced. Field selection and subscripts on other types aren't
+// known.
+func assignedDBType(dbType string, indirection pgnodes.List) (string, bool) {
+	elem := arrayElemDBType(dbType)
+	if len(elem) == 0 {
+		return "", false
+	}
+
+	sliced := false
+	for _, i := range indirection.Items {
+		indices, ok := i.(pgnodes.A_Indices)
+		if !ok {
+			return "", false
+		}
+		sliced = sliced || indices.IsSlice
+	}
+
+	if sliced {
+		return dbType, true
+	}
+	return elem, true
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// CompareErr occurs when two columns are compared but postgres has no
+// operator or implicit cast that makes their types comparable.
+type CompareErr struct {
+	Left      string
+	LeftType  string
+	Right     string
+	RightType string
+
+	Operator string
+	Location int
+
+	Fn Call
+}
+
+func (c CompareErr) Error() string {
+	return fmt.Sprintf("%s:%d:%d incompatible comparison, %q has type %q but %q has type %q (operator %s at pos %d)",
+		c.Fn.Pos.Filename,
+		c.Fn.Pos.Line,
+		c.Fn.Pos.Column,
+		c.Left,
+		c.LeftType,
+		c.Right,
+		c.RightType,
+		c.Operator,
+		c.Location,
+	)
+}
+
+func (c CompareErr) call() Call {
+	return c.Fn
+}
+
+// checkComparison checks the columns on both sides of a comparison have
+// types that can be compared.
+func checkComparison(fn Call, scope *Scope, expr pgnodes.A_Expr) (errs []error) {
+	var op string
+	var rights []pgnodes.Node
+	switch expr.Kind {
+	case pgnodes.AEXPR_OP:
+		op = operatorName(expr)
+		if !comparisonOps[op] || !sameTypeOps[op] {
+			return nil
+		}
+		rights = []pgnodes.Node{expr.Rexpr}
+	case pgnodes.AEXPR_DISTINCT, pgnodes.AEXPR_NOT_DISTINCT, pgnodes.AEXPR_NULLIF:
+		op = "IS DISTINCT FROM"
+		switch expr.Kind {
+		case pgnodes.AEXPR_NOT_DISTINCT:
+			op = "IS NOT DISTINCT FROM"
+		case pgnodes.AEXPR_NULLIF:
+			op = "NULLIF"
+		}
+		rights = []pgnodes.Node{expr.Rexpr}
+	case pgnodes.AEXPR_IN:
+		op = "IN"
+		if list, ok := expr.Rexpr.(pgnodes.List); ok {
+			rights = list.Items
+		}
+	case pgnodes.AEXPR_BETWEEN, pgnodes.AEXPR_NOT_BETWEEN,
+		pgnodes.AEXPR_BETWEEN_SYM, pgnodes.AEXPR_NOT_BETWEEN_SYM:
+		op = "BETWEEN"
+		if list, ok := expr.Rexpr.(pgnodes.List); ok {
+			rights = list.Items
+		}
+	default:
+		return nil
+	}
+
+	left, leftCol := comparedColumn(scope, expr.Lexpr)
+	if leftCol == nil {
+		return nil
+	}
+
+	for _, r := range rights {
+		right, rightCol := comparedColumn(scope, r)
+		if rightCol == nil || dbTypesCompatible(leftCol.DBType, rightCol.DBType) {
+			continue
+		}
+
+		errs = append(errs, CompareErr{
+			Left:      left,
+			LeftType:  leftCol.DBType,
+			Right:     right,
+			RightType: rightCol.DBType,
+			Operator:  op,
+			Location:  expr.Location,
+			Fn:        fn,
+		})
+	}
+
+	return errs
+}
+
+// comparedColumn resolves a column reference to its column and the name
+// it was referred to by.
+func comparedColumn(scope *Scope, n pgnodes.Node) (string, *drivers.Column) {
+	colRef, ok := n.(pgnodes.ColumnRef)
+	if !ok {
+		return "", nil
+	}
+
+	schema, table, field := splitColumnRef(colRef)
+	column, ok := field.(pgnodes.String)
+	if !ok {
+		return "", nil
+	}
+
+	col, ret := scope.get(schema, table, column.Str)
+	if ret != scopeRetOk || col == nil || len(col.DBType) == 0 {
+		return "", nil
+	}
+
+	name := column.Str
+	if len(table) != 0 {
+		name = table + "." + name
+	}
+	if len(schema) != 0 {
+		name = schema + "." + name
+	}
+
+	return name, col
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"go/types"
+	"regexp"
+	"strings"
+)
+
+// goKind is what a Go value looks like to postgres when it's bound to a
+// parameter.
+type goKind int
+
+// Kinds of go values
+const (
+	// goKindAny can't be known ahead of time (interfaces, driver.Valuers)
+	goKindAny goKind = iota
+	goKindInt
+	goKindFloat
+	goKindDecimal
+	goKindString
+	goKindBool
+	goKindBytes
+	goKindTime
+	goKindJSON
+	goKindArray
+	// goKindInvalid is something the driver can't bind at all like a struct
+	goKindInvalid
+)
+
+// goValue describes a Go type in terms of the values it sends to postgres,
+// elem is the kind of the elements of arrays.
+type goValue struct {
+	kind goKind
+	elem goKind
+}
+
+// knownGoTypes are types that implement driver.Valuer but whose values we
+// know the shape of. They're keyed by their package path without major
+// version elements.
+var knownGoTypes = map[string]goValue{
+	"time.Time":                {kind: goKindTime},
+	"encoding/json.RawMessage": {kind: goKindJSON},
+
+	"database/sql.NullString":  {kind: goKindString},
+	"database/sql.NullInt64":   {kind: goKindInt},
+	"database/sql.NullInt32":   {kind: goKindInt},
+	"database/sql.NullInt16":   {kind: goKindInt},
+	"database/sql.NullByte":    {kind: goKindInt},
+	"database/sql.NullFloat64": {kind: goKindFloat},
+	"database/sql.NullBool":    {kind: goKindBool},
+	"database/sql.NullTime":    {kind: goKindTime},
+
+	"github.com/volatiletech/null.String":  {kind: goKindString},
+	"github.com/volatiletech/null.Byte":    {kind: goKindString},
+	"github.com/volatiletech/null.Bytes":   {kind: goKindBytes},
+	"github.com/volatiletech/null.JSON":    {kind: goKindJSON},
+	"github.com/volatiletech/null.Bool":    {kind: goKindBool},
+	"github.com/volatiletech/null.Time":    {kind: goKindTime},
+	"github.com/volatiletech/null.Float32": {kind: goKindFloat},
+	"github.com/volatiletech/null.Float64": {kind: goKindFloat},
+	"github.com/volatiletech/null.Int":     {kind: goKindInt},
+	"github.com/volatiletech/null.Int8":    {kind: goKindInt},
+	"github.com/volatiletech/null.Int16":   {kind: goKindInt},
+	"github.com/volatiletech/null.Int32":   {kind: goKindInt},
+	"github.com/volatiletech/null.Int64":   {kind: goKindInt},
+	"github.com/volatiletech/null.Uint":    {kind: goKindInt},
+	"github.com/volatiletech/null.Uint8":   {kind: goKindInt},
+	"github.com/volatiletech/null.Uint16":  {kind: goKindInt},
+	"github.com/volatiletech/null.Uint32":  {kind: goKindInt},
+	"github.com/volatiletech/null.Uint64":  {kind: goKindInt},
+
+	"github.com/volatiletech/sqlboiler/types.Decimal":      {kind: goKindDecimal},
+	"github.com/volatiletech/sqlboiler/types.NullDecimal":  {kind: goKindDecimal},
+	"github.com/volatiletech/sqlboiler/types.JSON":         {kind: goKindJSON},
+	"github.com/volatiletech/sqlboiler/types.Byte":         {kind: goKindString},
+	"github.com/volatiletech/sqlboiler/types.Int64Array":   {kind: goKindArray, elem: goKindInt},
+	"github.com/volatiletech/sqlboiler/types.Float64Array": {kind: goKindArray, elem: goKindFloat},
+	"github.com/volatiletech/sqlboiler/types.DecimalArray": {kind: goKindArray, elem: goKindDecimal},
+	"github.com/volatiletech/sqlboiler/types.BoolArray":    {kind: goKindArray, elem: goKindBool},
+	"github.com/volatiletech/sqlboiler/types.BytesArray":   {kind: goKindArray, elem: goKindBytes},
+	"github.com/volatiletech/sqlboiler/types.StringArray":  {kind: goKindArray, elem: goKindAny},
+	"github.com/volatiletech/sqlboiler/types.GenericArray": {kind: goKindArray, elem: goKindAny},
+
+	"github.com/lib/pq.Int64Array":   {kind: goKindArray, elem: goKindInt},
+	"github.com/lib/pq.Float64Array": {kind: goKindArray, elem: goKindFloat},
+	"github.com/lib/pq.BoolArray":    {kind: goKindArray, elem: goKindBool},
+	"github.com/lib/pq.ByteaArray":   {kind: goKindArray, elem: goKindBytes},
+	"github.com/lib/pq.StringArray":  {kind: goKindArray, elem: goKindAny},
+	"github.com/lib/pq.GenericArray": {kind: goKindArray, elem: goKindAny},
+	"github.com/lib/pq.NullTime":     {kind: goKindTime},
+}
+
+var rgxMajorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// unversionedPath removes major version elements from a package path so
+// github.com/volatiletech/null/v8 becomes github.com/volatiletech/null
+func unversionedPath(pkgPath string) string {
+	elems := strings.Split(pkgPath, "/")
+	kept := elems[:0]
+	for _, e := range elems {
+		if !rgxMajorVersion.MatchString(e) {
+			kept = append(kept, e)
+		}
+	}
+
+	return strings.Join(kept, "/")
+}
+
+// classifyGoType finds out what kind of value a Go type will send to
+// postgres. Pointers are treated like the type they point to since a nil
+// pointer is simply a NULL.
+func classifyGoType(t types.Type) goValue {
+	switch typ := t.(type) {
+	case *types.Pointer:
+		return classifyGoType(typ.Elem())
+	case *types.Named:
+		obj := typ.Obj()
+		if obj.Pkg() != nil {
+			if v, ok := knownGoTypes[unversionedPath(obj.Pkg().Path())+"."+obj.Name()]; ok {
+				return v
+			}
+		}
+
+		if implementsValuer(typ) {
+			return goValue{kind: goKindAny}
+		}
+
+		return classifyGoType(typ.Underlying())
+	case *types.Basic:
+		info := typ.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return goValue{kind: goKindBool}
+		case info&types.IsInteger != 0:
+			return goValue{kind: goKindInt}
+		case info&types.IsFloat != 0:
+			return goValue{kind: goKindFloat}
+		case info&types.IsString != 0:
+			return goValue{kind: goKindString}
+		case typ.Kind() == types.UntypedNil:
+			return goValue{kind: goKindAny}
+		}
+	case *types.Slice:
+		if basic, ok := typ.Elem().(*types.Basic); ok && basic.Kind() == types.Byte {
+			return goValue{kind: goKindBytes}
+		}
+	case *types.Interface:
+		return goValue{kind: goKindAny}
+	}
+
+	return goValue{kind: goKindInvalid}
+}
+
+// implementsValuer checks if a type or a pointer to it has a
+// Value() (driver.Value, error) method.
+func implementsValuer(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, "Value")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 2 {
+		return false
+	}
+
+	return types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// goValueCompatible checks if a Go value can be bound to a parameter of the
+// db type.
+func goValueCompatible(v goValue, dbType string) bool {
+	cat := dbTypeCategory(dbType)
+	if v.kind == goKindAny || len(cat) == 0 {
+		return true
+	}
+
+	switch v.kind {
+	case goKindInt, goKindFloat, goKindDecimal:
+		if cat != "N" {
+			return false
+		}
+
+		// Postgres refuses fractional values for integer types
+		switch dbType {
+		case "smallint", "integer", "bigint":
+			return v.kind == goKindInt
+		}
+		return true
+	case goKindString:
+		// Strings are sent as text which postgres parses into most types,
+		// times are often formatted with time.Format but a string for a
+		// number, boolean or array is most likely a mistake.
+		switch cat {
+		case "N", "B", "A":
+			return false
+		}
+		return true
+	case goKindBool:
+		return cat == "B"
+	case goKindBytes:
+		// Text is sent as a string, a []byte for it is usually a json
+		// document that should have been compared as json
+		return dbType == "bytea" || dbType == "json" || dbType == "jsonb"
+	case goKindTime:
+		return cat == "D"
+	case goKindJSON:
+		return dbType == "json" || dbType == "jsonb"
+	case goKindArray:
+		if cat != "A" {
+			return false
+		}
+		return goValueCompatible(goValue{kind: v.elem}, arrayElemDBType(dbType))
+	}
+
+	return false
+}
+
+// parseGoType creates a type from a name like types.Type.String() gives.
+// It's used when a call has no type information, named types outside the
+// standard library are given an interface as their underlying type so
+// nothing is assumed about them.
+func parseGoType(name string) types.Type {
+	switch {
+	case strings.HasPrefix(name, "*"):
+		return types.NewPointer(parseGoType(name[1:]))
+	case strings.HasPrefix(name, "[]"):
+		return types.NewSlice(parseGoType(name[2:]))
+	case strings.HasPrefix(name, "map["), strings.HasPrefix(name, "struct{"),
+		strings.HasPrefix(name, "func("), strings.HasPrefix(name, "chan "):
+		return types.NewStruct(nil, nil)
+	case strings.HasPrefix(name, "interface{"):
+		return types.NewInterfaceType(nil, nil).Complete()
+	}
+
+	if obj := types.Universe.Lookup(name); obj != nil {
+		return obj.Type()
+	}
+
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 0 {
+		return types.NewInterfaceType(nil, nil).Complete()
+	}
+
+	pkgPath := name[:dot]
+	pkg := types.NewPackage(pkgPath, pkgPath[strings.LastIndexByte(pkgPath, '/')+1:])
+	obj := types.NewTypeName(0, pkg, name[dot+1:], nil)
+	return types.NewNamed(obj, types.NewInterfaceType(nil, nil).Complete(), nil)
+}
+
+// argGoType returns the type of a call's argument
+func argGoType(fn Call, index int) types.Type {
+	if index < len(fn.Args) && fn.Args[index] != nil {
+		return fn.Args[index]
+	}
+
+	return parseGoType(fn.ArgTypes[index])
+}
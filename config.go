@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/BurntSushi/toml"
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+	"github.com/volatiletech/sqlboiler/v4/importers"
+)
+
+// boilcheckConfig is what we read from the config file on top of the driver
+// configuration.
+type boilcheckConfig struct {
+	Boilcheck struct {
+		// Types maps db types (integer) or columns (table.column) to the Go
+		// types that may be used for them, eg:
+		//   [boilcheck.types]
+		//   "users.id" = ["github.com/me/app/ids.UserID"]
+		Types map[string][]string `toml:"types"`
+
+		// Migrations are directories of .sql files to read user defined
+		// functions from
+		Migrations []string `toml:"migrations"`
+		// Functions are the names of functions that exist but that can't
+		// be found otherwise, like those from extensions
+		Functions []string `toml:"functions"`
+		// SearchPath is the schemas unqualified table names are looked up
+		// in, it defaults to the schema in the psql config
+		SearchPath []string `toml:"search_path"`
+		// Nullability is how strictly NOT NULL columns are checked: off,
+		// default or strict which also reports Go types that can be nil
+		// being inserted or updated into them
+		Nullability string `toml:"nullability"`
+		// Immutable are columns that are never updated once inserted like
+		// created_at, as table.column or schema.table.column
+		Immutable []string `toml:"immutable"`
+	} `toml:"boilcheck"`
+
+	// Types are sqlboiler's own [[types]] replacements
+	Types []typeReplace `toml:"types"`
+}
+
+// typeReplace is an sqlboiler type replacement, the Go type in Replace is
+// used for all columns that match Match in the generated models.
+type typeReplace struct {
+	Match   drivers.Column `toml:"match"`
+	Replace drivers.Column `toml:"replace"`
+	Imports importers.Set  `toml:"imports"`
+}
+
+func loadBoilcheckConfig(filename string) (cfg boilcheckConfig, err error) {
+	_, err = toml.DecodeFile(filename, &cfg)
+	return cfg, err
+}
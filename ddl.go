@@ -0,0 +1,355 @@
+package main
+
+import (
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgquery "github.com/lfittl/pg_query_go"
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// withTables copies the state so that the tables it knows about can be
+// changed by DDL without affecting anything else that uses it.
+func (s *State) withTables() *State {
+	cloned := *s
+
+	info := *s.DBInfo
+	info.Tables = make([]drivers.Table, len(s.DBInfo.Tables))
+	copy(info.Tables, s.DBInfo.Tables)
+	cloned.DBInfo = &info
+
+	cloned.Relations = make(map[string]relation, len(s.Relations))
+	for k, v := range s.Relations {
+		cloned.Relations[k] = v
+	}
+
+	return &cloned
+}
+
+// changesTables checks if any statement in the tree is DDL that changes
+// the tables later statements see.
+func changesTables(tree pgquery.ParsetreeList) bool {
+	for _, stmt := range tree.Statements {
+		if raw, ok := stmt.(pgnodes.RawStmt); ok {
+			stmt = raw.Stmt
+		}
+
+		switch s := stmt.(type) {
+		case pgnodes.CreateStmt, pgnodes.CreateTableAsStmt, pgnodes.AlterTableStmt,
+			pgnodes.DropStmt, pgnodes.RenameStmt:
+			return true
+		case pgnodes.SelectStmt:
+			if s.IntoClause != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// createSchema returns the schema a table is created in, temporary tables
+// go in their own schema and the rest in the first one on the search path.
+func createSchema(scope *Scope, rv pgnodes.RangeVar) string {
+	switch {
+	case rv.Schemaname != nil:
+		return *rv.Schemaname
+	case rv.Relpersistence == 't':
+		return "pg_temp"
+	default:
+		return scope.schemas()[0]
+	}
+}
+
+// createTable adds a table to the state's database info. It's an error for
+// one by the same name to already exist in the schema unless the statement
+// said IF NOT EXISTS, in which case the existing table is kept.
+func createTable(state *State, fn Call, scope *Scope, rv pgnodes.RangeVar, kind int, columns []drivers.Column, pkey *drivers.PrimaryKey, ifNotExists bool) []error {
+	schema := createSchema(scope, rv)
+	for i := range state.DBInfo.Tables {
+		if t := &state.DBInfo.Tables[i]; t.Name == *rv.Relname && scope.schemaOf(t) == schema {
+			if ifNotExists {
+				return nil
+			}
+
+			var errSchema string
+			if rv.Schemaname != nil {
+				errSchema = *rv.Schemaname
+			}
+			return []error{IdentErr{
+				Kind:     Exists,
+				Schema:   errSchema,
+				Table:    *rv.Relname,
+				Location: rv.Location,
+				Fn:       fn,
+			}}
+		}
+	}
+
+	state.DBInfo.Tables = append(state.DBInfo.Tables, drivers.Table{
+		SchemaName: schema,
+		Name:       *rv.Relname,
+		Columns:    columns,
+		PKey:       pkey,
+	})
+
+	key := schema + "." + *rv.Relname
+	delete(state.Relations, key)
+	if kind != relTable {
+		state.Relations[key] = relation{kind: kind}
+	}
+
+	return nil
+}
+
+// checkCreateTableAs checks the query of a CREATE TABLE AS or SELECT INTO
+// and creates a table from its output.
+func checkCreateTableAs(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt, into pgnodes.IntoClause, kind int, ifNotExists bool) []error {
+	sel.IntoClause = nil
+	refs, errs := checkSelect(state, fn, scope, sel)
+	if len(into.ColNames.Items) != 0 {
+		refs = renameOutputCols(refs, into.ColNames)
+	}
+
+	columns := outputColsToPseudoTable(*into.Rel.Relname, refs).Columns
+	return append(errs, createTable(state, fn, scope, *into.Rel, kind, columns, nil, ifNotExists)...)
+}
+
+// alteredTable finds the table a DDL statement changes, it returns -1 when
+// it isn't one in the database info like the system catalog's tables.
+func alteredTable(fn Call, scope *Scope, rv pgnodes.RangeVar, missingOk bool) (int, []error) {
+	var schema string
+	if rv.Schemaname != nil {
+		schema = *rv.Schemaname
+	}
+
+	if !scope.pushTable(schema, *rv.Relname, "") {
+		if missingOk {
+			return -1, nil
+		}
+		return -1, []error{IdentErr{
+			Schema:   schema,
+			Table:    *rv.Relname,
+			Location: rv.Location,
+			Fn:       fn,
+		}}
+	}
+	t := scope.tables[len(scope.tables)-1]
+	scope.popTable()
+
+	for i := range scope.info.Tables {
+		if &scope.info.Tables[i] == t {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// columnIndex finds a column of a table, -1 if it doesn't have it
+func columnIndex(t *drivers.Table, name string) int {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// checkAlterTable applies the column changes of an ALTER TABLE
+func checkAlterTable(state *State, fn Call, scope *Scope, alter pgnodes.AlterTableStmt) (errs []error) {
+	index, errs := alteredTable(fn, scope, *alter.Relation, alter.MissingOk)
+	if index < 0 {
+		return errs
+	}
+
+	t := &state.DBInfo.Tables[index]
+	schema := scope.schemaOf(t)
+
+	// Other states may share the columns so they're copied before they're
+	// changed
+	t.Columns = append([]drivers.Column(nil), t.Columns...)
+
+	for _, item := range alter.Cmds.Items {
+		cmd, ok := item.(pgnodes.AlterTableCmd)
+		if !ok {
+			continue
+		}
+
+		if cmd.Subtype == pgnodes.AT_AddColumn {
+			added := createdColumns(pgnodes.List{Items: []pgnodes.Node{cmd.Def}})
+			for _, c := range added {
+				if columnIndex(t, c.Name) < 0 {
+					t.Columns = append(t.Columns, c)
+				}
+			}
+			continue
+		}
+
+		if cmd.Name == nil {
+			continue
+		}
+
+		col := columnIndex(t, *cmd.Name)
+		switch cmd.Subtype {
+		case pgnodes.AT_DropColumn, pgnodes.AT_AlterColumnType,
+			pgnodes.AT_SetNotNull, pgnodes.AT_DropNotNull, pgnodes.AT_ColumnDefault:
+		default:
+			continue
+		}
+
+		if col < 0 {
+			if !cmd.MissingOk {
+				errs = append(errs, IdentErr{
+					Schema:   schema,
+					Table:    t.Name,
+					Column:   *cmd.Name,
+					Location: alter.Relation.Location,
+					Fn:       fn,
+				})
+			}
+			continue
+		}
+
+		c := &t.Columns[col]
+		switch cmd.Subtype {
+		case pgnodes.AT_DropColumn:
+			t.Columns = append(t.Columns[:col], t.Columns[col+1:]...)
+			// The primary key goes with any of its columns
+			for i := 0; t.PKey != nil && i < len(t.PKey.Columns); i++ {
+				if t.PKey.Columns[i] == *cmd.Name {
+					t.PKey = nil
+				}
+			}
+		case pgnodes.AT_AlterColumnType:
+			if def, ok := cmd.Def.(pgnodes.ColumnDef); ok && def.TypeName != nil {
+				c.DBType = typeNameDBType(*def.TypeName)
+				c.Type = translateDBType(c.DBType, c.Nullable)
+			}
+		case pgnodes.AT_SetNotNull, pgnodes.AT_DropNotNull:
+			c.Nullable = cmd.Subtype == pgnodes.AT_DropNotNull
+			c.Type = translateDBType(c.DBType, c.Nullable)
+		case pgnodes.AT_ColumnDefault:
+			// DROP DEFAULT has no expression
+			c.Default = ""
+			if cmd.Def != nil {
+				c.Default = columnDefault
+			}
+		}
+	}
+
+	return errs
+}
+
+// checkRename applies ALTER TABLE ... RENAME for tables and their columns
+func checkRename(state *State, fn Call, scope *Scope, rename pgnodes.RenameStmt) []error {
+	if rename.Relation == nil || rename.Newname == nil {
+		return nil
+	}
+
+	switch {
+	case rename.RenameType == pgnodes.OBJECT_TABLE:
+	case rename.RenameType == pgnodes.OBJECT_COLUMN && rename.RelationType == pgnodes.OBJECT_TABLE:
+	default:
+		return nil
+	}
+
+	index, errs := alteredTable(fn, scope, *rename.Relation, rename.MissingOk)
+	if index < 0 {
+		return errs
+	}
+
+	t := &state.DBInfo.Tables[index]
+	schema := scope.schemaOf(t)
+
+	if rename.RenameType == pgnodes.OBJECT_TABLE {
+		if rel, ok := state.Relations[schema+"."+t.Name]; ok {
+			delete(state.Relations, schema+"."+t.Name)
+			state.Relations[schema+"."+*rename.Newname] = rel
+		}
+		t.Name = *rename.Newname
+		return nil
+	}
+
+	col := columnIndex(t, *rename.Subname)
+	if col < 0 {
+		return []error{IdentErr{
+			Schema:   schema,
+			Table:    t.Name,
+			Column:   *rename.Subname,
+			Location: rename.Relation.Location,
+			Fn:       fn,
+		}}
+	}
+
+	t.Columns = append([]drivers.Column(nil), t.Columns...)
+	t.Columns[col].Name = *rename.Newname
+
+	if t.PKey != nil {
+		pkey := &drivers.PrimaryKey{Name: t.PKey.Name}
+		for _, k := range t.PKey.Columns {
+			if k == *rename.Subname {
+				k = *rename.Newname
+			}
+			pkey.Columns = append(pkey.Columns, k)
+		}
+		t.PKey = pkey
+	}
+	return nil
+}
+
+// checkDrop removes the tables and views a DROP statement drops
+func checkDrop(state *State, fn Call, scope *Scope, drop pgnodes.DropStmt) (errs []error) {
+	switch drop.RemoveType {
+	case pgnodes.OBJECT_TABLE, pgnodes.OBJECT_VIEW, pgnodes.OBJECT_MATVIEW, pgnodes.OBJECT_FOREIGN_TABLE:
+	default:
+		return nil
+	}
+
+	for _, obj := range drop.Objects.Items {
+		names, ok := obj.(pgnodes.List)
+		if !ok {
+			continue
+		}
+		rv, ok := namesRangeVar(names)
+		if !ok {
+			continue
+		}
+
+		index, errList := alteredTable(fn, scope, rv, drop.MissingOk)
+		errs = append(errs, errList...)
+		if index < 0 {
+			continue
+		}
+
+		t := state.DBInfo.Tables[index]
+		delete(state.Relations, scope.schemaOf(&state.DBInfo.Tables[index])+"."+t.Name)
+		state.DBInfo.Tables = append(state.DBInfo.Tables[:index], state.DBInfo.Tables[index+1:]...)
+	}
+
+	return errs
+}
+
+// namesRangeVar turns the qualified name a DROP gives into a RangeVar, ok
+// is false if it isn't made of strings
+func namesRangeVar(names pgnodes.List) (pgnodes.RangeVar, bool) {
+	rv := pgnodes.RangeVar{}
+	if len(names.Items) == 0 {
+		return rv, false
+	}
+
+	name, ok := names.Items[len(names.Items)-1].(pgnodes.String)
+	if !ok {
+		return rv, false
+	}
+	rv.Relname = &name.Str
+	if len(names.Items) > 1 {
+		schema, ok := names.Items[len(names.Items)-2].(pgnodes.String)
+		if !ok {
+			return rv, false
+		}
+		rv.Schemaname = &schema.Str
+	}
+
+	return rv, true
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"go/constant"
+	"strings"
+
+	"github.com/volatiletech/strmangle"
+)
+
+// EnumErr occurs when a value that isn't one of an enum's values is used
+// for a column of that enum type. The value comes from a string literal in
+// the statement or from a Go constant given as a parameter's argument.
+type EnumErr struct {
+	Schema string
+	Table  string
+	Column string
+
+	Enum   string
+	Values []string
+	Value  string
+
+	// Parameter is the parameter the Go constant was given for or 0 for
+	// string literals
+	Parameter int
+	Location  int
+
+	Fn Call
+}
+
+func (e EnumErr) Error() string {
+	ident := errIdent(e.Schema, e.Table, e.Column)
+
+	value := fmt.Sprintf("literal %q (pos %d)", e.Value, e.Location)
+	if e.Parameter != 0 {
+		value = fmt.Sprintf("constant %q given for parameter $%d (pos %d)", e.Value, e.Parameter, e.Location)
+	}
+
+	return fmt.Sprintf("%s:%d:%d invalid enum value, %q has type %q which does not allow the %s (values: %s)",
+		e.Fn.Pos.Filename,
+		e.Fn.Pos.Line,
+		e.Fn.Pos.Column,
+		ident,
+		e.Enum,
+		value,
+		strings.Join(e.Values, ", "),
+	)
+}
+
+func (e EnumErr) call() Call {
+	return e.Fn
+}
+
+// enumValues returns the name and values of an enum db type, ok is false
+// when the type is not an enum.
+func enumValues(dbType string) (name string, values []string, ok bool) {
+	if !strings.HasPrefix(dbType, "enum.") {
+		return "", nil, false
+	}
+
+	return strmangle.ParseEnumName(dbType), strmangle.ParseEnumVals(dbType), true
+}
+
+// enumAllows checks if the value is one of the enum's values. Types that
+// aren't enums allow everything.
+func enumAllows(dbType, value string) bool {
+	_, values, ok := enumValues(dbType)
+	if !ok {
+		return true
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enumCheck checks the Go argument for a parameter when it's a string
+// constant used for an enum column. sqlboiler generates constants for each
+// enum value so this catches values that were removed from the database.
+func enumCheck(fn Call, number int, use paramUse) error {
+	if number-1 >= len(fn.Values) {
+		return nil
+	}
+
+	val := fn.Values[number-1]
+	if val == nil || val.Kind() != constant.String {
+		return nil
+	}
+
+	str := constant.StringVal(val)
+	if enumAllows(use.col.DBType, str) {
+		return nil
+	}
+
+	name, values, _ := enumValues(use.col.DBType)
+	return EnumErr{
+		Schema:    use.schema,
+		Table:     use.table,
+		Column:    use.column,
+		Enum:      name,
+		Values:    values,
+		Value:     str,
+		Parameter: number,
+		Location:  use.location,
+		Fn:        fn,
+	}
+}
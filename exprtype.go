@@ -0,0 +1,445 @@
+package main
+
+import (
+	"math"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// exprType determines the column information for the result of an
+// expression. It returns nil when the type can't be determined.
+func exprType(scope *Scope, n pgnodes.Node) *drivers.Column {
+	switch node := n.(type) {
+	case pgnodes.ColumnRef:
+		schema, table, field := splitColumnRef(node)
+		name, ok := field.(pgnodes.String)
+		if !ok {
+			return nil
+		}
+
+		col, ret := scope.get(schema, table, name.Str)
+		if ret != scopeRetOk {
+			return nil
+		}
+		return scope.outputColumn(schema, table, name.Str, col)
+	case pgnodes.TypeCast:
+		nullable := true
+		if arg := exprType(scope, node.Arg); arg != nil {
+			nullable = arg.Nullable
+		}
+
+		return pseudoColumn("", typeNameDBType(*node.TypeName), nullable)
+	case pgnodes.A_Const:
+		switch val := node.Val.(type) {
+		case pgnodes.Integer:
+			if val.Ival > math.MaxInt32 || val.Ival < math.MinInt32 {
+				return pseudoColumn("", "bigint", false)
+			}
+			return pseudoColumn("", "integer", false)
+		case pgnodes.Float:
+			return pseudoColumn("", "numeric", false)
+		case pgnodes.String:
+			// String literals take their type from where they're used
+			return pseudoColumn("", "unknown", false)
+		}
+	case pgnodes.A_Expr:
+		return operatorType(scope, node)
+	case pgnodes.BoolExpr:
+		return pseudoColumn("", "boolean", anyNullable(scope, node.Args.Items...))
+	case pgnodes.NullTest, pgnodes.BooleanTest:
+		return pseudoColumn("", "boolean", false)
+	case pgnodes.FuncCall:
+		return funcCallType(scope, node)
+	case pgnodes.SQLValueFunction:
+		switch node.Op {
+		case pgnodes.SVFOP_CURRENT_DATE:
+			return pseudoColumn("", "date", false)
+		case pgnodes.SVFOP_CURRENT_TIME, pgnodes.SVFOP_CURRENT_TIME_N:
+			return pseudoColumn("", "time with time zone", false)
+		case pgnodes.SVFOP_CURRENT_TIMESTAMP, pgnodes.SVFOP_CURRENT_TIMESTAMP_N:
+			return pseudoColumn("", "timestamp with time zone", false)
+		case pgnodes.SVFOP_LOCALTIME, pgnodes.SVFOP_LOCALTIME_N:
+			return pseudoColumn("", "time without time zone", false)
+		case pgnodes.SVFOP_LOCALTIMESTAMP, pgnodes.SVFOP_LOCALTIMESTAMP_N:
+			return pseudoColumn("", "timestamp without time zone", false)
+		case pgnodes.SVFOP_CURRENT_SCHEMA:
+			return pseudoColumn("", "name", true)
+		default:
+			return pseudoColumn("", "name", false)
+		}
+	case pgnodes.CoalesceExpr:
+		return firstKnownType(scope, node.Args.Items, allNullable(scope, node.Args.Items...))
+	case pgnodes.MinMaxExpr:
+		return firstKnownType(scope, node.Args.Items, allNullable(scope, node.Args.Items...))
+	case pgnodes.CaseExpr:
+		var results []pgnodes.Node
+		for _, w := range node.Args.Items {
+			results = append(results, w.(pgnodes.CaseWhen).Result)
+		}
+		if node.Defresult != nil {
+			results = append(results, node.Defresult)
+		}
+
+		// Without an else the result can be null
+		nullable := node.Defresult == nil || anyNullable(scope, results...)
+		return firstKnownType(scope, results, nullable)
+	case pgnodes.A_ArrayExpr:
+		elem := firstKnownType(scope, node.Elements.Items, false)
+		if elem == nil {
+			return nil
+		}
+		return pseudoColumn("", "ARRAY"+elem.DBType, false)
+	case pgnodes.A_Indirection:
+		arg := exprType(scope, node.Arg)
+		if arg == nil {
+			return nil
+		}
+		elem := arrayElemDBType(arg.DBType)
+		if len(elem) == 0 {
+			return nil
+		}
+
+		for _, i := range node.Indirection.Items {
+			indices, ok := i.(pgnodes.A_Indices)
+			if !ok {
+				return nil
+			}
+			if indices.IsSlice {
+				return pseudoColumn("", arg.DBType, true)
+			}
+		}
+
+		// Subscripts out of range give null
+		return pseudoColumn("", elem, true)
+	case pgnodes.CollateClause:
+		return exprType(scope, node.Arg)
+	case pgnodes.SubLink:
+		if node.SubLinkType == pgnodes.EXISTS_SUBLINK {
+			return pseudoColumn("", "boolean", false)
+		}
+	}
+
+	return nil
+}
+
+// comparisonOps are the operators that result in a boolean
+var comparisonOps = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"~": true, "~*": true, "!~": true, "!~*": true,
+	"~~": true, "~~*": true, "!~~": true, "!~~*": true,
+	"@>": true, "<@": true, "&&": true, "@@": true,
+	"?": true, "?|": true, "?&": true,
+}
+
+// operatorType determines the result type of an operator expression
+func operatorType(scope *Scope, expr pgnodes.A_Expr) *drivers.Column {
+	switch expr.Kind {
+	case pgnodes.AEXPR_DISTINCT, pgnodes.AEXPR_NOT_DISTINCT:
+		return pseudoColumn("", "boolean", false)
+	case pgnodes.AEXPR_OP_ANY, pgnodes.AEXPR_OP_ALL, pgnodes.AEXPR_IN,
+		pgnodes.AEXPR_LIKE, pgnodes.AEXPR_ILIKE, pgnodes.AEXPR_SIMILAR,
+		pgnodes.AEXPR_BETWEEN, pgnodes.AEXPR_NOT_BETWEEN,
+		pgnodes.AEXPR_BETWEEN_SYM, pgnodes.AEXPR_NOT_BETWEEN_SYM:
+		return pseudoColumn("", "boolean", anyNullable(scope, expr.Lexpr, expr.Rexpr))
+	case pgnodes.AEXPR_NULLIF:
+		left := exprType(scope, expr.Lexpr)
+		if left == nil {
+			return nil
+		}
+		return pseudoColumn("", left.DBType, true)
+	case pgnodes.AEXPR_OP:
+	default:
+		return nil
+	}
+
+	op := operatorName(expr)
+	if comparisonOps[op] {
+		return pseudoColumn("", "boolean", anyNullable(scope, expr.Lexpr, expr.Rexpr))
+	}
+
+	right := exprType(scope, expr.Rexpr)
+	if expr.Lexpr == nil {
+		// Prefix operators like - keep the type of their operand
+		if right == nil || op != "-" {
+			return nil
+		}
+		return pseudoColumn("", right.DBType, right.Nullable)
+	}
+
+	left := exprType(scope, expr.Lexpr)
+	if left == nil || right == nil {
+		return nil
+	}
+	nullable := left.Nullable || right.Nullable
+
+	// A string literal is taken to be the type of the other side, or an
+	// interval when there's no arithmetic between two of those
+	unknownLeft := left.DBType == "unknown" && right.DBType != "unknown"
+	unknownRight := right.DBType == "unknown" && left.DBType != "unknown"
+	switch {
+	case unknownLeft:
+		left = pseudoColumn("", right.DBType, left.Nullable)
+	case unknownRight:
+		right = pseudoColumn("", left.DBType, right.Nullable)
+	}
+
+	var dbType string
+	switch op {
+	case "||":
+		switch {
+		case len(arrayElemDBType(left.DBType)) != 0:
+			dbType = left.DBType
+		case len(arrayElemDBType(right.DBType)) != 0:
+			dbType = right.DBType
+		case left.DBType == "jsonb":
+			dbType = "jsonb"
+		default:
+			dbType = "text"
+		}
+	case "->", "#>":
+		// Missing keys give null
+		return pseudoColumn("", left.DBType, true)
+	case "#-":
+		dbType = left.DBType
+	case "->>", "#>>":
+		return pseudoColumn("", "text", true)
+	case "+", "-", "*", "/", "%", "^":
+		dbType = arithmeticType(op, left.DBType, right.DBType)
+		switch {
+		case len(dbType) != 0:
+		case unknownLeft:
+			dbType = arithmeticType(op, "interval", right.DBType)
+		case unknownRight:
+			dbType = arithmeticType(op, left.DBType, "interval")
+		}
+	}
+
+	if len(dbType) == 0 {
+		return nil
+	}
+	return pseudoColumn("", dbType, nullable)
+}
+
+// operatorName returns the unqualified name of an operator
+func operatorName(expr pgnodes.A_Expr) string {
+	if len(expr.Name.Items) == 0 {
+		return ""
+	}
+
+	name, _ := expr.Name.Items[len(expr.Name.Items)-1].(pgnodes.String)
+	return name.Str
+}
+
+// numericRank orders the numeric types so that the result of arithmetic
+// between two of them is the one with the highest rank. Other types are 0.
+func numericRank(dbType string) int {
+	switch dbType {
+	case "smallint":
+		return 1
+	case "integer":
+		return 2
+	case "bigint":
+		return 3
+	case "numeric":
+		return 4
+	case "real":
+		return 5
+	case "double precision":
+		return 6
+	}
+
+	return 0
+}
+
+// arithmeticType returns the type of an arithmetic operator's result, or the
+// empty string if it's not known.
+func arithmeticType(op, left, right string) string {
+	lrank, rrank := numericRank(left), numericRank(right)
+	switch {
+	case lrank != 0 && rrank != 0:
+		if lrank > rrank {
+			return left
+		}
+		return right
+	case op == "^":
+		return ""
+	}
+
+	isTimestamp := func(t string) bool {
+		return t == "timestamp with time zone" || t == "timestamp without time zone"
+	}
+
+	switch {
+	case left == "date" && right == "date" && op == "-":
+		return "integer"
+	case left == "date" && rrank != 0 && (op == "+" || op == "-"):
+		return "date"
+	case left == "date" && right == "interval" && (op == "+" || op == "-"):
+		return "timestamp without time zone"
+	case isTimestamp(left) && isTimestamp(right) && op == "-":
+		return "interval"
+	case isTimestamp(left) && right == "interval" && (op == "+" || op == "-"):
+		return left
+	case left == "interval" && isTimestamp(right) && op == "+":
+		return right
+	case left == "interval" && right == "interval" && (op == "+" || op == "-"):
+		return "interval"
+	case left == "interval" && rrank != 0 && (op == "*" || op == "/"):
+		return "interval"
+	case lrank != 0 && right == "interval" && op == "*":
+		return "interval"
+	}
+
+	return ""
+}
+
+// firstKnownType returns the type of the first expression whose type is
+// known, which is how postgres resolves the type of things like coalesce.
+// String literals are text when nothing else decides their type.
+func firstKnownType(scope *Scope, exprs []pgnodes.Node, nullable bool) *drivers.Column {
+	unknown := false
+	for _, e := range exprs {
+		col := exprType(scope, e)
+		switch {
+		case col == nil || len(col.DBType) == 0:
+		case col.DBType == "unknown":
+			unknown = true
+		default:
+			return pseudoColumn("", col.DBType, nullable)
+		}
+	}
+
+	if unknown {
+		return pseudoColumn("", "text", nullable)
+	}
+	return nil
+}
+
+// resolveUnknown gives string literals nothing else decided the type of
+// the type postgres falls back to, text.
+func resolveUnknown(col *drivers.Column) *drivers.Column {
+	if col == nil || col.DBType != "unknown" {
+		return col
+	}
+
+	return pseudoColumn(col.Name, "text", col.Nullable)
+}
+
+// anyNullable checks if any of the expressions could be null, expressions
+// with unknown types are assumed to be.
+func anyNullable(scope *Scope, exprs ...pgnodes.Node) bool {
+	for _, e := range exprs {
+		if e == nil {
+			continue
+		}
+		if col := exprType(scope, e); col == nil || col.Nullable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allNullable checks if all of the expressions could be null, expressions
+// with unknown types are assumed to be.
+func allNullable(scope *Scope, exprs ...pgnodes.Node) bool {
+	for _, e := range exprs {
+		if col := exprType(scope, e); col != nil && !col.Nullable {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exprColName figures out the name postgres gives to the column of an
+// expression in a select list that has no alias.
+func exprColName(n pgnodes.Node) string {
+	switch node := n.(type) {
+	case pgnodes.ColumnRef:
+		if str, ok := node.Fields.Items[len(node.Fields.Items)-1].(pgnodes.String); ok {
+			return str.Str
+		}
+	case pgnodes.A_Indirection:
+		for i := len(node.Indirection.Items) - 1; i >= 0; i-- {
+			if str, ok := node.Indirection.Items[i].(pgnodes.String); ok {
+				return str.Str
+			}
+		}
+		return exprColName(node.Arg)
+	case pgnodes.FuncCall:
+		return funcCallName(node)
+	case pgnodes.A_Expr:
+		if node.Kind == pgnodes.AEXPR_NULLIF {
+			return "nullif"
+		}
+	case pgnodes.TypeCast:
+		if name := exprColName(node.Arg); name != "?column?" {
+			return name
+		}
+		if names := node.TypeName.Names.Items; len(names) != 0 {
+			return names[len(names)-1].(pgnodes.String).Str
+		}
+	case pgnodes.CollateClause:
+		return exprColName(node.Arg)
+	case pgnodes.SubLink:
+		switch node.SubLinkType {
+		case pgnodes.EXISTS_SUBLINK:
+			return "exists"
+		case pgnodes.ARRAY_SUBLINK:
+			return "array"
+		case pgnodes.EXPR_SUBLINK:
+			sel, ok := node.Subselect.(pgnodes.SelectStmt)
+			if !ok || len(sel.TargetList.Items) == 0 {
+				break
+			}
+			target := sel.TargetList.Items[0].(pgnodes.ResTarget)
+			if target.Name != nil {
+				return *target.Name
+			}
+			return exprColName(target.Val)
+		}
+	case pgnodes.CaseExpr:
+		return "case"
+	case pgnodes.A_ArrayExpr:
+		return "array"
+	case pgnodes.RowExpr:
+		return "row"
+	case pgnodes.CoalesceExpr:
+		return "coalesce"
+	case pgnodes.MinMaxExpr:
+		if node.Op == pgnodes.IS_GREATEST {
+			return "greatest"
+		}
+		return "least"
+	case pgnodes.SQLValueFunction:
+		switch node.Op {
+		case pgnodes.SVFOP_CURRENT_DATE:
+			return "current_date"
+		case pgnodes.SVFOP_CURRENT_TIME, pgnodes.SVFOP_CURRENT_TIME_N:
+			return "current_time"
+		case pgnodes.SVFOP_CURRENT_TIMESTAMP, pgnodes.SVFOP_CURRENT_TIMESTAMP_N:
+			return "current_timestamp"
+		case pgnodes.SVFOP_LOCALTIME, pgnodes.SVFOP_LOCALTIME_N:
+			return "localtime"
+		case pgnodes.SVFOP_LOCALTIMESTAMP, pgnodes.SVFOP_LOCALTIMESTAMP_N:
+			return "localtimestamp"
+		case pgnodes.SVFOP_CURRENT_ROLE:
+			return "current_role"
+		case pgnodes.SVFOP_CURRENT_USER:
+			return "current_user"
+		case pgnodes.SVFOP_USER:
+			return "user"
+		case pgnodes.SVFOP_SESSION_USER:
+			return "session_user"
+		case pgnodes.SVFOP_CURRENT_CATALOG:
+			return "current_catalog"
+		case pgnodes.SVFOP_CURRENT_SCHEMA:
+			return "current_schema"
+		}
+	}
+
+	return "?column?"
+}
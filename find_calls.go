@@ -5,6 +5,7 @@ import (
 	"go/ast"
 	"go/constant"
 	"go/token"
+	"go/types"
 	"sort"
 	"strings"
 
@@ -16,6 +17,14 @@ import (
 type Call struct {
 	SQL      string
 	ArgTypes []string
+	// Args are the types of the arguments, ArgTypes are their names
+	Args []types.Type
+	// Values are the values of arguments that are constants
+	Values []constant.Value
+
+	// Session groups calls that run on the same connection, tables that
+	// one of them creates or changes are seen by the ones after it
+	Session string
 
 	Package string
 	Pos     token.Position
@@ -26,6 +35,7 @@ type Constant struct {
 	Name    string
 	Val     string
 	ValSpec *ast.ValueSpec
+	Session string
 	Pos     token.Position
 }
 
@@ -119,10 +129,12 @@ func iterateCommentMap(pkg *packages.Package, cm ast.CommentMap) ([]Constant, []
 
 	for node, comments := range cm {
 		found := false
+		var session string
 
 		for _, c := range comments {
 			if strings.HasPrefix(c.Text(), "sqlboiler:check") {
 				found = true
+				session = tagSession(c.Text())
 				break
 			}
 		}
@@ -143,12 +155,18 @@ func iterateCommentMap(pkg *packages.Package, cm ast.CommentMap) ([]Constant, []
 		}
 		if genDec, ok := genNode.(*ast.GenDecl); ok {
 			c, w := tagConstants(pkg, genDec)
+			for i := range c {
+				c[i].Session = session
+			}
 			consts = append(consts, c...)
 			warns = append(warns, w...)
 			continue
 		}
 		if valSpec, ok := genNode.(*ast.ValueSpec); ok {
 			c, w := tagValueSpecConstants(pkg, valSpec)
+			for i := range c {
+				c[i].Session = session
+			}
 			consts = append(consts, c...)
 			warns = append(warns, w...)
 			continue
@@ -171,12 +189,25 @@ func iterateCommentMap(pkg *packages.Package, cm ast.CommentMap) ([]Constant, []
 			continue
 		}
 
+		call.Session = session
 		calls = append(calls, *call)
 	}
 
 	return consts, calls, warns
 }
 
+// tagSession returns the session given in a tag, it's written as
+// "sqlboiler:check session=name".
+func tagSession(tag string) string {
+	for _, field := range strings.Fields(strings.TrimPrefix(tag, "sqlboiler:check")) {
+		if strings.HasPrefix(field, "session=") {
+			return strings.TrimPrefix(field, "session=")
+		}
+	}
+
+	return ""
+}
+
 // tagConstants
 func tagConstants(pkg *packages.Package, genDec *ast.GenDecl) (consts []Constant, warns []Warn) {
 	if genDec.Tok != token.CONST {
@@ -318,6 +349,8 @@ func tagCallsByConstant(pkg *packages.Package, file *ast.File, consts []Constant
 		// We would have already skipped over the function's ctx arg
 		// so we should simply be able to get the rest of them
 		argTypes := make([]string, 0, len(callExpr.Args))
+		args := make([]types.Type, 0, len(callExpr.Args))
+		values := make([]constant.Value, 0, len(callExpr.Args))
 		for i := constIndex + 1; i < len(callExpr.Args); i++ {
 			arg := callExpr.Args[i]
 			typeAndVal, ok := pkg.TypesInfo.Types[arg]
@@ -331,11 +364,16 @@ func tagCallsByConstant(pkg *packages.Package, file *ast.File, consts []Constant
 			}
 
 			argTypes = append(argTypes, typeAndVal.Type.String())
+			args = append(args, typeAndVal.Type)
+			values = append(values, typeAndVal.Value)
 		}
 
 		calls = append(calls, Call{
 			SQL:      constVal.Val,
 			ArgTypes: argTypes,
+			Args:     args,
+			Values:   values,
+			Session:  constVal.Session,
 			Pos:      pkg.Fset.Position(callExpr.Pos()),
 		})
 
@@ -448,6 +486,8 @@ Loop:
 			}
 
 			var argTypes []string
+			var args []types.Type
+			var values []constant.Value
 			for i := sqlOffset + 1; i < len(n.Args); i++ {
 				arg := n.Args[i]
 
@@ -460,11 +500,15 @@ Loop:
 				}
 
 				argTypes = append(argTypes, typeAndVal.Type.String())
+				args = append(args, typeAndVal.Type)
+				values = append(values, typeAndVal.Value)
 			}
 
 			return &Call{
 				SQL:      sql,
 				ArgTypes: argTypes,
+				Args:     args,
+				Values:   values,
 				Pos:      pkg.Fset.Position(n.Pos()),
 			}, nil
 		case *ast.ExprStmt:
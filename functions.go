@@ -0,0 +1,790 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// Kinds of function errors
+const (
+	FuncUnknown = iota
+	FuncArity
+	FuncAggregate
+	FuncNestedAggregate
+	FuncWindow
+	FuncWindowOver
+	FuncUnknownWindow
+)
+
+// FuncErr is a call to a function that doesn't exist or that can't be
+// called the way it is.
+type FuncErr struct {
+	// Kind is FuncUnknown/Arity/Aggregate/NestedAggregate/Window/WindowOver/
+	// UnknownWindow
+	Kind int
+
+	// Name is the function's name or the window's for FuncUnknownWindow
+	Name     string
+	Args     int
+	Location int
+	// Clause is where an aggregate or window function was used when it's
+	// not allowed
+	Clause string
+
+	Fn Call
+}
+
+func (f FuncErr) Error() string {
+	var errMsg string
+	switch f.Kind {
+	case FuncUnknown:
+		errMsg = fmt.Sprintf("unknown function in sql statement: %s at pos %d", f.Name, f.Location)
+	case FuncArity:
+		errMsg = fmt.Sprintf("function %s (pos %d) does not take %d arguments", f.Name, f.Location, f.Args)
+	case FuncAggregate:
+		errMsg = fmt.Sprintf("aggregate function %s (pos %d) is not allowed in %s", f.Name, f.Location, f.Clause)
+	case FuncNestedAggregate:
+		errMsg = fmt.Sprintf("aggregate function %s (pos %d) is nested inside another aggregate", f.Name, f.Location)
+	case FuncWindow:
+		errMsg = fmt.Sprintf("window function %s (pos %d) is not allowed in %s", f.Name, f.Location, f.Clause)
+	case FuncWindowOver:
+		errMsg = fmt.Sprintf("window function %s (pos %d) requires an OVER clause", f.Name, f.Location)
+	case FuncUnknownWindow:
+		errMsg = fmt.Sprintf("window %s (pos %d) does not exist", f.Name, f.Location)
+	}
+
+	return fmt.Sprintf("%s:%d:%d %s",
+		f.Fn.Pos.Filename,
+		f.Fn.Pos.Line,
+		f.Fn.Pos.Column,
+		errMsg,
+	)
+}
+
+func (f FuncErr) call() Call {
+	return f.Fn
+}
+
+// pgFunction describes a function
+type pgFunction struct {
+	// min and max are the number of arguments the function takes, max is -1
+	// for variadic functions
+	min, max int
+
+	// args are the db types of the arguments, an empty string means the
+	// argument can be of any type
+	args []string
+	// ret is the db type the function returns, $1 means the type of the
+	// first argument and $1[] an array of it. Empty means unknown.
+	ret string
+
+	aggregate bool
+	// window functions can only be called with an OVER clause
+	window bool
+	// nullable functions can return null even when their arguments are
+	// not null, aggregates always can unless they're count.
+	nullable bool
+
+	// overloads are the other versions of the function, they only differ
+	// in their arguments and return type
+	overloads []pgFunction
+}
+
+// takes checks if the function or one of its overloads takes n arguments
+func (f pgFunction) takes(n int) bool {
+	for _, o := range append([]pgFunction{f}, f.overloads...) {
+		if o.arity(n) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// arity checks if this version of the function takes n arguments
+func (f pgFunction) arity(n int) bool {
+	return n >= f.min && (f.max < 0 || n <= f.max)
+}
+
+// forCall returns the version of the function a call resolves to. The
+// versions that take the number of arguments given and whose arguments fit
+// the ones whose types are known are the candidates, or every version that
+// takes that many when none fit. The ones the known types match exactly win
+// over the rest. Argument and return types the candidates don't agree on are
+// left unknown.
+func (f pgFunction) forCall(scope *Scope, args []pgnodes.Node) pgFunction {
+	if len(f.overloads) == 0 {
+		return f
+	}
+
+	var takes, fits []pgFunction
+	for _, v := range append([]pgFunction{f}, f.overloads...) {
+		if !v.arity(len(args)) {
+			continue
+		}
+		takes = append(takes, v)
+		if v.fits(scope, args) {
+			fits = append(fits, v)
+		}
+	}
+	if len(fits) == 0 {
+		fits = takes
+	}
+	var exact []pgFunction
+	for _, v := range fits {
+		if v.exact(scope, args) {
+			exact = append(exact, v)
+		}
+	}
+	if len(exact) != 0 {
+		fits = exact
+	}
+
+	picked := f
+	picked.overloads = nil
+	picked.args, picked.ret = nil, ""
+	if len(fits) == 0 {
+		return picked
+	}
+
+	picked.min, picked.max, picked.ret = fits[0].min, fits[0].max, fits[0].ret
+	picked.args = append([]string(nil), fits[0].args...)
+	for _, v := range fits[1:] {
+		if v.ret != picked.ret {
+			picked.ret = ""
+		}
+		for i := range picked.args {
+			if i >= len(v.args) || v.args[i] != picked.args[i] {
+				picked.args[i] = ""
+			}
+		}
+	}
+
+	return picked
+}
+
+// fits checks if the arguments whose types are known could be passed to
+// this version of the function
+func (f pgFunction) fits(scope *Scope, args []pgnodes.Node) bool {
+	for i, arg := range args {
+		if i >= len(f.args) || len(f.args[i]) == 0 {
+			continue
+		}
+		if col := exprType(scope, arg); col != nil && !dbTypesCompatible(col.DBType, f.args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exact checks if every argument whose type is known has the type this
+// version of the function takes, and at least one is known
+func (f pgFunction) exact(scope *Scope, args []pgnodes.Node) bool {
+	known := false
+	for i, arg := range args {
+		if i >= len(f.args) || len(f.args[i]) == 0 {
+			continue
+		}
+		col := exprType(scope, arg)
+		if col == nil || col.DBType == "unknown" {
+			continue
+		}
+		if col.DBType != f.args[i] {
+			return false
+		}
+		known = true
+	}
+
+	return known
+}
+
+// pgFunctions are postgres' builtin functions
+var pgFunctions = map[string]pgFunction{
+	// Aggregates, sum and avg depend on their argument so are in funcCallType
+	"count":            {min: 0, max: 1, ret: "bigint", aggregate: true},
+	"sum":              {min: 1, max: 1, aggregate: true},
+	"avg":              {min: 1, max: 1, aggregate: true},
+	"min":              {min: 1, max: 1, ret: "$1", aggregate: true},
+	"max":              {min: 1, max: 1, ret: "$1", aggregate: true},
+	"bit_and":          {min: 1, max: 1, ret: "$1", aggregate: true},
+	"bit_or":           {min: 1, max: 1, ret: "$1", aggregate: true},
+	"bool_and":         {min: 1, max: 1, args: []string{"boolean"}, ret: "boolean", aggregate: true},
+	"bool_or":          {min: 1, max: 1, args: []string{"boolean"}, ret: "boolean", aggregate: true},
+	"every":            {min: 1, max: 1, args: []string{"boolean"}, ret: "boolean", aggregate: true},
+	"array_agg":        {min: 1, max: 1, ret: "$1[]", aggregate: true},
+	"json_agg":         {min: 1, max: 1, ret: "json", aggregate: true},
+	"jsonb_agg":        {min: 1, max: 1, ret: "jsonb", aggregate: true},
+	"json_object_agg":  {min: 2, max: 2, ret: "json", aggregate: true},
+	"jsonb_object_agg": {min: 2, max: 2, ret: "jsonb", aggregate: true},
+	"stddev":           {min: 1, max: 1, ret: "numeric", aggregate: true},
+	"variance":         {min: 1, max: 1, ret: "numeric", aggregate: true},
+
+	// Window functions
+	"row_number":   {min: 0, max: 0, ret: "bigint", window: true},
+	"rank":         {min: 0, max: 0, ret: "bigint", window: true},
+	"dense_rank":   {min: 0, max: 0, ret: "bigint", window: true},
+	"ntile":        {min: 1, max: 1, args: []string{"integer"}, ret: "integer", window: true},
+	"percent_rank": {min: 0, max: 0, ret: "double precision", window: true},
+	"cume_dist":    {min: 0, max: 0, ret: "double precision", window: true},
+	"lag":          {min: 1, max: 3, args: []string{"", "integer"}, ret: "$1", window: true, nullable: true},
+	"lead":         {min: 1, max: 3, args: []string{"", "integer"}, ret: "$1", window: true, nullable: true},
+	"first_value":  {min: 1, max: 1, ret: "$1", window: true, nullable: true},
+	"last_value":   {min: 1, max: 1, ret: "$1", window: true, nullable: true},
+	"nth_value":    {min: 2, max: 2, args: []string{"", "integer"}, ret: "$1", window: true, nullable: true},
+
+	// Strings
+	"lower":            {min: 1, max: 1, args: []string{"text"}, ret: "text"},
+	"upper":            {min: 1, max: 1, args: []string{"text"}, ret: "text"},
+	"initcap":          {min: 1, max: 1, args: []string{"text"}, ret: "text"},
+	"char_length":      {min: 1, max: 1, args: []string{"text"}, ret: "integer"},
+	"btrim":            {min: 1, max: 2, args: []string{"text", "text"}, ret: "text"},
+	"ltrim":            {min: 1, max: 2, args: []string{"text", "text"}, ret: "text"},
+	"rtrim":            {min: 1, max: 2, args: []string{"text", "text"}, ret: "text"},
+	"lpad":             {min: 2, max: 3, args: []string{"text", "integer", "text"}, ret: "text"},
+	"rpad":             {min: 2, max: 3, args: []string{"text", "integer", "text"}, ret: "text"},
+	"left":             {min: 2, max: 2, args: []string{"text", "integer"}, ret: "text"},
+	"right":            {min: 2, max: 2, args: []string{"text", "integer"}, ret: "text"},
+	"repeat":           {min: 2, max: 2, args: []string{"text", "integer"}, ret: "text"},
+	"replace":          {min: 3, max: 3, args: []string{"text", "text", "text"}, ret: "text"},
+	"reverse":          {min: 1, max: 1, args: []string{"text"}, ret: "text"},
+	"translate":        {min: 3, max: 3, args: []string{"text", "text", "text"}, ret: "text"},
+	"strpos":           {min: 2, max: 2, args: []string{"text", "text"}, ret: "integer"},
+	"substr":           {min: 2, max: 3, args: []string{"text", "integer", "integer"}, ret: "text"},
+	"split_part":       {min: 3, max: 3, args: []string{"text", "text", "integer"}, ret: "text"},
+	"concat":           {min: 1, max: -1, ret: "text"},
+	"concat_ws":        {min: 2, max: -1, args: []string{"text"}, ret: "text"},
+	"format":           {min: 1, max: -1, args: []string{"text"}, ret: "text"},
+	"quote_ident":      {min: 1, max: 1, args: []string{"text"}, ret: "text"},
+	"quote_literal":    {min: 1, max: 1, args: []string{"text"}, ret: "text"},
+	"encode":           {min: 2, max: 2, args: []string{"bytea", "text"}, ret: "text"},
+	"decode":           {min: 2, max: 2, args: []string{"text", "text"}, ret: "bytea"},
+	"regexp_replace":   {min: 3, max: 4, args: []string{"text", "text", "text", "text"}, ret: "text"},
+	"string_to_array":  {min: 2, max: 3, args: []string{"text", "text", "text"}, ret: "ARRAYtext"},
+	"array_to_string":  {min: 2, max: 3, args: []string{"", "text", "text"}, ret: "text"},
+	"to_tsvector":      {min: 1, max: 2, args: []string{"text", "text"}, ret: "tsvector"},
+	"to_tsquery":       {min: 1, max: 2, args: []string{"text", "text"}, ret: "tsquery"},
+	"plainto_tsquery":  {min: 1, max: 2, args: []string{"text", "text"}, ret: "tsquery"},
+	"to_char":          {min: 2, max: 2, args: []string{"", "text"}, ret: "text"},
+	"to_number":        {min: 2, max: 2, args: []string{"text", "text"}, ret: "numeric"},
+	"gen_random_uuid":  {min: 0, max: 0, ret: "uuid"},
+	"uuid_generate_v4": {min: 0, max: 0, ret: "uuid"},
+
+	// Dates and times
+	"now":                   {min: 0, max: 0, ret: "timestamp with time zone"},
+	"transaction_timestamp": {min: 0, max: 0, ret: "timestamp with time zone"},
+	"statement_timestamp":   {min: 0, max: 0, ret: "timestamp with time zone"},
+	"clock_timestamp":       {min: 0, max: 0, ret: "timestamp with time zone"},
+	"to_date":               {min: 2, max: 2, args: []string{"text", "text"}, ret: "date"},
+	"age":                   {min: 1, max: 2, ret: "interval"},
+	"make_date":             {min: 3, max: 3, args: []string{"integer", "integer", "integer"}, ret: "date"},
+	"make_interval":         {min: 0, max: 7, args: []string{"integer", "integer", "integer", "integer", "integer", "integer", "double precision"}, ret: "interval"},
+
+	// to_timestamp converts either an epoch or a string in a given format
+	"to_timestamp": {min: 1, max: 1, args: []string{"double precision"}, ret: "timestamp with time zone", overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"text", "text"}, ret: "timestamp with time zone"},
+	}},
+
+	// Functions that take either text or bytea
+	"length": {min: 1, max: 2, args: []string{"text"}, ret: "integer", overloads: []pgFunction{
+		{min: 1, max: 1, args: []string{"bytea"}, ret: "integer"},
+	}},
+	"octet_length": {min: 1, max: 1, args: []string{"text"}, ret: "integer", overloads: []pgFunction{
+		{min: 1, max: 1, args: []string{"bytea"}, ret: "integer"},
+	}},
+	"md5": {min: 1, max: 1, args: []string{"text"}, ret: "text", overloads: []pgFunction{
+		{min: 1, max: 1, args: []string{"bytea"}, ret: "text"},
+	}},
+	"string_agg": {min: 2, max: 2, args: []string{"text", "text"}, ret: "text", aggregate: true, overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"bytea", "bytea"}, ret: "bytea"},
+	}},
+
+	// Functions that take either a timestamp or an interval
+	"date_trunc": {min: 2, max: 3, args: []string{"text", "timestamp with time zone"}, ret: "timestamp with time zone", overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"text", "interval"}, ret: "interval"},
+	}},
+	"date_part": {min: 2, max: 2, args: []string{"text", "timestamp with time zone"}, ret: "double precision", overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"text", "interval"}, ret: "double precision"},
+	}},
+
+	// Ranges, the constructors are named after the range types
+	"int4range":   {min: 2, max: 3, ret: "int4range"},
+	"int8range":   {min: 2, max: 3, ret: "int8range"},
+	"numrange":    {min: 2, max: 3, ret: "numrange"},
+	"tsrange":     {min: 2, max: 3, ret: "tsrange"},
+	"tstzrange":   {min: 2, max: 3, ret: "tstzrange"},
+	"daterange":   {min: 2, max: 3, ret: "daterange"},
+	"isempty":     {min: 1, max: 1, ret: "boolean"},
+	"lower_inc":   {min: 1, max: 1, ret: "boolean"},
+	"upper_inc":   {min: 1, max: 1, ret: "boolean"},
+	"lower_inf":   {min: 1, max: 1, ret: "boolean"},
+	"upper_inf":   {min: 1, max: 1, ret: "boolean"},
+	"range_merge": {min: 2, max: 2, ret: "$1"},
+
+	// Math
+	"abs":    {min: 1, max: 1, ret: "$1"},
+	"ceil":   {min: 1, max: 1, ret: "$1"},
+	"floor":  {min: 1, max: 1, ret: "$1"},
+	"round":  {min: 1, max: 2, args: []string{"numeric", "integer"}, ret: "numeric"},
+	"trunc":  {min: 1, max: 2, args: []string{"numeric", "integer"}, ret: "numeric"},
+	"random": {min: 0, max: 0, ret: "double precision"},
+
+	// Arrays
+	"array_length":  {min: 2, max: 2, args: []string{"", "integer"}, ret: "integer", nullable: true},
+	"cardinality":   {min: 1, max: 1, ret: "integer"},
+	"array_append":  {min: 2, max: 2, ret: "$1"},
+	"array_cat":     {min: 2, max: 2, ret: "$1"},
+	"array_remove":  {min: 2, max: 2, ret: "$1"},
+	"array_replace": {min: 3, max: 3, ret: "$1"},
+
+	// JSON
+	"to_json":                 {min: 1, max: 1, ret: "json"},
+	"to_jsonb":                {min: 1, max: 1, ret: "jsonb"},
+	"row_to_json":             {min: 1, max: 2, ret: "json"},
+	"json_build_object":       {min: 0, max: -1, ret: "json"},
+	"json_build_array":        {min: 0, max: -1, ret: "json"},
+	"jsonb_build_object":      {min: 0, max: -1, ret: "jsonb"},
+	"jsonb_build_array":       {min: 0, max: -1, ret: "jsonb"},
+	"json_array_length":       {min: 1, max: 1, args: []string{"json"}, ret: "integer"},
+	"jsonb_array_length":      {min: 1, max: 1, args: []string{"jsonb"}, ret: "integer"},
+	"jsonb_set":               {min: 3, max: 4, args: []string{"jsonb", "ARRAYtext", "jsonb", "boolean"}, ret: "jsonb"},
+	"jsonb_typeof":            {min: 1, max: 1, args: []string{"jsonb"}, ret: "text"},
+	"json_typeof":             {min: 1, max: 1, args: []string{"json"}, ret: "text"},
+	"jsonb_pretty":            {min: 1, max: 1, args: []string{"jsonb"}, ret: "text"},
+	"jsonb_strip_nulls":       {min: 1, max: 1, args: []string{"jsonb"}, ret: "jsonb"},
+	"json_extract_path":       {min: 2, max: -1, args: []string{"json"}, ret: "json", nullable: true},
+	"jsonb_extract_path":      {min: 2, max: -1, args: []string{"jsonb"}, ret: "jsonb", nullable: true},
+	"json_extract_path_text":  {min: 2, max: -1, args: []string{"json"}, ret: "text", nullable: true},
+	"jsonb_extract_path_text": {min: 2, max: -1, args: []string{"jsonb"}, ret: "text", nullable: true},
+
+	// Other aggregates, the ordered set ones return the type they're
+	// ordered by
+	"stddev_pop":      {min: 1, max: 1, ret: "numeric", aggregate: true},
+	"stddev_samp":     {min: 1, max: 1, ret: "numeric", aggregate: true},
+	"var_pop":         {min: 1, max: 1, ret: "numeric", aggregate: true},
+	"var_samp":        {min: 1, max: 1, ret: "numeric", aggregate: true},
+	"corr":            {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", aggregate: true},
+	"covar_pop":       {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", aggregate: true},
+	"covar_samp":      {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", aggregate: true},
+	"regr_count":      {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "bigint", aggregate: true},
+	"regr_slope":      {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", aggregate: true},
+	"regr_intercept":  {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", aggregate: true},
+	"xmlagg":          {min: 1, max: 1, args: []string{"xml"}, ret: "xml", aggregate: true},
+	"percentile_cont": {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision", aggregate: true},
+	"percentile_disc": {min: 1, max: 1, args: []string{"double precision"}, ret: "$order", aggregate: true},
+	"mode":            {min: 0, max: 0, ret: "$order", aggregate: true},
+
+	// Other strings, the ones without argument types also take bytea
+	"character_length":      {min: 1, max: 1, args: []string{"text"}, ret: "integer"},
+	"bit_length":            {min: 1, max: 1, ret: "integer"},
+	"substring":             {min: 2, max: 3, ret: "$1"},
+	"position":              {min: 2, max: 2, ret: "integer"},
+	"overlay":               {min: 3, max: 4, ret: "$1"},
+	"quote_nullable":        {min: 1, max: 1, ret: "text"},
+	"sha224":                {min: 1, max: 1, args: []string{"bytea"}, ret: "bytea"},
+	"sha256":                {min: 1, max: 1, args: []string{"bytea"}, ret: "bytea"},
+	"sha384":                {min: 1, max: 1, args: []string{"bytea"}, ret: "bytea"},
+	"sha512":                {min: 1, max: 1, args: []string{"bytea"}, ret: "bytea"},
+	"regexp_match":          {min: 2, max: 3, args: []string{"text", "text", "text"}, ret: "ARRAYtext", nullable: true},
+	"regexp_matches":        {min: 2, max: 3, args: []string{"text", "text", "text"}, ret: "ARRAYtext"},
+	"regexp_split_to_array": {min: 2, max: 3, args: []string{"text", "text", "text"}, ret: "ARRAYtext"},
+	"regexp_split_to_table": {min: 2, max: 3, args: []string{"text", "text", "text"}, ret: "text"},
+	"phraseto_tsquery":      {min: 1, max: 2, args: []string{"text", "text"}, ret: "tsquery"},
+	"websearch_to_tsquery":  {min: 1, max: 2, args: []string{"text", "text"}, ret: "tsquery"},
+	"ts_rank":               {min: 2, max: 4, ret: "real"},
+	"ts_rank_cd":            {min: 2, max: 4, ret: "real"},
+	"ts_headline":           {min: 2, max: 4, ret: "text"},
+	"setweight":             {min: 2, max: 2, args: []string{"tsvector"}, ret: "tsvector"},
+	"to_hex":                {min: 1, max: 1, args: []string{"bigint"}, ret: "text"},
+	"ascii":                 {min: 1, max: 1, args: []string{"text"}, ret: "integer"},
+	"chr":                   {min: 1, max: 1, args: []string{"integer"}, ret: "text"},
+	"starts_with":           {min: 2, max: 2, args: []string{"text", "text"}, ret: "boolean"},
+	"convert_from":          {min: 2, max: 2, args: []string{"bytea", "text"}, ret: "text"},
+	"convert_to":            {min: 2, max: 2, args: []string{"text", "text"}, ret: "bytea"},
+	"num_nonnulls":          {min: 1, max: -1, ret: "integer"},
+	"num_nulls":             {min: 1, max: -1, ret: "integer"},
+	"pg_typeof":             {min: 1, max: 1, ret: "regtype"},
+	"collation_for":         {min: 1, max: 1, ret: "text", nullable: true},
+	"normalize":             {min: 1, max: 2, args: []string{"text", "text"}, ret: "text"},
+	"unaccent":              {min: 1, max: 2, ret: "text"},
+	"similar_escape":        {min: 1, max: 2, args: []string{"text", "text"}, ret: "text"},
+	"like_escape":           {min: 2, max: 2, ret: "$1"},
+
+	// Other dates and times
+	"timeofday":        {min: 0, max: 0, ret: "text"},
+	"make_time":        {min: 3, max: 3, args: []string{"integer", "integer", "double precision"}, ret: "time without time zone"},
+	"make_timestamp":   {min: 6, max: 6, args: []string{"integer", "integer", "integer", "integer", "integer", "double precision"}, ret: "timestamp without time zone"},
+	"make_timestamptz": {min: 6, max: 7, args: []string{"integer", "integer", "integer", "integer", "integer", "double precision", "text"}, ret: "timestamp with time zone"},
+	"justify_days":     {min: 1, max: 1, args: []string{"interval"}, ret: "interval"},
+	"justify_hours":    {min: 1, max: 1, args: []string{"interval"}, ret: "interval"},
+	"justify_interval": {min: 1, max: 1, args: []string{"interval"}, ret: "interval"},
+	"isfinite":         {min: 1, max: 1, ret: "boolean"},
+	"overlaps":         {min: 4, max: 4, ret: "boolean"},
+
+	// Other math, the ones without argument types take any number
+	"ceiling":      {min: 1, max: 1, ret: "$1"},
+	"sign":         {min: 1, max: 1, ret: "$1"},
+	"cbrt":         {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"mod":          {min: 2, max: 2, ret: "$1"},
+	"div":          {min: 2, max: 2, args: []string{"numeric", "numeric"}, ret: "numeric"},
+	"pi":           {min: 0, max: 0, ret: "double precision"},
+	"setseed":      {min: 1, max: 1, args: []string{"double precision"}, ret: "void"},
+	"degrees":      {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"radians":      {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"sin":          {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"cos":          {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"tan":          {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"asin":         {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"acos":         {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"atan":         {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision"},
+	"atan2":        {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision"},
+	"width_bucket": {min: 2, max: 4, ret: "integer"},
+	"gcd":          {min: 2, max: 2, ret: "$1"},
+	"lcm":          {min: 2, max: 2, ret: "$1"},
+	"scale":        {min: 1, max: 1, args: []string{"numeric"}, ret: "integer"},
+
+	// Other arrays and sets, unnest's columns come from
+	// setReturningFuncCols
+	"array_lower":         {min: 2, max: 2, args: []string{"", "integer"}, ret: "integer", nullable: true},
+	"array_upper":         {min: 2, max: 2, args: []string{"", "integer"}, ret: "integer", nullable: true},
+	"array_ndims":         {min: 1, max: 1, ret: "integer", nullable: true},
+	"array_dims":          {min: 1, max: 1, ret: "text", nullable: true},
+	"array_prepend":       {min: 2, max: 2, ret: "$1[]"},
+	"array_position":      {min: 2, max: 3, args: []string{"", "", "integer"}, ret: "integer", nullable: true},
+	"array_positions":     {min: 2, max: 2, ret: "ARRAYinteger"},
+	"array_fill":          {min: 2, max: 3, args: []string{"", "ARRAYinteger", "ARRAYinteger"}, ret: "$1[]"},
+	"unnest":              {min: 1, max: -1},
+	"generate_series":     {min: 2, max: 3, ret: "$1"},
+	"generate_subscripts": {min: 2, max: 3, args: []string{"", "integer", "boolean"}, ret: "integer"},
+
+	// Other JSON
+	"array_to_json":             {min: 1, max: 2, args: []string{"", "boolean"}, ret: "json"},
+	"json_object":               {min: 1, max: 2, args: []string{"ARRAYtext", "ARRAYtext"}, ret: "json"},
+	"jsonb_object":              {min: 1, max: 2, args: []string{"ARRAYtext", "ARRAYtext"}, ret: "jsonb"},
+	"jsonb_insert":              {min: 3, max: 4, args: []string{"jsonb", "ARRAYtext", "jsonb", "boolean"}, ret: "jsonb"},
+	"json_strip_nulls":          {min: 1, max: 1, args: []string{"json"}, ret: "json"},
+	"json_array_elements":       {min: 1, max: 1, args: []string{"json"}, ret: "json"},
+	"jsonb_array_elements":      {min: 1, max: 1, args: []string{"jsonb"}, ret: "jsonb"},
+	"json_array_elements_text":  {min: 1, max: 1, args: []string{"json"}, ret: "text"},
+	"jsonb_array_elements_text": {min: 1, max: 1, args: []string{"jsonb"}, ret: "text"},
+	"json_each":                 {min: 1, max: 1, args: []string{"json"}, ret: "record"},
+	"jsonb_each":                {min: 1, max: 1, args: []string{"jsonb"}, ret: "record"},
+	"json_each_text":            {min: 1, max: 1, args: []string{"json"}, ret: "record"},
+	"jsonb_each_text":           {min: 1, max: 1, args: []string{"jsonb"}, ret: "record"},
+	"json_object_keys":          {min: 1, max: 1, args: []string{"json"}, ret: "text"},
+	"jsonb_object_keys":         {min: 1, max: 1, args: []string{"jsonb"}, ret: "text"},
+	"json_populate_record":      {min: 2, max: 3, args: []string{"", "json", "boolean"}, ret: "$1"},
+	"jsonb_populate_record":     {min: 2, max: 2, args: []string{"", "jsonb"}, ret: "$1"},
+	"json_populate_recordset":   {min: 2, max: 3, args: []string{"", "json", "boolean"}, ret: "$1"},
+	"jsonb_populate_recordset":  {min: 2, max: 2, args: []string{"", "jsonb"}, ret: "$1"},
+	"json_to_record":            {min: 1, max: 1, args: []string{"json"}, ret: "record"},
+	"jsonb_to_record":           {min: 1, max: 1, args: []string{"jsonb"}, ret: "record"},
+	"json_to_recordset":         {min: 1, max: 1, args: []string{"json"}, ret: "record"},
+	"jsonb_to_recordset":        {min: 1, max: 1, args: []string{"jsonb"}, ret: "record"},
+	"jsonb_path_query":          {min: 2, max: 4, args: []string{"jsonb", "jsonpath", "jsonb", "boolean"}, ret: "jsonb"},
+	"jsonb_path_query_array":    {min: 2, max: 4, args: []string{"jsonb", "jsonpath", "jsonb", "boolean"}, ret: "jsonb"},
+	"jsonb_path_query_first":    {min: 2, max: 4, args: []string{"jsonb", "jsonpath", "jsonb", "boolean"}, ret: "jsonb", nullable: true},
+	"jsonb_path_exists":         {min: 2, max: 4, args: []string{"jsonb", "jsonpath", "jsonb", "boolean"}, ret: "boolean"},
+	"jsonb_path_match":          {min: 2, max: 4, args: []string{"jsonb", "jsonpath", "jsonb", "boolean"}, ret: "boolean"},
+
+	// System, the advisory locks take a bigint or two integers and
+	// sequences are named by a regclass
+	"current_setting":           {min: 1, max: 2, args: []string{"text", "boolean"}, ret: "text", nullable: true},
+	"set_config":                {min: 3, max: 3, args: []string{"text", "text", "boolean"}, ret: "text"},
+	"pg_advisory_lock":          {min: 1, max: 2, ret: "void"},
+	"pg_advisory_unlock":        {min: 1, max: 2, ret: "boolean"},
+	"pg_try_advisory_lock":      {min: 1, max: 2, ret: "boolean"},
+	"pg_advisory_xact_lock":     {min: 1, max: 2, ret: "void"},
+	"pg_try_advisory_xact_lock": {min: 1, max: 2, ret: "boolean"},
+	"pg_advisory_unlock_all":    {min: 0, max: 0, ret: "void"},
+	"nextval":                   {min: 1, max: 1, ret: "bigint"},
+	"currval":                   {min: 1, max: 1, ret: "bigint"},
+	"setval":                    {min: 2, max: 3, args: []string{"", "bigint", "boolean"}, ret: "bigint"},
+	"lastval":                   {min: 0, max: 0, ret: "bigint"},
+	"txid_current":              {min: 0, max: 0, ret: "bigint"},
+	"version":                   {min: 0, max: 0, ret: "text"},
+	"pg_sleep":                  {min: 1, max: 1, args: []string{"double precision"}, ret: "void"},
+	"to_regclass":               {min: 1, max: 1, args: []string{"text"}, ret: "regclass", nullable: true},
+	"format_type":               {min: 2, max: 2, args: []string{"oid", "integer"}, ret: "text", nullable: true},
+	"inet_client_addr":          {min: 0, max: 0, ret: "inet", nullable: true},
+	"host":                      {min: 1, max: 1, args: []string{"inet"}, ret: "text"},
+	"family":                    {min: 1, max: 1, args: []string{"inet"}, ret: "integer"},
+	"masklen":                   {min: 1, max: 1, args: []string{"inet"}, ret: "integer"},
+	"netmask":                   {min: 1, max: 1, args: []string{"inet"}, ret: "inet"},
+	"network":                   {min: 1, max: 1, args: []string{"inet"}, ret: "cidr"},
+	"pg_column_size":            {min: 1, max: 1, ret: "integer"},
+	"pg_size_pretty":            {min: 1, max: 1, ret: "text"},
+	"pg_total_relation_size":    {min: 1, max: 1, ret: "bigint"},
+	"pg_relation_size":          {min: 1, max: 2, args: []string{"", "text"}, ret: "bigint"},
+	"pg_notify":                 {min: 2, max: 2, args: []string{"text", "text"}, ret: "void"},
+	"pg_backend_pid":            {min: 0, max: 0, ret: "integer"},
+	"pg_get_serial_sequence":    {min: 2, max: 2, args: []string{"text", "text"}, ret: "text", nullable: true},
+
+	// Functions with a version for double precision and one for numeric,
+	// which one is only known when the argument is exactly one of them
+	"sqrt": {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision", overloads: numericOverload},
+	"exp":  {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision", overloads: numericOverload},
+	"ln":   {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision", overloads: numericOverload},
+	"log": {min: 1, max: 1, args: []string{"double precision"}, ret: "double precision", overloads: []pgFunction{
+		{min: 1, max: 1, args: []string{"numeric"}, ret: "numeric"},
+		{min: 2, max: 2, args: []string{"numeric", "numeric"}, ret: "numeric"},
+	}},
+	"power": {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"numeric", "numeric"}, ret: "numeric"},
+	}},
+	"pow": {min: 2, max: 2, args: []string{"double precision", "double precision"}, ret: "double precision", overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"numeric", "numeric"}, ret: "numeric"},
+	}},
+
+	// timezone converts between timestamps with and without a time zone
+	"timezone": {min: 2, max: 2, args: []string{"text", "timestamp with time zone"}, ret: "timestamp without time zone", overloads: []pgFunction{
+		{min: 2, max: 2, args: []string{"text", "timestamp without time zone"}, ret: "timestamp with time zone"},
+	}},
+}
+
+// numericOverload is the numeric version of the math functions that also
+// take a double precision
+var numericOverload = []pgFunction{
+	{min: 1, max: 1, args: []string{"numeric"}, ret: "numeric"},
+}
+
+// builtinFunction finds a builtin function by name. Type names can be called
+// like functions to cast their argument, date(created_at) is the same as
+// created_at::date.
+func builtinFunction(name string) (pgFunction, bool) {
+	if f, ok := pgFunctions[name]; ok {
+		return f, true
+	}
+	if dbType, ok := pgTypeNames[name]; ok {
+		return pgFunction{min: 1, max: 1, ret: dbType}, true
+	}
+
+	return pgFunction{}, false
+}
+
+// funcCallType determines the column information for the result of a
+// function call. It returns nil when the type can't be determined.
+func funcCallType(scope *Scope, fc pgnodes.FuncCall) *drivers.Column {
+	name := funcCallName(fc)
+	f, ok := builtinFunction(name)
+	if !ok {
+		return nil
+	}
+	f = f.forCall(scope, fc.Args.Items)
+
+	var first *drivers.Column
+	nullable := false
+	for i, arg := range fc.Args.Items {
+		col := exprType(scope, arg)
+		if i == 0 {
+			first = resolveUnknown(col)
+		}
+		if col == nil || col.Nullable {
+			nullable = true
+		}
+	}
+	if f.aggregate {
+		nullable = name != "count"
+	}
+	nullable = nullable || f.nullable
+
+	var dbType string
+	switch f.ret {
+	case "":
+		if first == nil {
+			return nil
+		}
+		// sum and avg return a type that depends on their argument, any
+		// other function without one is ambiguous
+		switch {
+		case name == "sum" && (first.DBType == "smallint" || first.DBType == "integer"):
+			dbType = "bigint"
+		case name == "sum" && first.DBType == "bigint", name == "avg" && numericRank(first.DBType) != 0 && numericRank(first.DBType) <= numericRank("numeric"):
+			dbType = "numeric"
+		case name == "sum", name == "avg":
+			dbType = first.DBType
+		default:
+			return nil
+		}
+	case "$1":
+		if first == nil {
+			return nil
+		}
+		dbType = first.DBType
+	case "$1[]":
+		if first == nil {
+			return nil
+		}
+		dbType = "ARRAY" + first.DBType
+	case "$order":
+		if len(fc.AggOrder.Items) == 0 {
+			return nil
+		}
+		sortBy, ok := fc.AggOrder.Items[0].(pgnodes.SortBy)
+		if !ok {
+			return nil
+		}
+		col := resolveUnknown(exprType(scope, sortBy.Node))
+		if col == nil {
+			return nil
+		}
+		dbType = col.DBType
+	default:
+		dbType = f.ret
+	}
+
+	return pseudoColumn(name, dbType, nullable)
+}
+
+// lookupFunction finds a function by its possibly schema qualified name.
+// known is false if we can't tell whether the function exists or not.
+func lookupFunction(state *State, schema, name string) (f pgFunction, ok, known bool) {
+	if f, ok := state.Functions[name]; ok {
+		return f, true, true
+	}
+	for _, extra := range state.ExtraFunctions {
+		if extra == name {
+			return pgFunction{max: -1}, true, true
+		}
+	}
+
+	if len(schema) != 0 && schema != "pg_catalog" {
+		return pgFunction{}, false, false
+	}
+
+	// The parser qualifies functions it rewrites syntax into (like
+	// substring) with pg_catalog, there are more of them than we know of.
+	f, ok = builtinFunction(name)
+	return f, ok, ok || len(schema) == 0
+}
+
+// checkFuncCall checks that a function exists, that it's given the right
+// number of arguments and that aggregates are used where they're allowed.
+func checkFuncCall(state *State, fn Call, scope *Scope, fc pgnodes.FuncCall) (errs []error) {
+	name := funcCallName(fc)
+	f, ok, known := lookupFunction(state, funcCallSchema(fc), name)
+	if !known {
+		return nil
+	}
+	if !ok {
+		return []error{FuncErr{Kind: FuncUnknown, Name: name, Location: fc.Location, Fn: fn}}
+	}
+
+	nArgs := len(fc.Args.Items)
+	if !f.takes(nArgs) {
+		errs = append(errs, FuncErr{Kind: FuncArity, Name: name, Args: nArgs, Location: fc.Location, Fn: fn})
+	}
+
+	if f.window && fc.Over == nil {
+		errs = append(errs, FuncErr{Kind: FuncWindowOver, Name: name, Location: fc.Location, Fn: fn})
+	}
+
+	// With an OVER clause aggregates are window functions
+	if !f.aggregate || fc.Over != nil {
+		return errs
+	}
+
+	switch {
+	case len(scope.aggClause) != 0:
+		errs = append(errs, FuncErr{
+			Kind:     FuncAggregate,
+			Name:     name,
+			Location: fc.Location,
+			Clause:   scope.aggClause,
+			Fn:       fn,
+		})
+	case scope.inAggregate:
+		errs = append(errs, FuncErr{Kind: FuncNestedAggregate, Name: name, Location: fc.Location, Fn: fn})
+	}
+
+	return errs
+}
+
+// funcCallSchema returns the schema a function call is qualified with
+func funcCallSchema(fc pgnodes.FuncCall) string {
+	if len(fc.Funcname.Items) < 2 {
+		return ""
+	}
+
+	return fc.Funcname.Items[len(fc.Funcname.Items)-2].(pgnodes.String).Str
+}
+
+// funcCallName returns the unqualified name of the function being called
+func funcCallName(fc pgnodes.FuncCall) string {
+	if len(fc.Funcname.Items) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(fc.Funcname.Items[len(fc.Funcname.Items)-1].(pgnodes.String).Str)
+}
+
+// setReturningFuncCols returns the columns a set returning function produces
+// when used in a from clause. Functions that return a single scalar produce
+// one column with no name, it should be named after the function's alias.
+func setReturningFuncCols(scope *Scope, fc pgnodes.FuncCall) []outputColRef {
+	scalar := func(dbType string) []outputColRef {
+		ref := outputColRef{}
+		if len(dbType) != 0 {
+			ref.col = pseudoColumn("", dbType, false)
+		}
+		return []outputColRef{ref}
+	}
+	named := func(pairs ...string) []outputColRef {
+		var refs []outputColRef
+		for i := 0; i < len(pairs); i += 2 {
+			refs = append(refs, outputColRef{
+				name: pairs[i],
+				col:  pseudoColumn(pairs[i], pairs[i+1], true),
+			})
+		}
+		return refs
+	}
+
+	switch funcCallName(fc) {
+	case "generate_series":
+		if len(fc.Args.Items) != 0 {
+			if col := exprType(scope, fc.Args.Items[0]); col != nil && col.DBType != "unknown" {
+				return scalar(col.DBType)
+			}
+		}
+		return scalar("")
+	case "generate_subscripts":
+		return scalar("integer")
+	case "unnest":
+		// Unnest in a from clause can take several arrays and produces
+		// a column for each of them
+		var refs []outputColRef
+		for _, arg := range fc.Args.Items {
+			ref := outputColRef{}
+			if col := exprType(scope, arg); col != nil {
+				if elem := arrayElemDBType(col.DBType); len(elem) != 0 {
+					ref.col = pseudoColumn("", elem, true)
+				}
+			}
+			refs = append(refs, ref)
+		}
+		return refs
+	case "json_array_elements":
+		return named("value", "json")
+	case "jsonb_array_elements":
+		return named("value", "jsonb")
+	case "json_array_elements_text", "jsonb_array_elements_text":
+		return named("value", "text")
+	case "json_each":
+		return named("key", "text", "value", "json")
+	case "jsonb_each":
+		return named("key", "text", "value", "jsonb")
+	case "json_each_text", "jsonb_each_text":
+		return named("key", "text", "value", "text")
+	case "json_object_keys", "jsonb_object_keys", "regexp_split_to_table":
+		return scalar("text")
+	case "regexp_matches":
+		return scalar("ARRAYtext")
+	}
+
+	return scalar("")
+}
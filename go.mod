@@ -7,5 +7,6 @@ require (
 	github.com/friendsofgo/errors v0.9.2
 	github.com/lfittl/pg_query_go v1.0.0
 	github.com/volatiletech/sqlboiler/v4 v4.0.0
+	github.com/volatiletech/strmangle v0.0.1
 	golang.org/x/tools v0.0.0-20191130070609-6e064ea0cf2d
 )
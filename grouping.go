@@ -0,0 +1,540 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// OrdinalErr occurs when ORDER BY or GROUP BY refers to a position in the
+// select list that it doesn't have.
+type OrdinalErr struct {
+	// Clause is ORDER BY/GROUP BY
+	Clause   string
+	Position int
+	// Count is how many columns the select list has
+	Count    int
+	Location int
+
+	Fn Call
+}
+
+func (o OrdinalErr) Error() string {
+	return fmt.Sprintf("%s:%d:%d %s position %d (pos %d) is not in the select list which has %d columns",
+		o.Fn.Pos.Filename,
+		o.Fn.Pos.Line,
+		o.Fn.Pos.Column,
+		o.Clause,
+		o.Position,
+		o.Location,
+		o.Count,
+	)
+}
+
+func (o OrdinalErr) call() Call {
+	return o.Fn
+}
+
+// GroupingErr occurs when a grouped select uses a column outside of an
+// aggregate that isn't grouped by.
+type GroupingErr struct {
+	Table    string
+	Column   string
+	Location int
+
+	Fn Call
+}
+
+func (g GroupingErr) Error() string {
+	ident := g.Column
+	if len(g.Table) != 0 {
+		ident = g.Table + "." + ident
+	}
+
+	return fmt.Sprintf("%s:%d:%d column %q (pos %d) must appear in the GROUP BY clause or be used in an aggregate function",
+		g.Fn.Pos.Filename,
+		g.Fn.Pos.Line,
+		g.Fn.Pos.Column,
+		ident,
+		g.Location,
+	)
+}
+
+func (g GroupingErr) call() Call {
+	return g.Fn
+}
+
+// DistinctOnErr occurs when the DISTINCT ON expressions aren't the ones
+// that ORDER BY starts with, postgres needs them to be so that the row it
+// keeps for each distinct value is well defined.
+type DistinctOnErr struct {
+	Location int
+
+	Fn Call
+}
+
+func (d DistinctOnErr) Error() string {
+	return fmt.Sprintf("%s:%d:%d DISTINCT ON expression (pos %d) must match the initial ORDER BY expressions",
+		d.Fn.Pos.Filename,
+		d.Fn.Pos.Line,
+		d.Fn.Pos.Column,
+		d.Location,
+	)
+}
+
+func (d DistinctOnErr) call() Call {
+	return d.Fn
+}
+
+// ordinal returns the position an integer constant refers to
+func ordinal(n pgnodes.Node) (position, location int, ok bool) {
+	c, ok := n.(pgnodes.A_Const)
+	if !ok {
+		return 0, 0, false
+	}
+	i, ok := c.Val.(pgnodes.Integer)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return int(i.Ival), c.Location, true
+}
+
+// checkOrdinals checks the positions used in an ORDER BY or GROUP BY are
+// in the select list.
+func checkOrdinals(fn Call, clause string, items []pgnodes.Node, count int) (errs []error) {
+	for _, item := range items {
+		if sortBy, ok := item.(pgnodes.SortBy); ok {
+			item = sortBy.Node
+		}
+
+		position, location, ok := ordinal(item)
+		if !ok || (position >= 1 && position <= count) {
+			continue
+		}
+
+		errs = append(errs, OrdinalErr{
+			Clause:   clause,
+			Position: position,
+			Count:    count,
+			Location: location,
+			Fn:       fn,
+		})
+	}
+
+	return errs
+}
+
+// groupingExprs flattens GROUPING SETS, ROLLUP and CUBE into the
+// expressions they group by.
+func groupingExprs(items []pgnodes.Node) []pgnodes.Node {
+	var exprs []pgnodes.Node
+	for _, item := range items {
+		switch i := item.(type) {
+		case pgnodes.GroupingSet:
+			exprs = append(exprs, groupingExprs(i.Content.Items)...)
+		case pgnodes.RowExpr:
+			// ROLLUP ((a, b)) groups the columns of the row together
+			exprs = append(exprs, i.Args.Items...)
+		default:
+			exprs = append(exprs, item)
+		}
+	}
+
+	return exprs
+}
+
+// exprChildren returns the expressions directly inside an expression.
+// Subqueries are their own select and aren't looked into.
+func exprChildren(n pgnodes.Node) []pgnodes.Node {
+	switch node := n.(type) {
+	case pgnodes.ResTarget:
+		return []pgnodes.Node{node.Val}
+	case pgnodes.A_Expr:
+		return []pgnodes.Node{node.Lexpr, node.Rexpr}
+	case pgnodes.BoolExpr:
+		return node.Args.Items
+	case pgnodes.FuncCall:
+		children := append([]pgnodes.Node{node.AggFilter}, node.Args.Items...)
+		children = append(children, node.AggOrder.Items...)
+		if node.Over != nil {
+			children = append(children, node.Over.PartitionClause.Items...)
+			children = append(children, node.Over.OrderClause.Items...)
+		}
+		return children
+	case pgnodes.TypeCast:
+		return []pgnodes.Node{node.Arg}
+	case pgnodes.CollateClause:
+		return []pgnodes.Node{node.Arg}
+	case pgnodes.NamedArgExpr:
+		return []pgnodes.Node{node.Arg}
+	case pgnodes.CaseExpr:
+		return append([]pgnodes.Node{node.Arg, node.Defresult}, node.Args.Items...)
+	case pgnodes.CaseWhen:
+		return []pgnodes.Node{node.Expr, node.Result}
+	case pgnodes.CoalesceExpr:
+		return node.Args.Items
+	case pgnodes.MinMaxExpr:
+		return node.Args.Items
+	case pgnodes.RowExpr:
+		return node.Args.Items
+	case pgnodes.A_ArrayExpr:
+		return node.Elements.Items
+	case pgnodes.A_Indirection:
+		return append([]pgnodes.Node{node.Arg}, node.Indirection.Items...)
+	case pgnodes.A_Indices:
+		return []pgnodes.Node{node.Lidx, node.Uidx}
+	case pgnodes.NullTest:
+		return []pgnodes.Node{node.Arg}
+	case pgnodes.BooleanTest:
+		return []pgnodes.Node{node.Arg}
+	case pgnodes.SortBy:
+		return []pgnodes.Node{node.Node}
+	case pgnodes.List:
+		return node.Items
+	}
+
+	return nil
+}
+
+// isAggregate checks if a function call is an aggregate, used as a window
+// function it isn't.
+func isAggregate(state *State, n pgnodes.Node) bool {
+	fc, ok := n.(pgnodes.FuncCall)
+	if !ok || fc.Over != nil {
+		return false
+	}
+
+	f, ok, _ := lookupFunction(state, "", funcCallName(fc))
+	return ok && f.aggregate
+}
+
+// hasAggregate checks if there's an aggregate anywhere in the expressions
+func hasAggregate(state *State, exprs ...pgnodes.Node) bool {
+	for _, e := range exprs {
+		if e == nil {
+			continue
+		}
+		if isAggregate(state, e) || hasAggregate(state, exprChildren(e)...) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exprKey is an expression's parse tree without locations so expressions
+// that are written the same compare equal.
+func exprKey(n pgnodes.Node) string {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return ""
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return ""
+	}
+
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			delete(val, "location")
+			for _, child := range val {
+				strip(child)
+			}
+		case []interface{}:
+			for _, child := range val {
+				strip(child)
+			}
+		}
+	}
+	strip(tree)
+
+	b, _ = json.Marshal(tree)
+	return string(b)
+}
+
+// grouping is what a grouped select groups by, columns are kept by the
+// index of their table in the scope so self joins stay apart.
+type grouping struct {
+	state *State
+	scope *Scope
+	// level is the index of the select's first table, tables before it
+	// belong to outer selects and are constant within it
+	level   int
+	columns map[int]map[string]bool
+	exprs   map[string]bool
+}
+
+// checkGrouping applies postgres's grouping rule to a select whose tables
+// and output names are in scope. When it groups or uses aggregates the
+// columns in its select list and HAVING must be grouped by, be part of a
+// table whose primary key is grouped by, or be inside an aggregate.
+func checkGrouping(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt) (errs []error) {
+	targets := sel.TargetList.Items
+	if len(sel.GroupClause.Items) == 0 && sel.HavingClause == nil &&
+		!hasAggregate(state, targets...) {
+		return nil
+	}
+	// Aggregates in GROUP BY are already an error
+	if hasAggregate(state, sel.GroupClause.Items...) {
+		return nil
+	}
+
+	g := grouping{
+		state:   state,
+		scope:   scope,
+		level:   scope.levels[len(scope.levels)-1],
+		columns: make(map[int]map[string]bool),
+		exprs:   make(map[string]bool),
+	}
+
+	for _, e := range groupingExprs(sel.GroupClause.Items) {
+		e, ok := selectListExpr(scope, sel, e)
+		if !ok {
+			return nil
+		}
+
+		if colRef, ok := e.(pgnodes.ColumnRef); ok {
+			schema, table, field := splitColumnRef(colRef)
+			if name, ok := field.(pgnodes.String); ok {
+				if index := scope.columnSource(schema, table, name.Str); index >= 0 {
+					if g.columns[index] == nil {
+						g.columns[index] = make(map[string]bool)
+					}
+					g.columns[index][name.Str] = true
+				}
+			}
+		}
+		g.exprs[exprKey(e)] = true
+	}
+
+	for _, t := range targets {
+		errs = append(errs, g.check(fn, t.(pgnodes.ResTarget).Val)...)
+	}
+	errs = append(errs, g.check(fn, sel.HavingClause)...)
+
+	return errs
+}
+
+// selectListExpr returns the select list expression that a position or an
+// output name in GROUP BY, ORDER BY or DISTINCT ON refers to, anything else
+// is returned as is. Names that are columns of the from clause refer to
+// them instead. It's not ok when a * in the select list makes positions
+// impossible to follow.
+func selectListExpr(scope *Scope, sel pgnodes.SelectStmt, n pgnodes.Node) (pgnodes.Node, bool) {
+	targets := sel.TargetList.Items
+
+	if position, _, ok := ordinal(n); ok {
+		if exprs, _ := leafTargets(sel); len(exprs) != len(targets) {
+			return nil, false
+		}
+		if position < 1 || position > len(targets) {
+			return n, true
+		}
+		return targets[position-1].(pgnodes.ResTarget).Val, true
+	}
+
+	colRef, ok := n.(pgnodes.ColumnRef)
+	if !ok {
+		return n, true
+	}
+	schema, table, field := splitColumnRef(colRef)
+	name, ok := field.(pgnodes.String)
+	if !ok || len(schema) != 0 || len(table) != 0 || scope.columnSource("", "", name.Str) >= 0 {
+		return n, true
+	}
+
+	for _, t := range targets {
+		target := t.(pgnodes.ResTarget)
+		if (target.Name != nil && *target.Name == name.Str) ||
+			(target.Name == nil && exprColName(target.Val) == name.Str) {
+			return target.Val, true
+		}
+	}
+
+	return n, true
+}
+
+// selectListKey identifies what an expression in GROUP BY, ORDER BY or
+// DISTINCT ON refers to so that different ways of writing it compare equal.
+// Columns are identified by the index of their table in the scope.
+func selectListKey(scope *Scope, sel pgnodes.SelectStmt, n pgnodes.Node) (string, bool) {
+	n, ok := selectListExpr(scope, sel, n)
+	if !ok {
+		return "", false
+	}
+
+	if colRef, ok := n.(pgnodes.ColumnRef); ok {
+		schema, table, field := splitColumnRef(colRef)
+		if name, ok := field.(pgnodes.String); ok {
+			if index := scope.columnSource(schema, table, name.Str); index >= 0 {
+				return fmt.Sprintf("%d.%s", index, name.Str), true
+			}
+		}
+	}
+
+	return exprKey(n), true
+}
+
+// grouped checks if a column of the table at index is grouped by itself
+// or through its table's primary key.
+func (g grouping) grouped(index int, column string) bool {
+	if g.columns[index][column] {
+		return true
+	}
+
+	t := g.scope.tables[index]
+	if t.PKey == nil || len(t.PKey.Columns) == 0 || len(g.scope.schemaOf(t)) == 0 {
+		return false
+	}
+	for _, c := range t.PKey.Columns {
+		if !g.columns[index][c] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// check finds the columns in an expression that aren't grouped
+func (g grouping) check(fn Call, n pgnodes.Node) (errs []error) {
+	if n == nil || g.exprs[exprKey(n)] || isAggregate(g.state, n) {
+		return nil
+	}
+
+	colRef, ok := n.(pgnodes.ColumnRef)
+	if !ok {
+		for _, child := range exprChildren(n) {
+			errs = append(errs, g.check(fn, child)...)
+		}
+		return errs
+	}
+
+	schema, table, field := splitColumnRef(colRef)
+	switch f := field.(type) {
+	case pgnodes.String:
+		index := g.scope.columnSource(schema, table, f.Str)
+		if index < g.level || g.grouped(index, f.Str) {
+			return nil
+		}
+
+		errs = append(errs, GroupingErr{
+			Table:    g.tableName(index),
+			Column:   f.Str,
+			Location: colRef.Location,
+			Fn:       fn,
+		})
+	case pgnodes.A_Star:
+		// Every column the star expands to has to be grouped
+		start, end := g.level, len(g.scope.tables)
+		if len(table) != 0 {
+			start = g.scope.tableIndex(schema, table)
+			end = start + 1
+		}
+
+		for i := start; i >= g.level && i < end; i++ {
+			for _, c := range g.scope.tables[i].Columns {
+				if g.grouped(i, c.Name) {
+					continue
+				}
+
+				errs = append(errs, GroupingErr{
+					Table:    g.tableName(i),
+					Column:   c.Name,
+					Location: colRef.Location,
+					Fn:       fn,
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+// tableName is the name a table in scope is referred to by
+func (g grouping) tableName(index int) string {
+	if alias := g.scope.aliases[index]; len(alias) != 0 {
+		return alias
+	}
+
+	return g.scope.tables[index].Name
+}
+
+// checkDistinctOn checks the DISTINCT ON expressions are the ones ORDER BY
+// starts with in any order. ORDER BY can have more after them or be shorter
+// in which case the rest are sorted by implicitly.
+func checkDistinctOn(fn Call, scope *Scope, sel pgnodes.SelectStmt) []error {
+	distinct := sel.DistinctClause.Items
+	// A plain DISTINCT is a list with a single nil in it
+	if len(distinct) == 0 || distinct[0] == nil || len(sel.SortClause.Items) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(distinct))
+	unmatched := make(map[string]bool)
+	for i, d := range distinct {
+		key, ok := selectListKey(scope, sel, d)
+		if !ok {
+			return nil
+		}
+		keys[i] = key
+		unmatched[key] = true
+	}
+
+	for _, item := range sel.SortClause.Items {
+		if len(unmatched) == 0 {
+			break
+		}
+
+		key, ok := selectListKey(scope, sel, item.(pgnodes.SortBy).Node)
+		if !ok {
+			return nil
+		}
+
+		matches := false
+		for _, k := range keys {
+			matches = matches || k == key
+		}
+		if matches {
+			delete(unmatched, key)
+			continue
+		}
+
+		// Something else is sorted by before all the DISTINCT ON
+		// expressions were, the first one left over is reported
+		for i, k := range keys {
+			if unmatched[k] {
+				return []error{DistinctOnErr{Location: exprLocation(distinct[i]), Fn: fn}}
+			}
+		}
+	}
+
+	return nil
+}
+
+// exprLocation returns where an expression starts, -1 if it's not known
+func exprLocation(n pgnodes.Node) int {
+	switch node := n.(type) {
+	case pgnodes.ColumnRef:
+		return node.Location
+	case pgnodes.A_Const:
+		return node.Location
+	case pgnodes.ParamRef:
+		return node.Location
+	case pgnodes.FuncCall:
+		return node.Location
+	case pgnodes.TypeCast:
+		return exprLocation(node.Arg)
+	case pgnodes.A_Expr:
+		if loc := exprLocation(node.Lexpr); loc >= 0 {
+			return loc
+		}
+		return node.Location
+	}
+
+	return -1
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// OperatorErr occurs when an operator is applied to a type it doesn't
+// exist for like a json operator on a text column.
+type OperatorErr struct {
+	Operator string
+	// Operand is the column the operator was applied to, it's empty when
+	// it was some other expression
+	Operand string
+	DBType  string
+
+	Location int
+
+	Fn Call
+}
+
+func (o OperatorErr) Error() string {
+	operand := "an expression"
+	if len(o.Operand) != 0 {
+		operand = strconv.Quote(o.Operand)
+	}
+
+	return fmt.Sprintf("%s:%d:%d operator %s (pos %d) cannot be applied to %s of type %q",
+		o.Fn.Pos.Filename,
+		o.Fn.Pos.Line,
+		o.Fn.Pos.Column,
+		o.Operator,
+		o.Location,
+		operand,
+		o.DBType,
+	)
+}
+
+func (o OperatorErr) call() Call {
+	return o.Fn
+}
+
+// jsonOps are the operators that only exist for json types and the types
+// their left side can be
+var jsonOps = map[string][]string{
+	"->":  {"json", "jsonb"},
+	"->>": {"json", "jsonb"},
+	"#>":  {"json", "jsonb"},
+	"#>>": {"json", "jsonb"},
+	"?":   {"jsonb"},
+	"?|":  {"jsonb"},
+	"?&":  {"jsonb"},
+	"#-":  {"jsonb"},
+}
+
+// jsonOpArgs are the db types of the right side of json operators, -> and
+// ->> aren't here because they take either a key or an index.
+var jsonOpArgs = map[string]string{
+	"?":   "text",
+	"?|":  "ARRAYtext",
+	"?&":  "ARRAYtext",
+	"#>":  "ARRAYtext",
+	"#>>": "ARRAYtext",
+	"#-":  "ARRAYtext",
+}
+
+// checkJSONOperator checks a json operator is applied to a json value.
+// Types that aren't known are let through since extensions like hstore
+// define some of the same operators.
+func checkJSONOperator(fn Call, scope *Scope, expr pgnodes.A_Expr) []error {
+	if expr.Kind != pgnodes.AEXPR_OP || expr.Lexpr == nil {
+		return nil
+	}
+
+	op := operatorName(expr)
+	left := exprType(scope, expr.Lexpr)
+	if left == nil || len(dbTypeCategory(left.DBType)) == 0 {
+		return nil
+	}
+
+	switch op {
+	case "@>", "<@":
+		// Containment also exists for arrays and geometric types
+		switch dbTypeCategory(left.DBType) {
+		case "A", "G":
+			return nil
+		}
+		if left.DBType == "jsonb" {
+			return nil
+		}
+	default:
+		types, ok := jsonOps[op]
+		if !ok {
+			return nil
+		}
+		for _, t := range types {
+			if t == left.DBType {
+				return nil
+			}
+		}
+	}
+
+	operand, _ := comparedColumn(scope, expr.Lexpr)
+	return []error{OperatorErr{
+		Operator: op,
+		Operand:  operand,
+		DBType:   left.DBType,
+		Location: expr.Location,
+		Fn:       fn,
+	}}
+}
+
+// inferJSONParams infers the types of parameters used as the right side of
+// json operators, the left side is always the json value.
+func inferJSONParams(scope *Scope, expr pgnodes.A_Expr) {
+	op := operatorName(expr)
+	if dbType, ok := jsonOpArgs[op]; ok {
+		expectDBType(scope, expr.Rexpr, dbType, op, coerceImplicit)
+		return
+	}
+
+	if op != "@>" && op != "<@" {
+		return
+	}
+	if left := exprType(scope, expr.Lexpr); left != nil && left.DBType == "jsonb" {
+		expectSameType(scope, expr.Lexpr, expr.Rexpr)
+	}
+}
+
+// jsonPathName describes a json operator expression like data->>'age' so
+// that errors about its result can say where it came from.
+func jsonPathName(expr pgnodes.A_Expr) string {
+	var left string
+	switch l := expr.Lexpr.(type) {
+	case pgnodes.ColumnRef:
+		_, _, field := splitColumnRef(l)
+		if str, ok := field.(pgnodes.String); ok {
+			left = str.Str
+		}
+	case pgnodes.A_Expr:
+		left = jsonPathName(l)
+	}
+	if len(left) == 0 {
+		left = "..."
+	}
+
+	right := "..."
+	if c, ok := expr.Rexpr.(pgnodes.A_Const); ok {
+		switch val := c.Val.(type) {
+		case pgnodes.String:
+			right = "'" + val.Str + "'"
+		case pgnodes.Integer:
+			right = strconv.FormatInt(val.Ival, 10)
+		}
+	}
+
+	return left + operatorName(expr) + right
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// Coercion contexts, these are how postgres converts a value to the type
+// it's expected to be. Function arguments are resolved against overloads
+// which aren't known here so literals given to them aren't checked.
+const (
+	// coerceImplicit is used for comparisons and other operators
+	coerceImplicit = iota
+	// coerceAssignment is used for values stored in a column
+	coerceAssignment
+	// coerceExplicit is used for casts
+	coerceExplicit
+	coerceFunction
+)
+
+// LiteralErr occurs when a constant written in the statement can't be used
+// as the type of the column it's compared to or stored in.
+type LiteralErr struct {
+	Schema string
+	Table  string
+	Column string
+
+	DBType string
+	// Literal is the constant as it was written and LiteralType is the
+	// type postgres gives it, string literals are "unknown".
+	Literal     string
+	LiteralType string
+
+	Location int
+
+	Fn Call
+}
+
+func (l LiteralErr) Error() string {
+	ident := errIdent(l.Schema, l.Table, l.Column)
+
+	problem := fmt.Sprintf("is of type %s", l.LiteralType)
+	if l.LiteralType == "unknown" {
+		problem = fmt.Sprintf("is not a valid %s", l.DBType)
+	}
+
+	return fmt.Sprintf("%s:%d:%d literal mismatch, %q has type %q but the literal %s (pos %d) %s",
+		l.Fn.Pos.Filename,
+		l.Fn.Pos.Line,
+		l.Fn.Pos.Column,
+		ident,
+		l.DBType,
+		l.Literal,
+		l.Location,
+		problem,
+	)
+}
+
+func (l LiteralErr) call() Call {
+	return l.Fn
+}
+
+// literalUse is a constant used where a type was expected, the use is
+// what it was expected to be.
+type literalUse struct {
+	use   paramUse
+	value pgnodes.A_Const
+}
+
+// checkLiterals checks the constants used in a statement against the types
+// they were expected to be.
+func checkLiterals(fn Call, literals []literalUse) (errs []error) {
+	for _, l := range literals {
+		if l.use.coercion == coerceFunction {
+			continue
+		}
+
+		dbType := l.use.col.DBType
+		if str, ok := l.value.Val.(pgnodes.String); ok && !enumAllows(dbType, str.Str) {
+			name, values, _ := enumValues(dbType)
+			errs = append(errs, EnumErr{
+				Schema:   l.use.schema,
+				Table:    l.use.table,
+				Column:   l.use.column,
+				Enum:     name,
+				Values:   values,
+				Value:    str.Str,
+				Location: l.value.Location,
+				Fn:       fn,
+			})
+			continue
+		}
+
+		literal, literalType, ok := literalCoerces(l.value, dbType, l.use.coercion)
+		if ok {
+			continue
+		}
+
+		errs = append(errs, LiteralErr{
+			Schema:      l.use.schema,
+			Table:       l.use.table,
+			Column:      l.use.column,
+			DBType:      dbType,
+			Literal:     literal,
+			LiteralType: literalType,
+			Location:    l.value.Location,
+			Fn:          fn,
+		})
+	}
+
+	return errs
+}
+
+// literalCoerces checks if a constant can be converted to the db type in
+// the coercion context. It also returns the constant as it's written and
+// the type postgres gives it.
+func literalCoerces(val pgnodes.A_Const, dbType string, coercion int) (literal, literalType string, ok bool) {
+	switch v := val.Val.(type) {
+	case pgnodes.String:
+		// String literals are of unknown type until they're used and are
+		// then parsed by the type's input function
+		literal = "'" + strings.Replace(v.Str, "'", "''", -1) + "'"
+		return literal, "unknown", validInput(dbType, v.Str)
+	case pgnodes.Integer:
+		literal, literalType = strconv.FormatInt(v.Ival, 10), "integer"
+		if v.Ival > math.MaxInt32 || v.Ival < math.MinInt32 {
+			literalType = "bigint"
+		}
+	case pgnodes.Float:
+		literal, literalType = v.Str, "numeric"
+	default:
+		return "", "", true
+	}
+
+	// Numbers convert to every numeric type, money can only be assigned
+	// or cast to
+	cat := dbTypeCategory(dbType)
+	switch {
+	case len(cat) == 0:
+		return literal, literalType, true
+	case dbType == "money":
+		return literal, literalType, coercion != coerceImplicit
+	case cat == "N":
+		return literal, literalType, true
+	}
+
+	switch coercion {
+	case coerceAssignment:
+		// Anything can be stored in a string type as its text
+		return literal, literalType, cat == "S"
+	case coerceExplicit:
+		switch cat {
+		case "S", "B", "V":
+			return literal, literalType, true
+		}
+	}
+
+	return literal, literalType, false
+}
+
+var (
+	rgxDigit      = regexp.MustCompile(`[0-9]`)
+	rgxCIDRPrefix = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,3}(/[0-9]+)?$`)
+)
+
+// validInput checks if a string is accepted by the input function of a db
+// type. Only types whose input is easily checked are looked at, the rest
+// are assumed valid.
+func validInput(dbType, s string) bool {
+	if strings.HasPrefix(dbType, "ARRAY") {
+		s = strings.TrimSpace(s)
+		return strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")
+	}
+
+	trimmed := strings.TrimSpace(s)
+	switch dbType {
+	case "smallint":
+		_, err := strconv.ParseInt(trimmed, 10, 16)
+		return err == nil
+	case "integer":
+		_, err := strconv.ParseInt(trimmed, 10, 32)
+		return err == nil
+	case "bigint":
+		_, err := strconv.ParseInt(trimmed, 10, 64)
+		return err == nil
+	case "real", "double precision", "numeric":
+		_, err := strconv.ParseFloat(trimmed, 64)
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return true
+		}
+		return err == nil
+	case "boolean":
+		return validBool(trimmed)
+	case "uuid":
+		return validUUID(trimmed)
+	case "json", "jsonb":
+		return json.Valid([]byte(s))
+	case "inet", "cidr":
+		if net.ParseIP(trimmed) != nil {
+			return true
+		}
+		if _, _, err := net.ParseCIDR(trimmed); err == nil {
+			return true
+		}
+		return dbType == "cidr" && rgxCIDRPrefix.MatchString(trimmed)
+	case "date", "timestamp without time zone", "timestamp with time zone",
+		"time without time zone", "time with time zone":
+		// Dates have too many formats to check, but they all need digits
+		// unless they're one of the special values
+		switch strings.ToLower(trimmed) {
+		case "now", "today", "tomorrow", "yesterday", "epoch", "infinity",
+			"-infinity", "allballs":
+			return true
+		}
+		return rgxDigit.MatchString(trimmed)
+	case "interval":
+		return rgxDigit.MatchString(trimmed)
+	}
+
+	return true
+}
+
+// validBool checks a string like boolin does, any unique prefix of true,
+// false, yes, no, on or off is accepted.
+func validBool(s string) bool {
+	s = strings.ToLower(s)
+	if s == "1" || s == "0" {
+		return true
+	}
+	// o could be on or off
+	if len(s) == 0 || s == "o" {
+		return false
+	}
+
+	for _, word := range []string{"true", "false", "yes", "no", "on", "off"} {
+		if strings.HasPrefix(word, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validUUID checks a string like uuid_in, it's 32 hex digits that may be
+// surrounded by braces and have a hyphen after any group of four.
+func validUUID(s string) bool {
+	if strings.HasPrefix(s, "{") {
+		if !strings.HasSuffix(s, "}") {
+			return false
+		}
+		s = s[1 : len(s)-1]
+	}
+
+	digits := 0
+	for i, r := range s {
+		switch {
+		case r == '-':
+			if digits == 0 || digits%4 != 0 || i == len(s)-1 {
+				return false
+			}
+			if i > 0 && s[i-1] == '-' {
+				return false
+			}
+		case (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F'):
+			digits++
+		default:
+			return false
+		}
+	}
+
+	return digits == 32
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// checkLocking checks that the relations named in FOR UPDATE OF and the
+// other locking clauses are in the select's FROM clause. Like postgres a
+// table that has an alias can only be named by its alias.
+func checkLocking(fn Call, scope *Scope, sel pgnodes.SelectStmt) (errs []error) {
+	level := scope.levels[len(scope.levels)-1]
+
+	for _, item := range sel.LockingClause.Items {
+		locking, ok := item.(pgnodes.LockingClause)
+		if !ok {
+			continue
+		}
+
+		for _, rel := range locking.LockedRels.Items {
+			rv, ok := rel.(pgnodes.RangeVar)
+			if !ok {
+				continue
+			}
+
+			var schema string
+			if rv.Schemaname != nil {
+				schema = *rv.Schemaname
+			}
+
+			found := false
+			for i := level; i < len(scope.tables) && !found; i++ {
+				if alias := scope.aliases[i]; len(alias) != 0 {
+					found = len(schema) == 0 && alias == *rv.Relname
+					continue
+				}
+
+				t := scope.tables[i]
+				found = t.Name == *rv.Relname && (len(schema) == 0 || scope.schemaOf(t) == schema)
+			}
+			if found {
+				continue
+			}
+
+			errs = append(errs, IdentErr{
+				Schema:   schema,
+				Table:    *rv.Relname,
+				Location: rv.Location,
+				Fn:       fn,
+			})
+		}
+	}
+
+	return errs
+}
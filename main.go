@@ -30,9 +30,27 @@ var (
 
 // State of the application
 type State struct {
-	DBInfo      *drivers.DBInfo
-	Imports     importers.Collection
+	DBInfo  *drivers.DBInfo
+	Imports importers.Collection
+	// TypeAliases are Go types that are acceptable for a db type or a
+	// table.column on top of the ones that normally are
 	TypeAliases map[string][]string
+	// Functions are user defined functions
+	Functions map[string]pgFunction
+	// ExtraFunctions are the names of functions that exist but that we
+	// have no information about
+	ExtraFunctions []string
+	// SearchPath is the schemas unqualified tables are looked up in, the
+	// driver's schema is used if it's empty
+	SearchPath []string
+	// Relations are the kinds of the relations that aren't plain tables
+	// keyed by schema.name
+	Relations map[string]relation
+	// Nullability is how strictly NOT NULL columns are checked
+	Nullability int
+	// Immutable are columns (table.column or schema.table.column) that
+	// must never be updated
+	Immutable []string
 }
 
 func main() {
@@ -95,9 +113,49 @@ func main() {
 		os.Exit(1)
 	}
 
+	boilcheckCfg, err := loadBoilcheckConfig(flagConfig)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "failed to read boilcheck config:", err)
+		os.Exit(1)
+	}
+
+	nullability, err := parseNullability(boilcheckCfg.Boilcheck.Nullability)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "failed to read boilcheck config:", err)
+		os.Exit(1)
+	}
+
+	migrations, migrationWarns, err := loadMigrations(boilcheckCfg.Boilcheck.Migrations)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, w := range migrationWarns {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+
+	// Views and the like come from the migrations, they have to be added
+	// before anything else looks at the tables
+	relations, viewWarns := addRelations(dbInfo, boilcheckCfg.Boilcheck.SearchPath, migrations)
+	for _, w := range viewWarns {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	migrations.markIdentities(dbInfo, boilcheckCfg.Boilcheck.SearchPath)
+
+	// Columns get the types sqlboiler replaces theirs with so they're
+	// checked against what the generated models use
+	replaced := replaceTypes(boilcheckCfg, dbInfo)
+
 	state := &State{
-		DBInfo:  dbInfo,
-		Imports: imports,
+		DBInfo:         dbInfo,
+		Imports:        imports,
+		TypeAliases:    typeAliases(boilcheckCfg, replaced),
+		Functions:      migrations.functions,
+		ExtraFunctions: boilcheckCfg.Boilcheck.Functions,
+		SearchPath:     boilcheckCfg.Boilcheck.SearchPath,
+		Relations:      relations,
+		Nullability:    nullability,
+		Immutable:      boilcheckCfg.Boilcheck.Immutable,
 	}
 
 	calls, warns := findTaggedCalls(pkgs)
@@ -158,29 +216,21 @@ func main() {
 				continue
 			}
 
-			switch e := err.(type) {
-			case IdentErr:
-				if e.Fn.Package == pkg.PkgPath {
-					printPkg()
-					printed[i] = true
-					fmt.Println(e)
-				}
-			case TypeErr:
-				if e.Fn.Package == pkg.PkgPath {
-					printPkg()
-					printed[i] = true
-					fmt.Println(e)
-				}
-			default:
-				printPkg()
-				printed[i] = true
-				fmt.Println(e)
+			if e, ok := err.(callErr); ok && e.call().Package != pkg.PkgPath {
+				continue
 			}
+
+			printPkg()
+			printed[i] = true
+			fmt.Println(err)
 		}
 	}
 
-	if len(errs) != 0 {
-		os.Exit(1)
+	// Warnings alone don't fail the check
+	for _, err := range errs {
+		if _, ok := err.(SchemaWarn); !ok {
+			os.Exit(1)
+		}
 	}
 }
 
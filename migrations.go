@@ -0,0 +1,636 @@
+package main
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/friendsofgo/errors"
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgquery "github.com/lfittl/pg_query_go"
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// migrationInfo is what we know about the database from reading the sql
+// files that create it.
+type migrationInfo struct {
+	// functions are the user defined functions by name
+	functions map[string]pgFunction
+	// relations are the views and tables sqlboiler doesn't load in the
+	// order they were created
+	relations []migrationRelation
+	// insteadOf are the statements that INSTEAD OF triggers or rules
+	// handle for a relation (schema.name)
+	insteadOf map[string][]string
+	// identities are the identity columns (schema.table.column) and if
+	// they're GENERATED ALWAYS, which the database info can't tell
+	identities map[string]bool
+}
+
+// migrationRelation is a relation created by a migration. Views have the
+// query that defines them, tables have their columns.
+type migrationRelation struct {
+	schema string
+	name   string
+	kind   int
+	// pos is where the relation is created, problems with a view's query
+	// are reported there
+	pos token.Position
+	// renames are the columns renamed (old, new) after it was created
+	renames [][2]string
+
+	query   pgnodes.Node
+	aliases pgnodes.List
+	columns []drivers.Column
+	pkey    *drivers.PrimaryKey
+}
+
+// Parameter modes as the parser gives them, pg_query_go's constants for
+// these don't match the characters postgres uses.
+const (
+	paramModeOut      pgnodes.FunctionParameterMode = 'o'
+	paramModeVariadic pgnodes.FunctionParameterMode = 'v'
+	paramModeTable    pgnodes.FunctionParameterMode = 't'
+)
+
+// loadMigrations reads every .sql file in the directories in name order.
+// Files that can't be parsed are skipped and returned as warnings.
+func loadMigrations(dirs []string) (*migrationInfo, []error, error) {
+	info := &migrationInfo{
+		functions:  make(map[string]pgFunction),
+		insteadOf:  make(map[string][]string),
+		identities: make(map[string]bool),
+	}
+
+	var warns []error
+	for _, dir := range dirs {
+		var files []string
+		err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() && strings.HasSuffix(path, ".sql") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read migrations in %s", dir)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			sql, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to read migration %s", file)
+			}
+
+			tree, err := pgquery.Parse(string(sql))
+			if err != nil {
+				warns = append(warns, errors.Wrapf(err, "failed to parse migration %s", file))
+				continue
+			}
+
+			for _, stmt := range tree.Statements {
+				pos := token.Position{Filename: file, Line: 1, Column: 1}
+				if raw, ok := stmt.(pgnodes.RawStmt); ok {
+					pos = stmtPosition(file, string(sql), raw.StmtLocation)
+					stmt = raw.Stmt
+				}
+				info.add(pos, stmt)
+			}
+		}
+	}
+
+	return info, warns, nil
+}
+
+// stmtPosition finds the line and column a statement starts at in a file,
+// the parser counts the whitespace after the previous statement as part of
+// it so that's skipped.
+func stmtPosition(file, sql string, location int) token.Position {
+	for location < len(sql) && unicode.IsSpace(rune(sql[location])) {
+		location++
+	}
+
+	before := sql[:location]
+	return token.Position{
+		Filename: file,
+		Line:     strings.Count(before, "\n") + 1,
+		Column:   location - strings.LastIndex(before, "\n"),
+	}
+}
+
+// add records what a statement creates, changes or drops
+func (m *migrationInfo) add(pos token.Position, stmt pgnodes.Node) {
+	switch node := stmt.(type) {
+	case pgnodes.CreateFunctionStmt:
+		name, f := createdFunction(node)
+		m.addFunction(name, f)
+	case pgnodes.DefineStmt:
+		if node.Kind != pgnodes.OBJECT_AGGREGATE {
+			return
+		}
+
+		name, f := createdAggregate(node)
+		m.addFunction(name, f)
+	case pgnodes.ViewStmt:
+		m.addRelation(*node.View, relView, migrationRelation{
+			pos:     pos,
+			query:   node.Query,
+			aliases: node.Aliases,
+		})
+	case pgnodes.CreateTableAsStmt:
+		if node.Relkind != pgnodes.OBJECT_MATVIEW {
+			return
+		}
+
+		m.addRelation(*node.Into.Rel, relMatView, migrationRelation{
+			pos:     pos,
+			query:   node.Query,
+			aliases: node.Into.ColNames,
+		})
+	case pgnodes.CreateForeignTableStmt:
+		m.addRelation(*node.Base.Relation, relForeignTable, migrationRelation{
+			pos:     pos,
+			columns: createdColumns(node.Base.TableElts),
+		})
+	case pgnodes.CreateStmt:
+		for _, elt := range node.TableElts.Items {
+			if def, ok := elt.(pgnodes.ColumnDef); ok {
+				m.addIdentity(*node.Relation, def)
+			}
+		}
+
+		// Partitioned tables are the only ones sqlboiler doesn't load
+		if node.Partspec == nil {
+			return
+		}
+
+		m.addRelation(*node.Relation, relPartitioned, migrationRelation{
+			pos:     pos,
+			columns: createdColumns(node.TableElts),
+			pkey:    createdPKey(node.TableElts),
+		})
+	case pgnodes.AlterTableStmt:
+		if node.Relation == nil {
+			return
+		}
+
+		for _, c := range node.Cmds.Items {
+			cmd, ok := c.(pgnodes.AlterTableCmd)
+			if !ok {
+				continue
+			}
+
+			if cmd.Subtype == pgnodes.AT_AddColumn {
+				if def, ok := cmd.Def.(pgnodes.ColumnDef); ok {
+					m.addIdentity(*node.Relation, def)
+				}
+				continue
+			}
+
+			if cmd.Name == nil {
+				continue
+			}
+
+			key := rangeVarKey(*node.Relation) + "." + *cmd.Name
+			switch cmd.Subtype {
+			case pgnodes.AT_AddIdentity:
+				if con, ok := cmd.Def.(pgnodes.Constraint); ok {
+					m.identities[key] = con.GeneratedWhen == 'a'
+				}
+			case pgnodes.AT_SetIdentity:
+				opts, _ := cmd.Def.(pgnodes.List)
+				for _, o := range opts.Items {
+					opt, ok := o.(pgnodes.DefElem)
+					if !ok || opt.Defname == nil || *opt.Defname != "generated" {
+						continue
+					}
+					if when, ok := opt.Arg.(pgnodes.Integer); ok {
+						m.identities[key] = when.Ival == 'a'
+					}
+				}
+			case pgnodes.AT_DropIdentity:
+				delete(m.identities, key)
+			}
+		}
+	case pgnodes.DropStmt:
+		switch node.RemoveType {
+		case pgnodes.OBJECT_TABLE, pgnodes.OBJECT_VIEW, pgnodes.OBJECT_MATVIEW, pgnodes.OBJECT_FOREIGN_TABLE:
+		default:
+			return
+		}
+
+		for _, obj := range node.Objects.Items {
+			names, ok := obj.(pgnodes.List)
+			if !ok {
+				continue
+			}
+			if rv, ok := namesRangeVar(names); ok {
+				m.dropRelation(rv)
+			}
+		}
+	case pgnodes.RenameStmt:
+		if node.Relation == nil || node.Newname == nil {
+			return
+		}
+
+		switch node.RenameType {
+		case pgnodes.OBJECT_TABLE, pgnodes.OBJECT_VIEW, pgnodes.OBJECT_MATVIEW, pgnodes.OBJECT_FOREIGN_TABLE:
+			m.renameRelation(*node.Relation, *node.Newname)
+		case pgnodes.OBJECT_COLUMN:
+			if node.Subname != nil {
+				m.renameColumn(*node.Relation, *node.Subname, *node.Newname)
+			}
+		}
+	case pgnodes.CreateTrigStmt:
+		if node.Timing&triggerTypeInstead == 0 {
+			return
+		}
+
+		key := rangeVarKey(*node.Relation)
+		if node.Events&triggerTypeInsert != 0 {
+			m.insteadOf[key] = append(m.insteadOf[key], "INSERT")
+		}
+		if node.Events&triggerTypeUpdate != 0 {
+			m.insteadOf[key] = append(m.insteadOf[key], "UPDATE")
+		}
+		if node.Events&triggerTypeDelete != 0 {
+			m.insteadOf[key] = append(m.insteadOf[key], "DELETE")
+		}
+	case pgnodes.RuleStmt:
+		if !node.Instead {
+			return
+		}
+
+		stmt, ok := map[pgnodes.CmdType]string{
+			pgnodes.CMD_INSERT: "INSERT",
+			pgnodes.CMD_UPDATE: "UPDATE",
+			pgnodes.CMD_DELETE: "DELETE",
+		}[node.Event]
+		if ok {
+			key := rangeVarKey(*node.Relation)
+			m.insteadOf[key] = append(m.insteadOf[key], stmt)
+		}
+	}
+}
+
+// addIdentity records if a column definition is an identity column and
+// whether it's GENERATED ALWAYS
+func (m *migrationInfo) addIdentity(rv pgnodes.RangeVar, def pgnodes.ColumnDef) {
+	if def.Colname == nil {
+		return
+	}
+
+	for _, c := range def.Constraints.Items {
+		if con, ok := c.(pgnodes.Constraint); ok && con.Contype == pgnodes.CONSTR_IDENTITY {
+			m.identities[rangeVarKey(rv)+"."+*def.Colname] = con.GeneratedWhen == 'a'
+		}
+	}
+}
+
+// markIdentities gives the identity columns the migrations created as
+// GENERATED ALWAYS the identityAlways default.
+func (m *migrationInfo) markIdentities(info *drivers.DBInfo, searchPath []string) {
+	scope := NewScope(info)
+	scope.searchPath = searchPath
+
+	for key, always := range m.identities {
+		if !always {
+			continue
+		}
+
+		parts := strings.SplitN(key, ".", 3)
+		schema := parts[0]
+		if len(schema) == 0 {
+			schema = scope.schemas()[0]
+		}
+
+		for i := range info.Tables {
+			t := &info.Tables[i]
+			if t.Name != parts[1] || scope.schemaOf(t) != schema {
+				continue
+			}
+			if index := columnIndex(t, parts[2]); index >= 0 {
+				t.Columns[index].Default = identityAlways
+			}
+		}
+	}
+}
+
+// Trigger timing and event bits from pg_trigger.h
+const (
+	triggerTypeInsert  = 1 << 2
+	triggerTypeDelete  = 1 << 3
+	triggerTypeUpdate  = 1 << 4
+	triggerTypeInstead = 1 << 6
+)
+
+// addRelation adds a relation, the last definition of one wins like it
+// would for CREATE OR REPLACE.
+func (m *migrationInfo) addRelation(rv pgnodes.RangeVar, kind int, rel migrationRelation) {
+	if rv.Schemaname != nil {
+		rel.schema = *rv.Schemaname
+	}
+	rel.name = *rv.Relname
+	rel.kind = kind
+
+	if i := m.relationIndex(rv); i >= 0 {
+		m.relations = append(m.relations[:i], m.relations[i+1:]...)
+	}
+	m.relations = append(m.relations, rel)
+}
+
+// dropRelation removes a relation and everything known about it
+func (m *migrationInfo) dropRelation(rv pgnodes.RangeVar) {
+	if i := m.relationIndex(rv); i >= 0 {
+		m.relations = append(m.relations[:i], m.relations[i+1:]...)
+	}
+
+	key := rangeVarKey(rv)
+	delete(m.insteadOf, key)
+	for k := range m.identities {
+		if strings.HasPrefix(k, key+".") {
+			delete(m.identities, k)
+		}
+	}
+}
+
+// renameRelation renames a relation and moves everything known about it to
+// the new name.
+func (m *migrationInfo) renameRelation(rv pgnodes.RangeVar, name string) {
+	if i := m.relationIndex(rv); i >= 0 {
+		m.relations[i].name = name
+	}
+
+	renamed := rv
+	renamed.Relname = &name
+	key, newKey := rangeVarKey(rv), rangeVarKey(renamed)
+	if stmts, ok := m.insteadOf[key]; ok {
+		delete(m.insteadOf, key)
+		m.insteadOf[newKey] = stmts
+	}
+	for k, always := range m.identities {
+		if strings.HasPrefix(k, key+".") {
+			delete(m.identities, k)
+			m.identities[newKey+strings.TrimPrefix(k, key)] = always
+		}
+	}
+}
+
+// renameColumn renames a column of a relation
+func (m *migrationInfo) renameColumn(rv pgnodes.RangeVar, column, name string) {
+	if i := m.relationIndex(rv); i >= 0 {
+		m.relations[i].renames = append(m.relations[i].renames, [2]string{column, name})
+	}
+
+	key := rangeVarKey(rv) + "."
+	if always, ok := m.identities[key+column]; ok {
+		delete(m.identities, key+column)
+		m.identities[key+name] = always
+	}
+}
+
+// relationIndex finds a relation the migrations created, -1 if they didn't
+func (m *migrationInfo) relationIndex(rv pgnodes.RangeVar) int {
+	var schema string
+	if rv.Schemaname != nil {
+		schema = *rv.Schemaname
+	}
+
+	for i, r := range m.relations {
+		if r.schema == schema && r.name == *rv.Relname {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// rangeVarKey is the schema.name of a relation, the schema is left empty
+// when it wasn't given.
+func rangeVarKey(rv pgnodes.RangeVar) string {
+	var schema string
+	if rv.Schemaname != nil {
+		schema = *rv.Schemaname
+	}
+
+	return schema + "." + *rv.Relname
+}
+
+// columnDefault is the default of columns created by DDL, the expression
+// isn't kept only that there is one.
+const columnDefault = "DEFAULT"
+
+// serialTypes are the types that create a sequence for a column's default
+var serialTypes = map[string]bool{
+	"smallserial": true, "serial2": true,
+	"serial": true, "serial4": true,
+	"bigserial": true, "serial8": true,
+}
+
+// typeNameName returns the unqualified name of a type
+func typeNameName(tn pgnodes.TypeName) string {
+	if len(tn.Names.Items) == 0 {
+		return ""
+	}
+
+	return tn.Names.Items[len(tn.Names.Items)-1].(pgnodes.String).Str
+}
+
+// createdColumns returns the columns defined in a CREATE TABLE
+func createdColumns(elts pgnodes.List) []drivers.Column {
+	var cols []drivers.Column
+	notNull := make(map[string]bool)
+	unique := make(map[string]bool)
+
+	for _, elt := range elts.Items {
+		switch e := elt.(type) {
+		case pgnodes.ColumnDef:
+			if e.Colname == nil || e.TypeName == nil {
+				continue
+			}
+
+			dbType := typeNameDBType(*e.TypeName)
+			col := *pseudoColumn(*e.Colname, dbType, true)
+			if serialTypes[typeNameName(*e.TypeName)] {
+				col.Default = columnDefault
+				notNull[col.Name] = true
+			}
+			for _, c := range e.Constraints.Items {
+				switch c.(pgnodes.Constraint).Contype {
+				case pgnodes.CONSTR_DEFAULT:
+					col.Default = columnDefault
+				case pgnodes.CONSTR_IDENTITY:
+					// Only GENERATED ALWAYS refuses values
+					col.Default = columnDefault
+					if c.(pgnodes.Constraint).GeneratedWhen == 'a' {
+						col.Default = identityAlways
+					}
+					notNull[col.Name] = true
+				case pgnodes.CONSTR_NOTNULL:
+					notNull[col.Name] = true
+				case pgnodes.CONSTR_PRIMARY:
+					notNull[col.Name] = true
+					unique[col.Name] = true
+				case pgnodes.CONSTR_UNIQUE:
+					unique[col.Name] = true
+				}
+			}
+			cols = append(cols, col)
+		case pgnodes.Constraint:
+			// Table constraints name the columns they're on
+			for _, k := range e.Keys.Items {
+				name := k.(pgnodes.String).Str
+				switch e.Contype {
+				case pgnodes.CONSTR_PRIMARY:
+					notNull[name] = true
+					unique[name] = true
+				case pgnodes.CONSTR_UNIQUE:
+					if len(e.Keys.Items) == 1 {
+						unique[name] = true
+					}
+				}
+			}
+		}
+	}
+
+	for i := range cols {
+		c := &cols[i]
+		c.Unique = unique[c.Name]
+		if notNull[c.Name] {
+			c.Nullable = false
+			c.Type = translateDBType(c.DBType, false)
+		}
+	}
+
+	return cols
+}
+
+// createdPKey returns the primary key defined in a CREATE TABLE
+func createdPKey(elts pgnodes.List) *drivers.PrimaryKey {
+	for _, elt := range elts.Items {
+		switch e := elt.(type) {
+		case pgnodes.ColumnDef:
+			if e.Colname == nil {
+				continue
+			}
+			for _, c := range e.Constraints.Items {
+				if c.(pgnodes.Constraint).Contype == pgnodes.CONSTR_PRIMARY {
+					return &drivers.PrimaryKey{Columns: []string{*e.Colname}}
+				}
+			}
+		case pgnodes.Constraint:
+			if e.Contype != pgnodes.CONSTR_PRIMARY {
+				continue
+			}
+			pkey := &drivers.PrimaryKey{}
+			for _, k := range e.Keys.Items {
+				pkey.Columns = append(pkey.Columns, k.(pgnodes.String).Str)
+			}
+			return pkey
+		}
+	}
+
+	return nil
+}
+
+// addFunction adds a function, overloads are merged so that every number
+// of arguments that any of them take is allowed.
+func (m *migrationInfo) addFunction(name string, f pgFunction) {
+	existing, ok := m.functions[name]
+	if !ok {
+		m.functions[name] = f
+		return
+	}
+
+	if f.min < existing.min {
+		existing.min = f.min
+	}
+	if existing.max >= 0 && (f.max < 0 || f.max > existing.max) {
+		existing.max = f.max
+	}
+	// The return type can differ between overloads
+	if existing.ret != f.ret {
+		existing.ret = ""
+	}
+	existing.args = nil
+	existing.aggregate = existing.aggregate || f.aggregate
+
+	m.functions[name] = existing
+}
+
+// createdFunction returns the function a CREATE FUNCTION creates
+func createdFunction(stmt pgnodes.CreateFunctionStmt) (string, pgFunction) {
+	var f pgFunction
+	for _, p := range stmt.Parameters.Items {
+		param := p.(pgnodes.FunctionParameter)
+		switch param.Mode {
+		case paramModeOut, paramModeTable:
+			continue
+		case paramModeVariadic:
+			f.max = -1
+		}
+
+		if param.Defexpr == nil {
+			f.min++
+		}
+		if f.max >= 0 {
+			f.max++
+		}
+
+		var argType string
+		if param.ArgType != nil {
+			argType = typeNameDBType(*param.ArgType)
+		}
+		f.args = append(f.args, argType)
+	}
+
+	if stmt.ReturnType != nil && !stmt.ReturnType.Setof {
+		f.ret = typeNameDBType(*stmt.ReturnType)
+	}
+
+	return stringListLast(stmt.Funcname), f
+}
+
+// createdAggregate returns the aggregate a CREATE AGGREGATE creates
+func createdAggregate(stmt pgnodes.DefineStmt) (string, pgFunction) {
+	f := pgFunction{aggregate: true}
+
+	// The arguments are a list of parameters followed by the number of
+	// direct arguments, the old style syntax always has one argument
+	switch {
+	case stmt.Oldstyle:
+		f.min, f.max = 1, 1
+	case len(stmt.Args.Items) != 0:
+		if params, ok := stmt.Args.Items[0].(pgnodes.List); ok {
+			for _, p := range params.Items {
+				param := p.(pgnodes.FunctionParameter)
+				if param.Mode == paramModeVariadic {
+					f.max = -1
+				}
+				f.min++
+				if f.max >= 0 {
+					f.max++
+				}
+			}
+		}
+	}
+
+	return stringListLast(stmt.Defnames), f
+}
+
+// stringListLast returns the lowercased last element of a qualified name
+func stringListLast(names pgnodes.List) string {
+	if len(names.Items) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(names.Items[len(names.Items)-1].(pgnodes.String).Str)
+}
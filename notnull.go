@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// Kinds of not null errors
+const (
+	NullMissing = iota
+	NullLiteral
+	NullParam
+)
+
+// Levels of not null checking, the default reports statements that will
+// always fail, strict also reports Go types that can be nil being stored in
+// NOT NULL columns.
+const (
+	nullabilityDefault = iota
+	nullabilityOff
+	nullabilityStrict
+)
+
+// NullErr occurs when a NOT NULL column would be set to NULL
+type NullErr struct {
+	// Kind is NullMissing/Literal/Param
+	Kind int
+
+	Schema string
+	Table  string
+	Column string
+
+	// Parameter and CallType are the Go argument for NullParam
+	Parameter int
+	CallType  string
+
+	Location int
+
+	Fn Call
+}
+
+func (n NullErr) Error() string {
+	ident := errIdent(n.Schema, n.Table, n.Column)
+
+	var errMsg string
+	switch n.Kind {
+	case NullMissing:
+		errMsg = fmt.Sprintf("insert leaves out NOT NULL column %q which has no default (pos %d)",
+			ident, n.Location)
+	case NullLiteral:
+		errMsg = fmt.Sprintf("NULL (pos %d) is inserted into NOT NULL column %q", n.Location, ident)
+	case NullParam:
+		errMsg = fmt.Sprintf("parameter $%d (pos %d) is %q which can be nil but %q is NOT NULL",
+			n.Parameter, n.Location, n.CallType, ident)
+	}
+
+	return fmt.Sprintf("%s:%d:%d %s",
+		n.Fn.Pos.Filename,
+		n.Fn.Pos.Line,
+		n.Fn.Pos.Column,
+		errMsg,
+	)
+}
+
+func (n NullErr) call() Call {
+	return n.Fn
+}
+
+// parseNullability turns the nullability config option into a level
+func parseNullability(level string) (int, error) {
+	switch level {
+	case "", "default":
+		return nullabilityDefault, nil
+	case "off":
+		return nullabilityOff, nil
+	case "strict":
+		return nullabilityStrict, nil
+	}
+
+	return 0, fmt.Errorf("unknown nullability %q, it must be off, default or strict", level)
+}
+
+// checkInsertNulls checks an insert gives every NOT NULL column without a
+// default a value and that the value isn't NULL.
+func checkInsertNulls(state *State, fn Call, ins pgnodes.InsertStmt, into *drivers.Table) (errs []error) {
+	if state.Nullability == nullabilityOff {
+		return nil
+	}
+
+	// Like the other errors the schema is the one the statement gave
+	var schema string
+	if ins.Relation.Schemaname != nil {
+		schema = *ins.Relation.Schemaname
+	}
+	nullErr := func(kind int, col *drivers.Column, location int) NullErr {
+		return NullErr{
+			Kind:     kind,
+			Schema:   schema,
+			Table:    into.Name,
+			Column:   col.Name,
+			Location: location,
+			Fn:       fn,
+		}
+	}
+
+	// A missing select is DEFAULT VALUES which gives no column a value
+	var values [][]pgnodes.Node
+	supplied := make(map[string]bool)
+	if ins.SelectStmt != nil {
+		sel, ok := ins.SelectStmt.(pgnodes.SelectStmt)
+		if !ok {
+			return nil
+		}
+		values = sel.ValuesLists
+
+		switch {
+		case len(ins.Cols.Items) != 0:
+			for _, c := range ins.Cols.Items {
+				supplied[*c.(pgnodes.ResTarget).Name] = true
+			}
+		case len(values) != 0:
+			for i := 0; i < len(values[0]) && i < len(into.Columns); i++ {
+				supplied[into.Columns[i].Name] = true
+			}
+		default:
+			// Without names the columns a select fills can't be followed
+			return nil
+		}
+	}
+
+	for i := range into.Columns {
+		col := &into.Columns[i]
+		if !supplied[col.Name] && !col.Nullable && len(col.Default) == 0 {
+			errs = append(errs, nullErr(NullMissing, col, ins.Relation.Location))
+		}
+	}
+
+	cols := insertColumns(into, ins.Cols)
+	for _, row := range values {
+		for i, expr := range row {
+			if i >= len(cols) || cols[i] == nil || cols[i].Nullable {
+				continue
+			}
+
+			switch {
+			case isNullConst(expr):
+				errs = append(errs, nullErr(NullLiteral, cols[i], exprLocation(expr)))
+			case isDefault(expr) && len(cols[i].Default) == 0:
+				errs = append(errs, nullErr(NullMissing, cols[i], expr.(pgnodes.SetToDefault).Location))
+			}
+		}
+	}
+
+	return errs
+}
+
+// isNullConst checks if an expression is a NULL constant, which can be cast
+func isNullConst(n pgnodes.Node) bool {
+	switch node := n.(type) {
+	case pgnodes.A_Const:
+		_, ok := node.Val.(pgnodes.Null)
+		return ok
+	case pgnodes.TypeCast:
+		return isNullConst(node.Arg)
+	}
+
+	return false
+}
+
+// isDefault checks if an expression is the DEFAULT keyword
+func isDefault(n pgnodes.Node) bool {
+	_, ok := n.(pgnodes.SetToDefault)
+	return ok
+}
+
+// checkNullParam checks a parameter stored in a NOT NULL column isn't given
+// a Go type that can be nil when nullability is strict.
+func checkNullParam(state *State, fn Call, number int, use paramUse) error {
+	if state.Nullability != nullabilityStrict || !use.stored || use.col.Nullable {
+		return nil
+	}
+	if number-1 >= len(fn.ArgTypes) || !goTypeNullable(argGoType(fn, number-1)) {
+		return nil
+	}
+
+	table, column := use.table, use.column
+	if parts := strings.Split(use.source, "."); len(parts) == 3 {
+		table = parts[1]
+	}
+
+	return NullErr{
+		Kind:      NullParam,
+		Schema:    use.schema,
+		Table:     table,
+		Column:    column,
+		Parameter: number,
+		CallType:  fn.ArgTypes[number-1],
+		Location:  use.location,
+		Fn:        fn,
+	}
+}
+
+// goTypeNullable checks if a Go type can hold a nil value that's sent to
+// postgres as NULL: pointers and the sql.Null*/null.* style types.
+func goTypeNullable(t types.Type) bool {
+	switch typ := t.(type) {
+	case *types.Pointer:
+		return true
+	case *types.Named:
+		obj := typ.Obj()
+		if obj.Pkg() == nil {
+			return false
+		}
+		if unversionedPath(obj.Pkg().Path()) == "github.com/volatiletech/null" {
+			return true
+		}
+		if strings.HasPrefix(obj.Name(), "Null") {
+			return true
+		}
+		return goTypeNullable(typ.Underlying())
+	}
+
+	return false
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// Kinds of parameter errors
+const (
+	ParamConflict = iota
+	ParamTooFew
+	ParamTooMany
+	ParamGap
+	ParamQuestion
+)
+
+// ParamErr is a problem with how a parameter is used in the statement
+// itself rather than with the Go argument given for it.
+type ParamErr struct {
+	// Kind is ParamConflict/TooFew/TooMany/Gap/Question
+	Kind int
+
+	Parameter int
+	Location  int
+
+	// DBTypes are the types the parameter was inferred to be
+	DBTypes []string
+
+	Fn Call
+}
+
+func (p ParamErr) Error() string {
+	args := "none"
+	if len(p.Fn.ArgTypes) != 0 {
+		args = strings.Join(p.Fn.ArgTypes, ", ")
+	}
+
+	var errMsg string
+	switch p.Kind {
+	case ParamConflict:
+		errMsg = fmt.Sprintf("parameter $%d (pos %d) is used as incompatible types: %s",
+			p.Parameter, p.Location, strings.Join(p.DBTypes, ", "))
+	case ParamTooFew:
+		errMsg = fmt.Sprintf("parameter $%d (pos %d) is used but only %d arguments were given (args: %s)",
+			p.Parameter, p.Location, len(p.Fn.ArgTypes), args)
+	case ParamTooMany:
+		if p.Parameter == 0 {
+			errMsg = fmt.Sprintf("%d arguments were given but the statement has no parameters (args: %s)",
+				len(p.Fn.ArgTypes), args)
+			break
+		}
+		errMsg = fmt.Sprintf("%d arguments were given but the statement only uses %d parameters (args: %s)",
+			len(p.Fn.ArgTypes), p.Parameter, args)
+	case ParamGap:
+		errMsg = fmt.Sprintf("parameter $%d is never used but later parameters are (args: %s)",
+			p.Parameter, args)
+	case ParamQuestion:
+		errMsg = fmt.Sprintf("? placeholder (pos %d) is not supported by postgres, use $1, $2... instead (args: %s)",
+			p.Location, args)
+	}
+
+	return fmt.Sprintf("%s:%d:%d %s",
+		p.Fn.Pos.Filename,
+		p.Fn.Pos.Line,
+		p.Fn.Pos.Column,
+		errMsg,
+	)
+}
+
+func (p ParamErr) call() Call {
+	return p.Fn
+}
+
+// paramUse is a place a parameter was used and the type it was expected to
+// be there. The schema/table/column are the identifier that the type came
+// from if there was one, source is the table.column it refers to.
+// coercion is the context the value is converted to the type in.
+type paramUse struct {
+	schema   string
+	table    string
+	column   string
+	source   string
+	col      *drivers.Column
+	location int
+	coercion int
+	// stored is set when the value is stored in the column by an insert
+	// or update
+	stored bool
+}
+
+// paramSet collects the uses of each parameter in a statement
+type paramSet map[int][]paramUse
+
+// sameTypeOps are the operators whose operands are the same type, others
+// like the json and array operators take different types on each side.
+var sameTypeOps = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"~": true, "~*": true, "!~": true, "!~*": true,
+	"~~": true, "~~*": true, "!~~": true, "!~~*": true,
+	"+": true, "-": true, "*": true, "/": true, "%": true, "^": true,
+}
+
+// inferOperatorParams infers the types of parameters used as operands from
+// the type of the other side of the operator.
+func inferOperatorParams(scope *Scope, expr pgnodes.A_Expr) {
+	switch expr.Kind {
+	case pgnodes.AEXPR_OP, pgnodes.AEXPR_DISTINCT, pgnodes.AEXPR_NOT_DISTINCT,
+		pgnodes.AEXPR_NULLIF, pgnodes.AEXPR_LIKE, pgnodes.AEXPR_ILIKE,
+		pgnodes.AEXPR_SIMILAR:
+		if expr.Lexpr == nil || expr.Rexpr == nil {
+			return
+		}
+		if expr.Kind == pgnodes.AEXPR_OP && !sameTypeOps[operatorName(expr)] {
+			inferJSONParams(scope, expr)
+			inferArrayParams(scope, expr)
+			return
+		}
+
+		// Arithmetic only keeps the operand types for numbers,
+		// date + integer for example does not
+		switch operatorName(expr) {
+		case "+", "-", "*", "/", "%", "^":
+			left, right := exprType(scope, expr.Lexpr), exprType(scope, expr.Rexpr)
+			if (left != nil && numericRank(left.DBType) == 0) ||
+				(right != nil && numericRank(right.DBType) == 0) {
+				return
+			}
+		}
+
+		expectSameType(scope, expr.Lexpr, expr.Rexpr)
+		expectSameType(scope, expr.Rexpr, expr.Lexpr)
+	case pgnodes.AEXPR_IN, pgnodes.AEXPR_BETWEEN, pgnodes.AEXPR_NOT_BETWEEN,
+		pgnodes.AEXPR_BETWEEN_SYM, pgnodes.AEXPR_NOT_BETWEEN_SYM:
+		list, ok := expr.Rexpr.(pgnodes.List)
+		if !ok {
+			return
+		}
+
+		for _, item := range list.Items {
+			expectSameType(scope, expr.Lexpr, item)
+			expectSameType(scope, item, expr.Lexpr)
+		}
+	case pgnodes.AEXPR_OP_ANY, pgnodes.AEXPR_OP_ALL:
+		// The right side is an array of the left side's type
+		if use, ok := typeSource(scope, expr.Lexpr); ok && len(arrayElemDBType(use.col.DBType)) == 0 {
+			use.col = pseudoColumn(use.col.Name, "ARRAY"+use.col.DBType, use.col.Nullable)
+			expectType(scope, expr.Rexpr, use)
+		}
+
+		// and the left side is the right side's element type
+		if use, ok := typeSource(scope, expr.Rexpr); ok {
+			if elem := arrayElemDBType(use.col.DBType); len(elem) != 0 {
+				use.col = pseudoColumn(use.col.Name, elem, true)
+				expectType(scope, expr.Lexpr, use)
+			}
+		}
+	}
+}
+
+// expectSameType expects the target to be the same type as the source if
+// the source's type is known.
+func expectSameType(scope *Scope, source, target pgnodes.Node) {
+	use, ok := typeSource(scope, source)
+	if !ok {
+		return
+	}
+
+	expectType(scope, target, use)
+}
+
+// typeSource creates a paramUse from an expression that can decide the type
+// of a parameter.
+func typeSource(scope *Scope, n pgnodes.Node) (paramUse, bool) {
+	col := resolveUnknown(exprType(scope, n))
+	if col == nil || len(col.DBType) == 0 {
+		return paramUse{}, false
+	}
+
+	use := paramUse{col: col}
+	if expr, ok := n.(pgnodes.A_Expr); ok && expr.Kind == pgnodes.AEXPR_OP {
+		// The results of json operators are named after the path taken
+		if _, ok := jsonOps[operatorName(expr)]; ok {
+			use.column = jsonPathName(expr)
+		}
+	}
+	if colRef, ok := n.(pgnodes.ColumnRef); ok {
+		var field pgnodes.Node
+		use.schema, use.table, field = splitColumnRef(colRef)
+		if str, ok := field.(pgnodes.String); ok {
+			use.column = str.Str
+			if orig, ret := scope.get(use.schema, use.table, use.column); ret == scopeRetOk {
+				use.source = scope.columnKey(orig)
+			}
+		}
+	}
+
+	return use, true
+}
+
+// expectType records that an expression should be a certain type. Parameters
+// take on the type, other expressions pass it on to the places postgres
+// would use to resolve their type.
+func expectType(scope *Scope, n pgnodes.Node, use paramUse) {
+	switch node := n.(type) {
+	case pgnodes.ParamRef:
+		use.location = node.Location
+		scope.params[node.Number] = append(scope.params[node.Number], use)
+	case pgnodes.A_Const:
+		*scope.literals = append(*scope.literals, literalUse{use: use, value: node})
+	case pgnodes.CoalesceExpr:
+		for _, arg := range node.Args.Items {
+			expectType(scope, arg, use)
+		}
+	case pgnodes.MinMaxExpr:
+		for _, arg := range node.Args.Items {
+			expectType(scope, arg, use)
+		}
+	case pgnodes.CaseExpr:
+		for _, w := range node.Args.Items {
+			expectType(scope, w.(pgnodes.CaseWhen).Result, use)
+		}
+		expectType(scope, node.Defresult, use)
+	case pgnodes.A_Expr:
+		if node.Kind == pgnodes.AEXPR_NULLIF {
+			expectType(scope, node.Lexpr, use)
+		}
+	case pgnodes.A_ArrayExpr:
+		elem := arrayElemDBType(use.col.DBType)
+		if len(elem) == 0 {
+			return
+		}
+
+		elemUse := use
+		elemUse.col = pseudoColumn(use.col.Name, elem, true)
+		for _, e := range node.Elements.Items {
+			expectType(scope, e, elemUse)
+		}
+	}
+}
+
+// expectDBType records that an expression should be of a db type, the
+// description is used in place of an identifier in errors.
+func expectDBType(scope *Scope, n pgnodes.Node, dbType, description string, coercion int) {
+	expectType(scope, n, paramUse{
+		column:   description,
+		col:      pseudoColumn("", dbType, false),
+		coercion: coercion,
+	})
+}
+
+// checkParams checks that each parameter is used consistently and that the
+// Go argument given for it matches its type.
+func checkParams(state *State, fn Call, params paramSet) (errs []error) {
+	numbers := make([]int, 0, len(params))
+	for n := range params {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	for _, n := range numbers {
+		uses := params[n]
+		if n == 0 {
+			// ? placeholders, they've already been reported
+			continue
+		}
+
+		var typed []paramUse
+		for _, u := range uses {
+			if u.col != nil {
+				typed = append(typed, u)
+			}
+		}
+		if len(typed) == 0 {
+			continue
+		}
+
+		var dbTypes []string
+		conflict := false
+		for _, u := range typed {
+			if len(dbTypes) != 0 && !dbTypesCompatible(dbTypes[0], u.col.DBType) {
+				conflict = true
+			}
+
+			found := false
+			for _, t := range dbTypes {
+				if t == u.col.DBType {
+					found = true
+					break
+				}
+			}
+			if !found {
+				dbTypes = append(dbTypes, u.col.DBType)
+			}
+		}
+
+		if conflict {
+			errs = append(errs, ParamErr{
+				Kind:      ParamConflict,
+				Parameter: n,
+				Location:  typed[0].location,
+				DBTypes:   dbTypes,
+				Fn:        fn,
+			})
+			continue
+		}
+
+		// The uses are all compatible so the Go argument only needs to be
+		// checked against one of them
+		if err := typeCheck(state, fn, n, typed[0]); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, u := range typed {
+			if err := checkNullParam(state, fn, n, u); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+
+		for _, u := range typed {
+			if err := enumCheck(fn, n, u); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// checkArity checks the parameters used in a call against the number of
+// arguments given. used maps the parameter numbers to where they were
+// first used.
+func checkArity(fn Call, used map[int]int) (errs []error) {
+	// With ? placeholders there's no telling which argument is which
+	if _, ok := used[0]; ok {
+		return nil
+	}
+
+	highest := 0
+	for n := range used {
+		if n > highest {
+			highest = n
+		}
+	}
+
+	for n := 1; n < highest; n++ {
+		if _, ok := used[n]; !ok {
+			errs = append(errs, ParamErr{Kind: ParamGap, Parameter: n, Fn: fn})
+		}
+	}
+
+	switch {
+	case highest > len(fn.ArgTypes):
+		errs = append(errs, ParamErr{
+			Kind:      ParamTooFew,
+			Parameter: highest,
+			Location:  used[highest],
+			Fn:        fn,
+		})
+	case highest < len(fn.ArgTypes):
+		errs = append(errs, ParamErr{
+			Kind:      ParamTooMany,
+			Parameter: highest,
+			Fn:        fn,
+		})
+	}
+
+	return errs
+}
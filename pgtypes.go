@@ -0,0 +1,223 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// pgTypeNames maps the names the parser gives back for types (which are
+// often the internal names like int4) to the names information_schema uses
+// which is what ends up in Column.DBType.
+var pgTypeNames = map[string]string{
+	"int2":        "smallint",
+	"smallint":    "smallint",
+	"smallserial": "smallint",
+	"int4":        "integer",
+	"int":         "integer",
+	"integer":     "integer",
+	"serial":      "integer",
+	"int8":        "bigint",
+	"bigint":      "bigint",
+	"bigserial":   "bigint",
+	"float4":      "real",
+	"real":        "real",
+	"float8":      "double precision",
+	"float":       "double precision",
+	"numeric":     "numeric",
+	"decimal":     "numeric",
+	"bool":        "boolean",
+	"boolean":     "boolean",
+	"text":        "text",
+	"varchar":     "character varying",
+	"bpchar":      "character",
+	"char":        "character",
+	"bytea":       "bytea",
+	"date":        "date",
+	"time":        "time without time zone",
+	"timetz":      "time with time zone",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+	"interval":    "interval",
+	"uuid":        "uuid",
+	"json":        "json",
+	"jsonb":       "jsonb",
+	"inet":        "inet",
+	"cidr":        "cidr",
+	"macaddr":     "macaddr",
+	"money":       "money",
+	"xml":         "xml",
+	"bit":         "bit",
+	"varbit":      "bit varying",
+	"point":       "point",
+	"line":        "line",
+	"lseg":        "lseg",
+	"box":         "box",
+	"path":        "path",
+	"polygon":     "polygon",
+	"circle":      "circle",
+}
+
+// typeNameDBType turns a parsed type name into the name information_schema
+// would give the type. Arrays are returned the way the psql driver formats
+// them: ARRAYinteger
+func typeNameDBType(tn pgnodes.TypeName) string {
+	if len(tn.Names.Items) == 0 {
+		return ""
+	}
+
+	name := tn.Names.Items[len(tn.Names.Items)-1].(pgnodes.String).Str
+	if mapped, ok := pgTypeNames[name]; ok {
+		name = mapped
+	}
+
+	if len(tn.ArrayBounds.Items) != 0 {
+		return "ARRAY" + name
+	}
+
+	return name
+}
+
+// arrayElemDBType returns the element type of an array db type or the empty
+// string if it is not an array.
+func arrayElemDBType(dbType string) string {
+	if !strings.HasPrefix(dbType, "ARRAY") {
+		return ""
+	}
+
+	return strings.TrimPrefix(dbType, "ARRAY")
+}
+
+// pseudoColumn creates column information for something that isn't a
+// real column like an expression or a column of a set returning function.
+func pseudoColumn(name, dbType string, nullable bool) *drivers.Column {
+	col := &drivers.Column{
+		Name:     name,
+		DBType:   dbType,
+		Nullable: nullable,
+		Type:     translateDBType(dbType, nullable),
+	}
+
+	if elem := arrayElemDBType(dbType); len(elem) != 0 {
+		col.ArrType = &elem
+	}
+
+	return col
+}
+
+// translateDBType finds the Go type the psql driver would use for a db type.
+func translateDBType(dbType string, nullable bool) string {
+	if elem := arrayElemDBType(dbType); len(elem) != 0 {
+		switch elem {
+		case "bigint", "integer", "smallint":
+			return "types.Int64Array"
+		case "bytea":
+			return "types.BytesArray"
+		case "boolean":
+			return "types.BoolArray"
+		case "numeric":
+			return "types.DecimalArray"
+		case "double precision", "real":
+			return "types.Float64Array"
+		default:
+			return "types.StringArray"
+		}
+	}
+
+	var typ, nullTyp string
+	switch dbType {
+	case "bigint":
+		typ, nullTyp = "int64", "null.Int64"
+	case "integer":
+		typ, nullTyp = "int", "null.Int"
+	case "smallint":
+		typ, nullTyp = "int16", "null.Int16"
+	case "numeric":
+		typ, nullTyp = "types.Decimal", "types.NullDecimal"
+	case "double precision":
+		typ, nullTyp = "float64", "null.Float64"
+	case "real":
+		typ, nullTyp = "float32", "null.Float32"
+	case "json", "jsonb":
+		typ, nullTyp = "types.JSON", "null.JSON"
+	case "bytea":
+		typ, nullTyp = "[]byte", "null.Bytes"
+	case "boolean":
+		typ, nullTyp = "bool", "null.Bool"
+	case "date", "time without time zone", "time with time zone",
+		"timestamp without time zone", "timestamp with time zone":
+		typ, nullTyp = "time.Time", "null.Time"
+	default:
+		typ, nullTyp = "string", "null.String"
+	}
+
+	if nullable {
+		return nullTyp
+	}
+	return typ
+}
+
+// dbTypeCategory returns the postgres type category (pg_type.typcategory) of
+// a db type or the empty string if it's not known.
+func dbTypeCategory(dbType string) string {
+	if strings.HasPrefix(dbType, "ARRAY") {
+		return "A"
+	}
+	if strings.HasPrefix(dbType, "enum.") {
+		return "E"
+	}
+
+	switch dbType {
+	case "smallint", "integer", "bigint", "real", "double precision",
+		"numeric", "money", "oid":
+		return "N"
+	case "text", "character varying", "character", "name":
+		return "S"
+	case "boolean":
+		return "B"
+	case "date", "time without time zone", "time with time zone",
+		"timestamp without time zone", "timestamp with time zone":
+		return "D"
+	case "interval":
+		return "T"
+	case "inet", "cidr":
+		return "I"
+	case "bit", "bit varying":
+		return "V"
+	case "point", "line", "lseg", "box", "path", "polygon", "circle":
+		return "G"
+	case "uuid", "json", "jsonb", "bytea", "xml", "macaddr":
+		return "U"
+	}
+
+	return ""
+}
+
+// dbTypesCompatible checks if a value of one db type could be used as the
+// other. Types in the same category are compatible with each other except
+// for user types like uuid and json which must match exactly. Types that
+// aren't known are assumed to be compatible.
+func dbTypesCompatible(a, b string) bool {
+	if a == b || len(a) == 0 || len(b) == 0 {
+		return true
+	}
+
+	catA, catB := dbTypeCategory(a), dbTypeCategory(b)
+	if len(catA) == 0 || len(catB) == 0 {
+		return true
+	}
+	if catA != catB {
+		return false
+	}
+
+	switch catA {
+	case "A":
+		return dbTypesCompatible(arrayElemDBType(a), arrayElemDBType(b))
+	case "E", "U":
+		return false
+	}
+
+	return true
+}
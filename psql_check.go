@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"path"
 	"strings"
 
 	"github.com/volatiletech/sqlboiler/v4/drivers"
@@ -15,14 +14,22 @@ import (
 const (
 	Unknown = iota
 	Ambiguous
+	Exists
 
 	unknownTypeSentinel = "UNKNOWNTYPESENTINEL"
 )
 
+// callErr is an error found in a call, they're printed grouped by the
+// call's package
+type callErr interface {
+	error
+	call() Call
+}
+
 // IdentErr is an unknown identifier error that occurs when the database
 // does not contain information that proves the identifiers existence.
 type IdentErr struct {
-	// Kind is Unknown/Ambiguous
+	// Kind is Unknown/Ambiguous/Exists
 	Kind int
 
 	Schema   string
@@ -34,20 +41,7 @@ type IdentErr struct {
 }
 
 func (i IdentErr) Error() string {
-	lnt, lnc := len(i.Table), len(i.Column)
-	var ident string
-	switch {
-	case lnt != 0 && lnc != 0:
-		ident = i.Table + "." + i.Column
-	case lnc != 0:
-		ident = i.Column
-	default:
-		ident = i.Table
-	}
-
-	if len(i.Schema) != 0 && i.Schema != "public" {
-		ident = i.Schema + "." + ident
-	}
+	ident := errIdent(i.Schema, i.Table, i.Column)
 
 	var errMsg string
 	switch i.Kind {
@@ -55,6 +49,8 @@ func (i IdentErr) Error() string {
 		errMsg = "ambiguous identifier in sql statement"
 	case Unknown:
 		errMsg = "unknown identifier in sql statement"
+	case Exists:
+		errMsg = "identifier already exists in sql statement"
 	}
 
 	return fmt.Sprintf("%s:%d:%d %s: %s at pos %d",
@@ -67,6 +63,27 @@ func (i IdentErr) Error() string {
 	)
 }
 
+func (i IdentErr) call() Call {
+	return i.Fn
+}
+
+// errIdent joins the parts of an identifier that are known for an error.
+// Every error leaves out the public schema since that's where tables are
+// unless they say otherwise.
+func errIdent(schema string, names ...string) string {
+	var parts []string
+	if len(schema) != 0 && schema != "public" {
+		parts = append(parts, schema)
+	}
+	for _, n := range names {
+		if len(n) != 0 {
+			parts = append(parts, n)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
 // TypeErr occurs when the function arguments given do not match the
 // parameters.
 type TypeErr struct {
@@ -85,13 +102,7 @@ type TypeErr struct {
 }
 
 func (t TypeErr) Error() string {
-	ident := t.Column
-	if len(t.Table) != 0 {
-		ident = t.Table + "." + ident
-	}
-	if len(t.Schema) != 0 && t.Schema != "public" {
-		ident = t.Schema + "." + ident
-	}
+	ident := errIdent(t.Schema, t.Table, t.Column)
 
 	return fmt.Sprintf("%s:%d:%d type mismatch, %q has type %q (db: %s) but parameter $%d (pos %d) is %q",
 		t.Fn.Pos.Filename,
@@ -106,6 +117,38 @@ func (t TypeErr) Error() string {
 	)
 }
 
+func (t TypeErr) call() Call {
+	return t.Fn
+}
+
+// SchemaWarn occurs when an unqualified table name is found in more than
+// one schema on the search path. The first one is used just like postgres
+// would but it's easy to query the wrong table by accident.
+type SchemaWarn struct {
+	Table    string
+	Schemas  []string
+	Location int
+
+	Fn Call
+}
+
+func (s SchemaWarn) Error() string {
+	return fmt.Sprintf("%s:%d:%d warning: table %q is in several schemas on the search_path (%s), using %s.%s at pos %d",
+		s.Fn.Pos.Filename,
+		s.Fn.Pos.Line,
+		s.Fn.Pos.Column,
+		s.Table,
+		strings.Join(s.Schemas, ", "),
+		s.Schemas[0],
+		s.Table,
+		s.Location,
+	)
+}
+
+func (s SchemaWarn) call() Call {
+	return s.Fn
+}
+
 // ParseError occurs when a statement fails to parse
 type ParseError struct {
 	Err error
@@ -121,20 +164,43 @@ func (p ParseError) Error() string {
 	)
 }
 
+func (p ParseError) call() Call {
+	return p.Fn
+}
+
 func checkCalls(state *State, fns []Call) (errs []error) {
+	// Calls in the same session see the tables that the ones before them
+	// created or changed, other calls get their own copy if they change any
+	sessions := make(map[string]*State)
+
 	for _, fn := range fns {
 		tree, err := pgquery.Parse(fn.SQL)
 		if err != nil {
 			errs = append(errs, ParseError{Err: err, Fn: fn})
 		}
 
-		errs = append(errs, checkCall(state, fn, tree)...)
+		callState := state
+		switch {
+		case len(fn.Session) != 0:
+			key := fn.Package + "." + fn.Session
+			if _, ok := sessions[key]; !ok {
+				sessions[key] = state.withTables()
+			}
+			callState = sessions[key]
+		case changesTables(tree):
+			callState = state.withTables()
+		}
+
+		errs = append(errs, checkCall(callState, fn, tree)...)
 	}
 
 	return errs
 }
 
 func checkCall(state *State, fn Call, tree pgquery.ParsetreeList) (errs []error) {
+	// The location each parameter is first used at across all statements
+	used := make(map[int]int)
+
 	for _, stmt := range tree.Statements {
 		// Quite often things are packed in the raw statement
 		if raw, ok := stmt.(pgnodes.RawStmt); ok {
@@ -142,10 +208,25 @@ func checkCall(state *State, fn Call, tree pgquery.ParsetreeList) (errs []error)
 		}
 
 		// Create a scope for each statement we parse as they should be separate
-		errList := checkCallRecurse(state, fn, NewScope(state.DBInfo), stmt)
+		scope := NewScope(state.DBInfo)
+		scope.searchPath = state.SearchPath
+		errList := checkCallRecurse(state, fn, scope, stmt)
 		if len(errList) != 0 {
 			errs = append(errs, errList...)
 		}
+
+		errs = append(errs, checkParams(state, fn, scope.params)...)
+		errs = append(errs, checkLiterals(fn, *scope.literals)...)
+
+		for n, uses := range scope.params {
+			if _, ok := used[n]; !ok {
+				used[n] = uses[0].location
+			}
+		}
+	}
+
+	if len(tree.Statements) != 0 {
+		errs = append(errs, checkArity(fn, used)...)
 	}
 
 	return errs
@@ -165,8 +246,30 @@ func checkCallRecurse(state *State, fn Call, scope *Scope, n pgnodes.Node) (errs
 		// Rawstmt seems to be the root of most expressions
 		panic("there should be no raw statements at this level")
 	case pgnodes.SelectStmt:
+		if node.IntoClause != nil {
+			errs = append(errs, checkCreateTableAs(state, fn, scope, node, *node.IntoClause, relTable, false)...)
+			break
+		}
 		_, errList := checkSelect(state, fn, scope, node)
 		errs = append(errs, errList...)
+	case pgnodes.CreateStmt:
+		errs = append(errs, createTable(state, fn, scope, *node.Relation, relTable, createdColumns(node.TableElts), createdPKey(node.TableElts), node.IfNotExists)...)
+	case pgnodes.CreateTableAsStmt:
+		if sel, ok := node.Query.(pgnodes.SelectStmt); ok {
+			kind := relTable
+			if node.Relkind == pgnodes.OBJECT_MATVIEW {
+				kind = relMatView
+			}
+			errs = append(errs, checkCreateTableAs(state, fn, scope, sel, *node.Into, kind, node.IfNotExists)...)
+		}
+	case pgnodes.AlterTableStmt:
+		if node.Relkind == pgnodes.OBJECT_TABLE {
+			errs = append(errs, checkAlterTable(state, fn, scope, node)...)
+		}
+	case pgnodes.RenameStmt:
+		errs = append(errs, checkRename(state, fn, scope, node)...)
+	case pgnodes.DropStmt:
+		errs = append(errs, checkDrop(state, fn, scope, node)...)
 	case pgnodes.UpdateStmt:
 		errs = append(errs, checkUpdate(state, fn, scope, node)...)
 	case pgnodes.InsertStmt:
@@ -176,16 +279,123 @@ func checkCallRecurse(state *State, fn Call, scope *Scope, n pgnodes.Node) (errs
 	case pgnodes.SortBy:
 		errs = descend(node.Node)
 	case pgnodes.FuncCall:
+		errs = append(errs, checkFuncCall(state, fn, scope, node)...)
+
+		if f, ok, _ := lookupFunction(state, "", funcCallName(node)); ok && f.aggregate && node.Over == nil {
+			inAggregate := scope.inAggregate
+			scope.inAggregate = true
+			defer func() { scope.inAggregate = inAggregate }()
+		}
+
+		if node.Over != nil {
+			errs = append(errs, checkWindowCall(fn, scope, node)...)
+			restore := scope.noWindows("window function arguments")
+			defer restore()
+		}
+
+		for _, arg := range node.Args.Items {
+			errs = descend(arg)
+		}
+		if f, ok, _ := lookupFunction(state, funcCallSchema(node), funcCallName(node)); ok {
+			f = f.forCall(scope, node.Args.Items)
+			for i, arg := range node.Args.Items {
+				if i < len(f.args) && len(f.args[i]) != 0 {
+					expectDBType(scope, arg, f.args[i], funcCallName(node)+"()", coerceFunction)
+				}
+			}
+		}
+		for _, o := range node.AggOrder.Items {
+			errs = descend(o)
+		}
+		errs = descend(node.AggFilter)
+		if node.Over != nil {
+			errs = descend(*node.Over)
+		}
+	case pgnodes.WindowDef:
+		defer scope.noWindows("window definitions")()
+		for _, p := range node.PartitionClause.Items {
+			errs = descend(p)
+		}
+		for _, o := range node.OrderClause.Items {
+			errs = descend(o)
+		}
+		errs = descend(node.StartOffset)
+		errs = descend(node.EndOffset)
+	case pgnodes.NamedArgExpr:
+		errs = descend(node.Arg)
+	case pgnodes.CaseExpr:
+		errs = descend(node.Arg)
+		for _, w := range node.Args.Items {
+			errs = descend(w)
+		}
+		errs = descend(node.Defresult)
+	case pgnodes.CaseWhen:
+		errs = descend(node.Expr)
+		errs = descend(node.Result)
+	case pgnodes.CoalesceExpr:
+		for _, arg := range node.Args.Items {
+			errs = descend(arg)
+		}
+	case pgnodes.MinMaxExpr:
+		for _, arg := range node.Args.Items {
+			errs = descend(arg)
+		}
+	case pgnodes.RowExpr:
+		for _, arg := range node.Args.Items {
+			errs = descend(arg)
+		}
+	case pgnodes.A_ArrayExpr:
+		for _, elem := range node.Elements.Items {
+			errs = descend(elem)
+		}
+	case pgnodes.A_Indirection:
+		errs = descend(node.Arg)
+		for _, i := range node.Indirection.Items {
+			errs = descend(i)
+		}
+		errs = append(errs, checkSubscript(fn, scope, node)...)
+	case pgnodes.A_Indices:
+		errs = descend(node.Lidx)
+		errs = descend(node.Uidx)
+	case pgnodes.TypeCast:
+		errs = descend(node.Arg)
+
+		dbType := typeNameDBType(*node.TypeName)
+		expectDBType(scope, node.Arg, dbType, "::"+dbType, coerceExplicit)
+	case pgnodes.CollateClause:
+		errs = descend(node.Arg)
+	case pgnodes.NullTest:
+		errs = descend(node.Arg)
+	case pgnodes.BooleanTest:
+		errs = descend(node.Arg)
+	case pgnodes.GroupingSet:
+		for _, c := range node.Content.Items {
+			errs = descend(c)
+		}
+	case pgnodes.MultiAssignRef:
+		errs = descend(node.Source)
+	case pgnodes.XmlExpr:
 		for _, arg := range node.Args.Items {
 			errs = descend(arg)
 		}
+		for _, arg := range node.NamedArgs.Items {
+			// These are ResTargets but their names are xml attributes
+			// not columns.
+			errs = descend(arg.(pgnodes.ResTarget).Val)
+		}
+	case pgnodes.List:
+		// Lists show up as the right hand side of IN and as rows
+		for _, i := range node.Items {
+			errs = descend(i)
+		}
 	case pgnodes.A_Expr:
 		errs = descend(node.Lexpr)
 		errs = descend(node.Rexpr)
 
-		if err := typeCheck(state, fn, scope, node.Lexpr, node.Rexpr); err != nil {
-			errs = append(errs, err)
-		}
+		inferOperatorParams(scope, node)
+		errs = append(errs, checkComparison(fn, scope, node)...)
+		errs = append(errs, checkJSONOperator(fn, scope, node)...)
+		errs = append(errs, checkArrayOperator(fn, scope, node)...)
 	case pgnodes.BoolExpr:
 		for _, i := range node.Args.Items {
 			errs = descend(i)
@@ -218,7 +428,7 @@ func checkCallRecurse(state *State, fn Call, scope *Scope, n pgnodes.Node) (errs
 			if ret != scopeRetOk {
 				errs = append(errs, IdentErr{
 					Kind:     kind,
-					Schema:   schema,
+					Schema:   scope.identSchema(schema, table),
 					Table:    table,
 					Column:   column,
 					Location: node.Location,
@@ -230,7 +440,17 @@ func checkCallRecurse(state *State, fn Call, scope *Scope, n pgnodes.Node) (errs
 		default:
 			panic(fmt.Sprintf("%T", node.Fields.Items[1]))
 		}
+	case pgnodes.ParamRef:
+		// The parser accepts ? placeholders but gives them no number
+		if node.Number == 0 {
+			errs = append(errs, ParamErr{Kind: ParamQuestion, Location: node.Location, Fn: fn})
+		}
+
+		// Record every use so the arguments can be counted even when the
+		// parameter's type can't be inferred
+		scope.params[node.Number] = append(scope.params[node.Number], paramUse{location: node.Location})
 	case pgnodes.SubLink:
+		errs = descend(node.Testexpr)
 		errs = descend(node.Subselect)
 	case pgnodes.ResTarget:
 		// ResTarget can also happen in Select lists, but we circumvent
@@ -260,96 +480,103 @@ func checkSelect(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt) (o
 		return append(errs, checkCallRecurse(state, fn, scope, node)...)
 	}
 
-	// If this is an "upper level select" then lets just check the
-	// selects themselves as separate entities.
+	// Common table expressions are visible to the entire statement including
+	// both sides of a set operation so they have to come first.
+	popCTEs, errList := checkWithClause(state, fn, scope, sel.WithClause)
+	errs = append(errs, errList...)
+	defer popCTEs()
+
+	// Aggregates belong to the select they're in, so whether they're
+	// allowed starts over in every nested select
+	defer scope.noAggregates("")()
+	defer scope.noWindows("")()
+	inAggregate, windows := scope.inAggregate, scope.windows
+	scope.inAggregate, scope.windows = false, nil
+	defer func() { scope.inAggregate, scope.windows = inAggregate, windows }()
+
+	// If this is an "upper level select" then each side is checked as a
+	// separate entity and their columns are combined. ORDER BY and LIMIT
+	// belong to the combined result and can only see its column names.
 	if sel.Larg != nil && sel.Rarg != nil {
-		errs = descend(*sel.Larg)
-		errs = descend(*sel.Rarg)
-		return nil, errs
-	}
-
-	// Bring all the tables into scope
-	nTables := 0
-
-	addTable := func(r pgnodes.RangeVar) {
-		table := *r.Relname
-		var alias, schema string
-		if r.Schemaname != nil {
-			schema = *r.Schemaname
+		left, leftErrs := checkSelect(state, fn, scope, *sel.Larg)
+		errs = append(errs, leftErrs...)
+		right, rightErrs := checkSelect(state, fn, scope, *sel.Rarg)
+		errs = append(errs, rightErrs...)
+
+		// A side that couldn't be resolved has already been reported and
+		// comparing its columns would only pile more errors on top
+		refs := left
+		if len(left) == 0 {
+			refs = right
 		}
-		if r.Alias != nil {
-			alias = *r.Alias.Aliasname
+		if len(left) != 0 && len(right) != 0 && len(leftErrs) == 0 && len(rightErrs) == 0 {
+			var errList []error
+			refs, errList = checkSetOp(fn, sel, left, right)
+			errs = append(errs, errList...)
 		}
 
-		if !scope.pushTable(schema, table, alias) {
-			errs = append(errs, IdentErr{
-				Schema:   schema,
-				Table:    table,
-				Location: r.Location,
-				Fn:       fn,
-			})
-		} else {
-			nTables++
+		scope.pushLevel()
+		for _, r := range refs {
+			scope.pushOutputName(r.name, r.col)
 		}
-	}
-
-	// The joins are recursive in nature, but we're going to
-	// process it iteratively since we're still in the middle
-	// of processing a select statement
-	stack := make([]pgnodes.Node, len(sel.FromClause.Items))
-	copy(stack, sel.FromClause.Items)
-
-	for len(stack) > 0 {
-		popped := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		switch item := popped.(type) {
-		case pgnodes.RangeVar:
-			addTable(item)
-		case pgnodes.JoinExpr:
-			stack = append(stack, item.Quals)
-			stack = append(stack, item.Rarg)
-			stack = append(stack, item.Larg)
-		case pgnodes.A_Expr:
-			errs = descend(popped)
-		case pgnodes.BoolExpr:
-			errs = descend(popped)
-		case pgnodes.RangeSubselect:
-			if item.Alias == nil {
-				panic(fmt.Sprintf("subquery: %s\nmust have alias", item.Subquery.Deparse()))
-			}
-
-			var subSelectScope *Scope
-			if item.Lateral {
-				// Lateral things have access to the outside world
-				// but we don't want to poison our current scope with
-				// its internal nonsense so clone it
-				subSelectScope = scope.clone()
-			} else {
-				subSelectScope = NewScope(scope.info)
-			}
+		errs = append(errs, checkOrdinals(fn, "ORDER BY", sel.SortClause.Items, len(refs))...)
+		for _, items := range sel.SortClause.Items {
+			errs = descend(items)
+		}
+		restore := scope.noAggregates("LIMIT")
+		errs = descend(sel.LimitCount)
+		errs = descend(sel.LimitOffset)
+		restore()
+		expectDBType(scope, sel.LimitCount, "bigint", "LIMIT", coerceAssignment)
+		expectDBType(scope, sel.LimitOffset, "bigint", "OFFSET", coerceAssignment)
+		for range refs {
+			scope.popOutputName()
+		}
+		scope.popLevel()
 
-			subSelect, ok := item.Subquery.(pgnodes.SelectStmt)
-			if !ok {
-				panic(fmt.Sprintf("subquery: %s\nnot a select statement", item.Subquery.Deparse()))
-			}
+		return refs, errs
+	}
 
-			subSelMap, subSelectErrs := checkSelect(state, fn, subSelectScope, subSelect)
-			if len(subSelectErrs) > 0 {
-				errs = append(errs, subSelectErrs...)
+	// A VALUES list has no tables, its columns are named column1, column2 etc.
+	// and take their types from the first row that has a known type.
+	if len(sel.ValuesLists) != 0 {
+		var refs []outputColRef
+		defer scope.noAggregates("VALUES")()
+		for _, row := range sel.ValuesLists {
+			for i, expr := range row {
+				errs = descend(expr)
+
+				if i >= len(refs) {
+					refs = append(refs, outputColRef{name: fmt.Sprintf("column%d", i+1)})
+				}
+				if refs[i].col == nil || refs[i].col.DBType == "unknown" {
+					refs[i].col = exprType(scope, expr)
+				}
 			}
-
-			pseudoTable := outputColsToPseudoTable(*item.Alias.Aliasname, subSelMap)
-			scope.pushPseudoTable(*item.Alias.Aliasname, pseudoTable)
-			nTables++
-		default:
-			panic(fmt.Sprintf("what is this weird from statement: %T", item))
 		}
+		for i := range refs {
+			refs[i].col = resolveUnknown(refs[i].col)
+		}
+
+		return refs, errs
 	}
 
+	// Bring all the tables into scope
+	scope.pushLevel()
+	fromCols, popFrom, errList := checkFromClause(state, fn, scope, sel.FromClause.Items)
+	errs = append(errs, errList...)
+
 	// Follow-up clauses
+	errs = append(errs, defineWindows(fn, scope, sel)...)
+	restore := scope.noAggregates("WHERE")
 	errs = descend(sel.WhereClause)
+	restore()
+	restore = scope.noWindows("HAVING")
 	errs = descend(sel.HavingClause)
+	restore()
+	for _, w := range sel.WindowClause.Items {
+		errs = descend(w)
+	}
 
 	// Process select list after where/having, but before GroupBy and
 	// OrderBy so that we can create a list of output_name's that
@@ -373,13 +600,6 @@ func checkSelect(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt) (o
 			var schema, table, col string
 			ln := len(colRef.Fields.Items)
 
-			if ln == 1 {
-				if _, ok := colRef.Fields.Items[0].(pgnodes.A_Star); ok {
-					panic("subqueries with * are not supported yet")
-				}
-			}
-
-			col = colRef.Fields.Items[ln-1].(pgnodes.String).Str
 			if ln >= 2 {
 				table = colRef.Fields.Items[ln-2].(pgnodes.String).Str
 			}
@@ -387,11 +607,46 @@ func checkSelect(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt) (o
 				schema = colRef.Fields.Items[ln-3].(pgnodes.String).Str
 			}
 
+			if _, ok := colRef.Fields.Items[ln-1].(pgnodes.A_Star); ok {
+				// A bare * is every column of the from clause, a qualified
+				// one is only the columns of that table.
+				if len(table) == 0 {
+					addRefs = append(addRefs, fromCols...)
+					continue
+				}
+
+				index := scope.tableIndex(schema, table)
+				if index < 0 {
+					errs = append(errs, IdentErr{
+						Schema:   schema,
+						Table:    table,
+						Location: colRef.Location,
+						Fn:       fn,
+					})
+					continue
+				}
+
+				tableCols := tableOutputCols(scope.tables[index])
+				if scope.nullable[index] {
+					tableCols = nullableOutputCols(tableCols)
+				}
+				addRefs = append(addRefs, tableCols...)
+				continue
+			}
+
+			col = colRef.Fields.Items[ln-1].(pgnodes.String).Str
+
 			var ret int
 			column, ret = scope.get(schema, table, col)
 			if ret != scopeRetOk {
+				kind := Unknown
+				if ret == scopeRetAmbiguous {
+					kind = Ambiguous
+				}
+
 				errs = append(errs, IdentErr{
-					Schema:   schema,
+					Kind:     kind,
+					Schema:   scope.identSchema(schema, table),
 					Table:    table,
 					Column:   col,
 					Location: colRef.Location,
@@ -401,8 +656,16 @@ func checkSelect(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt) (o
 			}
 
 			if len(name) == 0 {
-				name = column.Name
+				name = col
+			}
+			column = scope.outputColumn(schema, table, col, column)
+		} else {
+			errs = descend(resTarg.Val)
+
+			if len(name) == 0 {
+				name = exprColName(resTarg.Val)
 			}
+			column = resolveUnknown(exprType(scope, resTarg.Val))
 		}
 
 		addRefs = append(addRefs, outputColRef{
@@ -414,115 +677,452 @@ func checkSelect(state *State, fn Call, scope *Scope, sel pgnodes.SelectStmt) (o
 		scope.pushOutputName(r.name, r.col)
 	}
 
+	errs = append(errs, checkOrdinals(fn, "GROUP BY", groupingExprs(sel.GroupClause.Items), len(addRefs))...)
+	errs = append(errs, checkOrdinals(fn, "ORDER BY", sel.SortClause.Items, len(addRefs))...)
+	errs = append(errs, checkOrdinals(fn, "DISTINCT ON", sel.DistinctClause.Items, len(addRefs))...)
+	errs = append(errs, checkGrouping(state, fn, scope, sel)...)
+	errs = append(errs, checkDistinctOn(fn, scope, sel)...)
+	errs = append(errs, checkLocking(fn, scope, sel)...)
+
+	restore = scope.noAggregates("GROUP BY")
 	for _, items := range sel.GroupClause.Items {
 		errs = descend(items)
 	}
+	restore()
 	for _, items := range sel.SortClause.Items {
 		errs = descend(items)
 	}
+	// A plain DISTINCT is a list with a single nil in it which is harmless
+	for _, items := range sel.DistinctClause.Items {
+		errs = descend(items)
+	}
+	restore = scope.noAggregates("LIMIT")
+	errs = descend(sel.LimitCount)
+	errs = descend(sel.LimitOffset)
+	restore()
+	expectDBType(scope, sel.LimitCount, "bigint", "LIMIT", coerceAssignment)
+	expectDBType(scope, sel.LimitOffset, "bigint", "OFFSET", coerceAssignment)
 
 	for range addRefs {
 		scope.popOutputName()
 	}
 
-	for i := 0; i < nTables; i++ {
-		scope.popTable()
-	}
+	popFrom()
+	scope.popLevel()
 
 	return addRefs, errs
 }
 
-func checkUpdate(state *State, fn Call, scope *Scope, update pgnodes.UpdateStmt) (errs []error) {
-	var schema, alias string
-	if update.Relation.Schemaname != nil {
-		schema = *update.Relation.Schemaname
-	}
-	if update.Relation.Alias != nil {
-		alias = *update.Relation.Alias.Aliasname
+// checkFromClause brings the items of a from clause into scope. It returns
+// the columns they contribute in order so a * can be expanded, and a
+// function that takes them back out of scope.
+func checkFromClause(state *State, fn Call, scope *Scope, items []pgnodes.Node) (fromCols []outputColRef, pop func(), errs []error) {
+	descend := func(node pgnodes.Node) []error {
+		return append(errs, checkCallRecurse(state, fn, scope, node)...)
 	}
-	table := *update.Relation.Relname
 
 	nTables := 0
-	if !scope.pushTable(schema, table, alias) {
-		errs = append(errs, IdentErr{
-			Schema:   schema,
-			Table:    table,
-			Location: update.Relation.Location,
-			Fn:       fn,
-		})
-	} else {
-		nTables++
-	}
-
-	for _, c := range update.TargetList.Items {
-		errs = append(errs, checkCallRecurse(state, fn, scope, c)...)
-	}
-	errs = append(errs, checkCallRecurse(state, fn, scope, update.WhereClause)...)
-
-	for i := 0; i < nTables; i++ {
-		scope.popTable()
-	}
+	nUsing := 0
 
-	return errs
-}
+	addTable := func(r pgnodes.RangeVar) []outputColRef {
+		table := *r.Relname
+		var alias, schema string
+		if r.Schemaname != nil {
+			schema = *r.Schemaname
+		}
+		if r.Alias != nil {
+			alias = *r.Alias.Aliasname
+		}
 
-func checkInsert(state *State, fn Call, scope *Scope, ins pgnodes.InsertStmt) (errs []error) {
-	var schema, alias string
-	if ins.Relation.Schemaname != nil {
-		schema = *ins.Relation.Schemaname
-	}
-	if ins.Relation.Alias != nil {
-		alias = *ins.Relation.Alias.Aliasname
-	}
-	table := *ins.Relation.Relname
+		if !scope.pushTable(schema, table, alias) {
+			errs = append(errs, IdentErr{
+				Schema:   schema,
+				Table:    table,
+				Location: r.Location,
+				Fn:       fn,
+			})
+			return nil
+		}
 
-	nTables := 0
-	if !scope.pushTable(schema, table, alias) {
-		errs = append(errs, IdentErr{
-			Schema:   schema,
-			Table:    table,
-			Location: ins.Relation.Location,
-			Fn:       fn,
-		})
-	} else {
+		errs = append(errs, schemaWarn(fn, scope, schema, table, r.Location)...)
 		nTables++
+		return tableOutputCols(scope.tables[len(scope.tables)-1])
 	}
 
-	for _, c := range ins.Cols.Items {
-		errs = append(errs, checkCallRecurse(state, fn, scope, c)...)
+	// fromItemName finds the name and location of a from item for errors
+	var fromItemName func(node pgnodes.Node) (string, int)
+	fromItemName = func(node pgnodes.Node) (string, int) {
+		switch item := node.(type) {
+		case pgnodes.RangeVar:
+			if item.Alias != nil {
+				return *item.Alias.Aliasname, item.Location
+			}
+			return *item.Relname, item.Location
+		case pgnodes.RangeSubselect:
+			return *item.Alias.Aliasname, -1
+		case pgnodes.JoinExpr:
+			_, location := fromItemName(item.Larg)
+			if item.Alias != nil {
+				return *item.Alias.Aliasname, location
+			}
+			return "", location
+		}
+		return "", -1
 	}
 
-	for i := 0; i < nTables; i++ {
-		scope.popTable()
-	}
+	// The joins are recursive in nature, each item returns the columns
+	// it contributes to the from clause in order so that a * in the
+	// select list can be expanded.
+	var addFromItem func(node pgnodes.Node) []outputColRef
+	addFromItem = func(node pgnodes.Node) []outputColRef {
+		switch item := node.(type) {
+		case pgnodes.RangeVar:
+			return addTable(item)
+		case pgnodes.JoinExpr:
+			start := len(scope.tables)
+			left := addFromItem(item.Larg)
+			mid := len(scope.tables)
+			right := addFromItem(item.Rarg)
+			end := len(scope.tables)
+
+			// The side that doesn't have to match produces nulls
+			switch item.Jointype {
+			case pgnodes.JOIN_LEFT:
+				scope.setNullable(mid, end)
+			case pgnodes.JOIN_RIGHT:
+				scope.setNullable(start, mid)
+			case pgnodes.JOIN_FULL:
+				scope.setNullable(start, end)
+			}
 
-	return errs
-}
+			restore := scope.noAggregates("JOIN conditions")
+			errs = descend(item.Quals)
+			restore()
+
+			var using []string
+			if item.IsNatural {
+				for _, l := range left {
+					for _, r := range right {
+						if l.name == r.name {
+							using = append(using, l.name)
+							break
+						}
+					}
+				}
+			}
+			for _, u := range item.UsingClause.Items {
+				using = append(using, u.(pgnodes.String).Str)
+			}
 
-func checkDelete(state *State, fn Call, scope *Scope, del pgnodes.DeleteStmt) (errs []error) {
-	var schema, alias string
-	if del.Relation.Schemaname != nil {
-		schema = *del.Relation.Schemaname
+			for _, u := range using {
+				for _, side := range []struct {
+					node pgnodes.Node
+					refs []outputColRef
+				}{{item.Larg, left}, {item.Rarg, right}} {
+					found := false
+					for _, r := range side.refs {
+						if r.name == u {
+							found = true
+							break
+						}
+					}
+
+					if !found {
+						table, location := fromItemName(side.node)
+						errs = append(errs, IdentErr{
+							Table:    table,
+							Column:   u,
+							Location: location,
+							Fn:       fn,
+						})
+					}
+				}
+			}
+
+			joined := joinOutputCols(left, right, using, item.Jointype)
+			if len(using) != 0 {
+				var merged []outputColRef
+				for _, u := range using {
+					for _, j := range joined {
+						if j.name == u {
+							merged = append(merged, j)
+							break
+						}
+					}
+				}
+
+				scope.pushUsing(merged, start, end)
+				nUsing++
+			}
+
+			if item.Alias == nil {
+				return joined
+			}
+
+			// An aliased join hides the tables in it, its columns can only
+			// be referred to through the alias
+			for len(scope.using) != 0 && scope.using[len(scope.using)-1].start >= start {
+				scope.popUsing()
+				nUsing--
+			}
+			for len(scope.tables) > start {
+				scope.popTable()
+				nTables--
+			}
+
+			joined = renameOutputCols(joined, item.Alias.Colnames)
+			pseudoTable := outputColsToPseudoTable(*item.Alias.Aliasname, joined)
+			scope.pushPseudoTable(*item.Alias.Aliasname, pseudoTable)
+			nTables++
+
+			return tableOutputCols(pseudoTable)
+		case pgnodes.RangeSubselect:
+			if item.Alias == nil {
+				panic(fmt.Sprintf("subquery: %s\nmust have alias", item.Subquery.Deparse()))
+			}
+
+			var subSelectScope *Scope
+			if item.Lateral {
+				// Lateral things have access to the outside world
+				// but we don't want to poison our current scope with
+				// its internal nonsense so clone it
+				subSelectScope = scope.clone()
+			} else {
+				subSelectScope = scope.child()
+			}
+
+			subSelect, ok := item.Subquery.(pgnodes.SelectStmt)
+			if !ok {
+				panic(fmt.Sprintf("subquery: %s\nnot a select statement", item.Subquery.Deparse()))
+			}
+
+			subSelMap, subSelectErrs := checkSelect(state, fn, subSelectScope, subSelect)
+			if len(subSelectErrs) > 0 {
+				errs = append(errs, subSelectErrs...)
+			}
+
+			subSelMap = renameOutputCols(subSelMap, item.Alias.Colnames)
+			pseudoTable := outputColsToPseudoTable(*item.Alias.Aliasname, subSelMap)
+			scope.pushPseudoTable(*item.Alias.Aliasname, pseudoTable)
+			nTables++
+
+			return tableOutputCols(pseudoTable)
+		case pgnodes.RangeFunction:
+			pseudoTable, funcErrs := checkRangeFunction(state, fn, scope, item)
+			if len(funcErrs) > 0 {
+				errs = append(errs, funcErrs...)
+			}
+
+			scope.pushPseudoTable(pseudoTable.Name, pseudoTable)
+			nTables++
+
+			return tableOutputCols(pseudoTable)
+		default:
+			panic(fmt.Sprintf("what is this weird from statement: %T", item))
+		}
 	}
-	if del.Relation.Alias != nil {
-		alias = *del.Relation.Alias.Aliasname
+
+	for _, item := range items {
+		fromCols = append(fromCols, addFromItem(item)...)
 	}
-	table := *del.Relation.Relname
+
+	pop = func() {
+		for i := 0; i < nUsing; i++ {
+			scope.popUsing()
+		}
+		for i := 0; i < nTables; i++ {
+			scope.popTable()
+		}
+	}
+
+	return fromCols, pop, errs
+}
+
+// checkWithClause checks the common table expressions of a statement and
+// brings them into scope, pop takes them back out.
+func checkWithClause(state *State, fn Call, scope *Scope, with *pgnodes.WithClause) (pop func(), errs []error) {
+	nCTEs := 0
+	if with != nil {
+		for _, c := range with.Ctes.Items {
+			cte := c.(pgnodes.CommonTableExpr)
+			refs, errList := checkCTE(state, fn, scope, cte, with.Recursive)
+			errs = append(errs, errList...)
+
+			scope.pushCTE(*cte.Ctename, outputColsToPseudoTable(*cte.Ctename, refs))
+			nCTEs++
+		}
+	}
+
+	pop = func() {
+		for i := 0; i < nCTEs; i++ {
+			scope.popCTE()
+		}
+	}
+
+	return pop, errs
+}
+
+// checkCTE checks the query of a common table expression and returns the
+// columns it produces.
+func checkCTE(state *State, fn Call, scope *Scope, cte pgnodes.CommonTableExpr, recursive bool) ([]outputColRef, []error) {
+	name := *cte.Ctename
+
+	sel, ok := cte.Ctequery.(pgnodes.SelectStmt)
+	if !ok {
+		// Data modifying statements are still checked but we don't know
+		// anything about what they return beyond the names given to us
+		errs := checkCallRecurse(state, fn, scope.child(), cte.Ctequery)
+		return renameOutputCols(nil, cte.Aliascolnames), errs
+	}
+
+	if !recursive || sel.Larg == nil || sel.Rarg == nil {
+		refs, errs := checkSelect(state, fn, scope.child(), sel)
+		return renameOutputCols(refs, cte.Aliascolnames), errs
+	}
+
+	// The non-recursive term decides the columns of a recursive query, the
+	// recursive term must then be able to refer to the query by name.
+	refs, errs := checkSelect(state, fn, scope.child(), *sel.Larg)
+	refs = renameOutputCols(refs, cte.Aliascolnames)
+
+	recScope := scope.child()
+	recScope.pushCTE(name, outputColsToPseudoTable(name, refs))
+	_, errList := checkSelect(state, fn, recScope, *sel.Rarg)
+
+	return refs, append(errs, errList...)
+}
+
+// checkRangeFunction checks the function calls in a from clause and creates
+// the table that they produce.
+func checkRangeFunction(state *State, fn Call, scope *Scope, rf pgnodes.RangeFunction) (*drivers.Table, []error) {
+	defer scope.noAggregates("functions in FROM")()
+
+	var errs []error
+	var refs []outputColRef
+	var name string
+
+	for _, f := range rf.Functions.Items {
+		// Each function is a pair of the call and its column definitions
+		// if it was part of a ROWS FROM()
+		pair := f.(pgnodes.List)
+		errs = append(errs, checkCallRecurse(state, fn, scope, pair.Items[0])...)
+
+		call, ok := pair.Items[0].(pgnodes.FuncCall)
+		if !ok {
+			refs = append(refs, outputColRef{})
+			continue
+		}
+		if len(name) == 0 {
+			name = funcCallName(call)
+		}
+
+		coldefs := rf.Coldeflist
+		if len(pair.Items) > 1 {
+			if list, ok := pair.Items[1].(pgnodes.List); ok && len(list.Items) != 0 {
+				coldefs = list
+			}
+		}
+
+		if len(coldefs.Items) == 0 {
+			refs = append(refs, setReturningFuncCols(scope, call)...)
+			continue
+		}
+
+		for _, c := range coldefs.Items {
+			def := c.(pgnodes.ColumnDef)
+			refs = append(refs, outputColRef{
+				name: *def.Colname,
+				col:  pseudoColumn(*def.Colname, typeNameDBType(*def.TypeName), !def.IsNotNull),
+			})
+		}
+	}
+
+	if rf.Ordinality {
+		refs = append(refs, outputColRef{
+			name: "ordinality",
+			col:  pseudoColumn("ordinality", "bigint", false),
+		})
+	}
+
+	if rf.Alias != nil {
+		name = *rf.Alias.Aliasname
+	}
+
+	// Scalar functions produce a column named after the table
+	for i := range refs {
+		if len(refs[i].name) == 0 {
+			refs[i].name = name
+		}
+	}
+	if rf.Alias != nil {
+		refs = renameOutputCols(refs, rf.Alias.Colnames)
+	}
+
+	return outputColsToPseudoTable(name, refs), errs
+}
+
+// splitColumnRef splits a column reference into its schema and table
+// qualifiers and the final field which is either a String or an A_Star
+func splitColumnRef(c pgnodes.ColumnRef) (schema, table string, field pgnodes.Node) {
+	ln := len(c.Fields.Items)
+	if ln >= 2 {
+		table = c.Fields.Items[ln-2].(pgnodes.String).Str
+	}
+	if ln >= 3 {
+		schema = c.Fields.Items[ln-3].(pgnodes.String).Str
+	}
+
+	return schema, table, c.Fields.Items[ln-1]
+}
+
+func checkUpdate(state *State, fn Call, scope *Scope, update pgnodes.UpdateStmt) (errs []error) {
+	popCTEs, errs := checkWithClause(state, fn, scope, update.WithClause)
+	defer popCTEs()
+
+	var schema, alias string
+	if update.Relation.Schemaname != nil {
+		schema = *update.Relation.Schemaname
+	}
+	if update.Relation.Alias != nil {
+		alias = *update.Relation.Alias.Aliasname
+	}
+	table := *update.Relation.Relname
 
 	nTables := 0
 	if !scope.pushTable(schema, table, alias) {
 		errs = append(errs, IdentErr{
 			Schema:   schema,
 			Table:    table,
-			Location: del.Relation.Location,
+			Location: update.Relation.Location,
 			Fn:       fn,
 		})
 	} else {
+		errs = append(errs, schemaWarn(fn, scope, schema, table, update.Relation.Location)...)
+		errs = append(errs, checkWritable(state, fn, scope, "UPDATE", update.Relation.Location)...)
 		nTables++
 	}
 
-	errs = append(errs, checkCallRecurse(state, fn, scope, del.WhereClause)...)
+	into := len(scope.tables) - 1
+
+	// Aggregates aren't allowed anywhere in an update
+	defer scope.noAggregates("UPDATE")()
+
+	// The from clause can be used by the whole statement except the names
+	// of the columns being set which always belong to the updated table
+	_, popFrom, errList := checkFromClause(state, fn, scope, update.FromClause.Items)
+	errs = append(errs, errList...)
 
+	if nTables != 0 {
+		errs = append(errs, checkSetList(state, fn, scope, schema, into, update.TargetList)...)
+	} else {
+		for _, c := range update.TargetList.Items {
+			errs = append(errs, checkCallRecurse(state, fn, scope, c)...)
+		}
+	}
+	errs = append(errs, checkCallRecurse(state, fn, scope, update.WhereClause)...)
+	errs = append(errs, checkReturning(state, fn, scope, update.ReturningList)...)
+
+	popFrom()
 	for i := 0; i < nTables; i++ {
 		scope.popTable()
 	}
@@ -530,124 +1130,268 @@ func checkDelete(state *State, fn Call, scope *Scope, del pgnodes.DeleteStmt) (e
 	return errs
 }
 
-func typeCheck(s *State, fn Call, scope *Scope, lhs, rhs pgnodes.Node) error {
-	if lhs == nil || rhs == nil {
-		return nil
+// checkSetList checks the SET list of an UPDATE or an ON CONFLICT DO UPDATE.
+// The columns being set belong to the table at index into in the scope,
+// schema is the table's schema as the statement wrote it.
+func checkSetList(state *State, fn Call, scope *Scope, schema string, into int, targets pgnodes.List) (errs []error) {
+	t := scope.tables[into]
+
+	for _, c := range targets.Items {
+		target := c.(pgnodes.ResTarget)
+		index := columnIndex(t, *target.Name)
+		if index < 0 {
+			errs = append(errs, IdentErr{
+				Column:   *target.Name,
+				Location: target.Location,
+				Fn:       fn,
+			})
+		}
+		for _, ind := range target.Indirection.Items {
+			errs = append(errs, checkCallRecurse(state, fn, scope, ind)...)
+		}
+		errs = append(errs, checkCallRecurse(state, fn, scope, target.Val)...)
+		if index < 0 {
+			continue
+		}
+		errs = append(errs, checkUpdateWrite(state, fn, scope, schema, into, target)...)
+
+		col := &t.Columns[index]
+		use := paramUse{
+			column:   *target.Name,
+			source:   scope.columnKey(col),
+			col:      col,
+			coercion: coerceAssignment,
+			stored:   true,
+		}
+		if len(target.Indirection.Items) != 0 {
+			dbType, ok := assignedDBType(col.DBType, target.Indirection)
+			if !ok {
+				continue
+			}
+			for _, i := range target.Indirection.Items {
+				indices := i.(pgnodes.A_Indices)
+				expectDBType(scope, indices.Lidx, "integer", "array subscript", coerceAssignment)
+				expectDBType(scope, indices.Uidx, "integer", "array subscript", coerceAssignment)
+			}
+			if dbType != col.DBType {
+				use.source = ""
+				use.col = pseudoColumn(col.Name, dbType, true)
+			}
+		}
+		expectType(scope, target.Val, use)
 	}
 
-	var c *pgnodes.ColumnRef
-	var p *pgnodes.ParamRef
+	return errs
+}
+
+func checkInsert(state *State, fn Call, scope *Scope, ins pgnodes.InsertStmt) (errs []error) {
+	popCTEs, errs := checkWithClause(state, fn, scope, ins.WithClause)
+	defer popCTEs()
 
-	if col, ok := lhs.(pgnodes.ColumnRef); ok {
-		c = &col
+	var schema, alias string
+	if ins.Relation.Schemaname != nil {
+		schema = *ins.Relation.Schemaname
 	}
-	if col, ok := rhs.(pgnodes.ColumnRef); ok {
-		c = &col
+	if ins.Relation.Alias != nil {
+		alias = *ins.Relation.Alias.Aliasname
 	}
+	table := *ins.Relation.Relname
 
-	if param, ok := lhs.(pgnodes.ParamRef); ok {
-		p = &param
+	nTables := 0
+	if !scope.pushTable(schema, table, alias) {
+		errs = append(errs, IdentErr{
+			Schema:   schema,
+			Table:    table,
+			Location: ins.Relation.Location,
+			Fn:       fn,
+		})
+	} else {
+		errs = append(errs, schemaWarn(fn, scope, schema, table, ins.Relation.Location)...)
+		errs = append(errs, checkWritable(state, fn, scope, "INSERT", ins.Relation.Location)...)
+		nTables++
 	}
-	if param, ok := rhs.(pgnodes.ParamRef); ok {
-		p = &param
+
+	for _, c := range ins.Cols.Items {
+		errs = append(errs, checkCallRecurse(state, fn, scope, c)...)
+	}
+	restore := scope.noAggregates("RETURNING")
+	errs = append(errs, checkReturning(state, fn, scope, ins.ReturningList)...)
+	restore()
+
+	// The values or select can't see the table being inserted into
+	errs = append(errs, checkCallRecurse(state, fn, scope.child(), ins.SelectStmt)...)
+
+	// Values are inserted into the columns by position
+	if values, ok := ins.SelectStmt.(pgnodes.SelectStmt); ok && nTables != 0 {
+		into := scope.tables[len(scope.tables)-1]
+		cols := insertColumns(into, ins.Cols)
+		for _, row := range values.ValuesLists {
+			for i, expr := range row {
+				if i < len(cols) && cols[i] != nil {
+					expectType(scope, expr, paramUse{
+						column:   cols[i].Name,
+						source:   scope.columnKey(cols[i]),
+						col:      cols[i],
+						coercion: coerceAssignment,
+						stored:   true,
+					})
+				}
+			}
+		}
+	}
+	if nTables != 0 {
+		into := scope.tables[len(scope.tables)-1]
+		errs = append(errs, checkInsertNulls(state, fn, ins, into)...)
+		errs = append(errs, checkInsertWrites(state, fn, scope, ins, into)...)
 	}
 
-	if c == nil || p == nil {
-		return nil
+	for i := 0; i < nTables; i++ {
+		scope.popTable()
+	}
+
+	if ins.OnConflictClause != nil && nTables != 0 {
+		errs = append(errs, checkOnConflict(state, fn, scope, schema, table, alias, *ins.OnConflictClause)...)
 	}
 
-	offset := 0
+	return errs
+}
+
+// checkOnConflict checks the SET list and WHERE of an ON CONFLICT DO UPDATE.
+// The row proposed for insertion can be used as EXCLUDED but only by name,
+// so it sits a level behind the table being inserted into.
+func checkOnConflict(state *State, fn Call, scope *Scope, schema, table, alias string, conflict pgnodes.OnConflictClause) (errs []error) {
+	scope.pushTable(schema, table, alias)
+	excluded := outputColsToPseudoTable("excluded", tableOutputCols(scope.tables[len(scope.tables)-1]))
+	scope.popTable()
+
+	scope.pushPseudoTable("excluded", excluded)
+	scope.pushLevel()
+	scope.pushTable(schema, table, alias)
+	defer func() {
+		scope.popTable()
+		scope.popLevel()
+		scope.popTable()
+	}()
+
+	defer scope.noAggregates("ON CONFLICT")()
 
-	var schema, table string
-	if len(c.Fields.Items) == 3 {
-		schema = c.Fields.Items[0].(pgnodes.String).Str
-		table = c.Fields.Items[1].(pgnodes.String).Str
-		offset += 2
-	} else if len(c.Fields.Items) == 2 {
-		table = c.Fields.Items[0].(pgnodes.String).Str
-		offset += 1
+	errs = append(errs, checkSetList(state, fn, scope, schema, len(scope.tables)-1, conflict.TargetList)...)
+	errs = append(errs, checkCallRecurse(state, fn, scope, conflict.WhereClause)...)
+
+	return errs
+}
+
+func checkDelete(state *State, fn Call, scope *Scope, del pgnodes.DeleteStmt) (errs []error) {
+	popCTEs, errs := checkWithClause(state, fn, scope, del.WithClause)
+	defer popCTEs()
+
+	var schema, alias string
+	if del.Relation.Schemaname != nil {
+		schema = *del.Relation.Schemaname
 	}
-	var column string
+	if del.Relation.Alias != nil {
+		alias = *del.Relation.Alias.Aliasname
+	}
+	table := *del.Relation.Relname
 
-	switch item := c.Fields.Items[offset].(type) {
-	case pgnodes.String:
-		column = item.Str
-	default:
-		panic(fmt.Sprintf("type check against weird node type: %T", c.Fields.Items[1]))
+	nTables := 0
+	if !scope.pushTable(schema, table, alias) {
+		errs = append(errs, IdentErr{
+			Schema:   schema,
+			Table:    table,
+			Location: del.Relation.Location,
+			Fn:       fn,
+		})
+	} else {
+		errs = append(errs, schemaWarn(fn, scope, schema, table, del.Relation.Location)...)
+		errs = append(errs, checkWritable(state, fn, scope, "DELETE", del.Relation.Location)...)
+		nTables++
 	}
 
-	col, ret := scope.get(schema, table, column)
-	if ret != scopeRetOk {
-		// It's the job of a different function to do unknown/ambiguous errors
-		// it's unfortunate that scope.get will be called again but it's less
-		// bad than duplicating the errors.
-		return nil
-	} else if col == nil {
-		// This is possible even with a scopeRetOk in the odd case where someone
-		// aliased an expression in a select statement (select 't' as hello)
-		// and then used that in a groupby/orderby in some expression involving
-		// a parameter.
-		return nil
+	restore := scope.noAggregates("DELETE")
+	_, popUsing, errList := checkFromClause(state, fn, scope, del.UsingClause.Items)
+	errs = append(errs, errList...)
+	errs = append(errs, checkCallRecurse(state, fn, scope, del.WhereClause)...)
+	errs = append(errs, checkReturning(state, fn, scope, del.ReturningList)...)
+	restore()
+
+	popUsing()
+	for i := 0; i < nTables; i++ {
+		scope.popTable()
 	}
 
-	if p.Number-1 >= len(fn.ArgTypes) {
-		return TypeErr{
-			Schema:     schema,
-			Table:      table,
-			Column:     column,
-			CallType:   "<none>",
-			DriverType: col.Type,
-			DBType:     col.DBType,
-			Parameter:  p.Number,
-			Location:   p.Location,
-			Fn:         fn,
+	return errs
+}
+
+// insertColumns returns the columns an insert statement inserts into in
+// order. Columns that don't exist are nil.
+func insertColumns(table *drivers.Table, names pgnodes.List) []*drivers.Column {
+	if len(names.Items) == 0 {
+		cols := make([]*drivers.Column, len(table.Columns))
+		for i := range table.Columns {
+			cols[i] = &table.Columns[i]
 		}
+		return cols
 	}
-	// argType is something like database/sql.NullBool or int
-	argType := fn.ArgTypes[p.Number-1]
-
-	// We need to normalize our type to be equivalent to argType
-	normalizedType := col.Type
-	if splits := strings.Split(col.Type, "."); len(splits) > 1 {
-		// This is a type from a package, try to resolve it
-		imports := s.Imports.BasedOnType[col.Type]
-		var imp string
-
-		allImps := make([]string, len(imports.Standard)+len(imports.ThirdParty))
-		copy(allImps, imports.Standard)
-		copy(allImps[len(imports.Standard):], imports.ThirdParty)
-
-		for _, i := range allImps {
-			noQuotes := strings.Trim(i, `"`)
-			if splits[1] != path.Base(noQuotes) {
-				continue
-			}
 
-			packageDir := path.Dir(noQuotes)
-			imp = path.Join(packageDir, col.Type)
-			break
+	cols := make([]*drivers.Column, len(names.Items))
+	for i, n := range names.Items {
+		name := *n.(pgnodes.ResTarget).Name
+		for j, c := range table.Columns {
+			if c.Name == name {
+				cols[i] = &table.Columns[j]
+				break
+			}
 		}
+	}
 
-		if len(imp) == 0 {
-			return fmt.Errorf("failed to lookup package for driver type: %s", col.Type)
-		}
+	return cols
+}
+
+// checkReturning checks the expressions of a RETURNING clause. Unlike the
+// target list of an update statement the names here are output names and
+// not columns.
+func checkReturning(state *State, fn Call, scope *Scope, returning pgnodes.List) (errs []error) {
+	for _, r := range returning.Items {
+		errs = append(errs, checkCallRecurse(state, fn, scope, r.(pgnodes.ResTarget).Val)...)
 	}
 
-	if argType != normalizedType {
-		return TypeErr{
-			Schema:     schema,
-			Table:      table,
-			Column:     column,
-			CallType:   argType,
-			DriverType: col.Type,
-			DBType:     col.DBType,
-			Parameter:  p.Number,
-			Location:   p.Location,
-			Fn:         fn,
-		}
+	return errs
+}
+
+// typeCheck compares the Go argument given for a parameter against the type
+// it's used as in the statement.
+func typeCheck(s *State, fn Call, number int, use paramUse) error {
+	col := use.col
+	if col == nil {
+		return nil
 	}
 
-	return nil
+	// Missing arguments are found by checkArity
+	if number-1 >= len(fn.ArgTypes) {
+		return nil
+	}
+
+	// argType is something like database/sql.NullBool or int
+	argType := fn.ArgTypes[number-1]
+	if typeAliased(s.TypeAliases, use.source, col.DBType, argType) {
+		return nil
+	}
+	if goValueCompatible(classifyGoType(argGoType(fn, number-1)), col.DBType) {
+		return nil
+	}
+
+	return TypeErr{
+		Schema:     use.schema,
+		Table:      use.table,
+		Column:     use.column,
+		CallType:   argType,
+		DriverType: col.Type,
+		DBType:     col.DBType,
+		Parameter:  number,
+		Location:   use.location,
+		Fn:         fn,
+	}
 }
 
 // Scope keeps track of tables that are in scope (and transitively the columns
@@ -656,11 +1400,52 @@ type Scope struct {
 	// The DB Info to check against
 	// when adding something to scope
 	info *drivers.DBInfo
+	// searchPath is the schemas unqualified table names are found in
+	searchPath []string
+	// infoSchemas is shared by clones so the database info is only indexed
+	// once
+	infoSchemas *tableSchemas
 
 	// The objects in scope
 	tables      []*drivers.Table
 	aliases     []string
 	outputNames []outputColRef
+
+	// Common table expressions that can be used as tables
+	ctes []*drivers.Table
+
+	// nullable is parallel to tables and is true when the table is on the
+	// side of an outer join that can produce nulls.
+	nullable []bool
+	// Columns merged by a USING clause which are not ambiguous despite
+	// being present in more than one table.
+	using []scopeUsing
+	// levels are indexes into tables where each nested select's tables
+	// begin, inner selects shadow the columns of outer ones.
+	levels []int
+
+	// The parameters used in the statement, this is shared with all
+	// clones and children
+	params paramSet
+	// The constants used where a type was expected, shared like params
+	literals *[]literalUse
+
+	// aggClause is the clause being checked if aggregates aren't allowed
+	// in it, inAggregate is set while checking an aggregate's arguments.
+	aggClause   string
+	inAggregate bool
+
+	// windowClause is the clause being checked if window functions aren't
+	// allowed in it when aggregates are, windows are the names of the
+	// current select's WINDOW clause.
+	windowClause string
+	windows      []string
+}
+
+// scopeUsing is the merged columns of the tables in the range [start, end)
+type scopeUsing struct {
+	cols       []outputColRef
+	start, end int
 }
 
 type outputColRef struct {
@@ -668,6 +1453,125 @@ type outputColRef struct {
 	col  *drivers.Column
 }
 
+// tableOutputCols returns every column of a table in order, what a * would
+// expand to.
+func tableOutputCols(t *drivers.Table) []outputColRef {
+	refs := make([]outputColRef, len(t.Columns))
+	for i, c := range t.Columns {
+		refs[i] = outputColRef{name: c.Name, col: &t.Columns[i]}
+	}
+
+	return refs
+}
+
+// joinOutputCols merges the columns of both sides of a join the same way
+// postgres does. Columns named in a USING clause are merged into a single
+// column and come first, followed by the rest of the left side's columns
+// and then the right side's. Columns on the side of an outer join that does
+// not need to match become nullable.
+func joinOutputCols(left, right []outputColRef, using []string, joinType pgnodes.JoinType) []outputColRef {
+	isUsing := func(name string) bool {
+		for _, u := range using {
+			if u == name {
+				return true
+			}
+		}
+		return false
+	}
+	find := func(refs []outputColRef, name string) (outputColRef, bool) {
+		for _, r := range refs {
+			if r.name == name {
+				return r, true
+			}
+		}
+		return outputColRef{}, false
+	}
+
+	refs := make([]outputColRef, 0, len(left)+len(right))
+	for _, u := range using {
+		l, lok := find(left, u)
+		r, rok := find(right, u)
+
+		switch {
+		case !lok && !rok:
+			continue
+		case !lok:
+			refs = append(refs, r)
+		case !rok:
+			refs = append(refs, l)
+		case joinType == pgnodes.JOIN_RIGHT:
+			refs = append(refs, r)
+		case joinType == pgnodes.JOIN_FULL && l.col != nil && r.col != nil:
+			// A full join merges the columns with coalesce so it's only
+			// null when both sides can be
+			merged := *l.col
+			merged.Nullable = l.col.Nullable && r.col.Nullable
+			refs = append(refs, outputColRef{name: l.name, col: &merged})
+		default:
+			refs = append(refs, l)
+		}
+	}
+
+	var rest []outputColRef
+	for _, r := range left {
+		if !isUsing(r.name) {
+			rest = append(rest, r)
+		}
+	}
+	if joinType == pgnodes.JOIN_RIGHT || joinType == pgnodes.JOIN_FULL {
+		rest = nullableOutputCols(rest)
+	}
+	refs = append(refs, rest...)
+
+	rest = nil
+	for _, r := range right {
+		if !isUsing(r.name) {
+			rest = append(rest, r)
+		}
+	}
+	if joinType == pgnodes.JOIN_LEFT || joinType == pgnodes.JOIN_FULL {
+		rest = nullableOutputCols(rest)
+	}
+	refs = append(refs, rest...)
+
+	return refs
+}
+
+// nullableOutputCols returns a copy of the output columns where every column
+// is nullable.
+func nullableOutputCols(refs []outputColRef) []outputColRef {
+	nullable := make([]outputColRef, len(refs))
+	for i, r := range refs {
+		nullable[i] = r
+		if r.col != nil && !r.col.Nullable {
+			col := *r.col
+			col.Nullable = true
+			nullable[i].col = &col
+		}
+	}
+
+	return nullable
+}
+
+// renameOutputCols applies a list of column aliases like those in
+// "as t(a, b)" to output columns. Postgres allows fewer aliases than columns
+// in which case the rest keep their names.
+func renameOutputCols(refs []outputColRef, names pgnodes.List) []outputColRef {
+	renamed := make([]outputColRef, len(refs))
+	copy(renamed, refs)
+
+	for i, n := range names.Items {
+		name := n.(pgnodes.String).Str
+		if i < len(renamed) {
+			renamed[i].name = name
+		} else {
+			renamed = append(renamed, outputColRef{name: name})
+		}
+	}
+
+	return renamed
+}
+
 func outputColsToPseudoTable(name string, refs []outputColRef) *drivers.Table {
 	table := &drivers.Table{Name: name}
 
@@ -687,7 +1591,10 @@ func outputColsToPseudoTable(name string, refs []outputColRef) *drivers.Table {
 // NewScope creates a new object for keeping track of tables in scope
 func NewScope(info *drivers.DBInfo) *Scope {
 	return &Scope{
-		info: info,
+		info:        info,
+		infoSchemas: new(tableSchemas),
+		params:      make(paramSet),
+		literals:    new([]literalUse),
 	}
 }
 
@@ -696,48 +1603,361 @@ func NewScope(info *drivers.DBInfo) *Scope {
 func (s *Scope) clone() *Scope {
 	cloned := new(Scope)
 	cloned.info = s.info
+	cloned.searchPath = s.searchPath
+	cloned.infoSchemas = s.infoSchemas
+	cloned.params = s.params
+	cloned.literals = s.literals
 	cloned.tables = make([]*drivers.Table, len(s.tables))
 	cloned.aliases = make([]string, len(s.aliases))
 	cloned.outputNames = make([]outputColRef, len(s.outputNames))
+	cloned.nullable = make([]bool, len(s.nullable))
+	cloned.using = make([]scopeUsing, len(s.using))
+	copy(cloned.nullable, s.nullable)
+	copy(cloned.using, s.using)
+	cloned.levels = make([]int, len(s.levels))
+	copy(cloned.levels, s.levels)
 	copy(cloned.tables, s.tables)
 	copy(cloned.aliases, s.aliases)
 	copy(cloned.outputNames, s.outputNames)
+	cloned.ctes = make([]*drivers.Table, len(s.ctes))
+	copy(cloned.ctes, s.ctes)
 	return cloned
 }
 
+// child creates a scope for an uncorrelated subquery, none of the tables
+// are shared but common table expressions are still visible.
+func (s *Scope) child() *Scope {
+	child := NewScope(s.info)
+	child.searchPath = s.searchPath
+	child.params = s.params
+	child.literals = s.literals
+	child.ctes = make([]*drivers.Table, len(s.ctes))
+	copy(child.ctes, s.ctes)
+	return child
+}
+
 // pushTable adds the table to the current scope. If it fails that means
 // the database info did not contain that table.
 func (s *Scope) pushTable(schema, table, alias string) bool {
 	debugf("PUSH: s(%s) t(%s) a(%s)\n", schema, table, alias)
-	for i, t := range s.info.Tables {
-		if len(schema) != 0 {
-			if t.SchemaName != schema {
-				continue
+
+	// Common table expressions shadow real tables, but can never
+	// be qualified by a schema
+	if len(schema) == 0 {
+		for i := len(s.ctes) - 1; i >= 0; i-- {
+			if s.ctes[i].Name == table {
+				s.aliases = append(s.aliases, alias)
+				s.tables = append(s.tables, s.ctes[i])
+				s.nullable = append(s.nullable, false)
+				return true
 			}
 		}
+	}
 
-		if t.Name == table {
-			s.aliases = append(s.aliases, alias)
-			s.tables = append(s.tables, &s.info.Tables[i])
-			return true
+	// Unqualified names are looked up in each schema on the search path
+	// in order, the first one that has the table wins. Like postgres
+	// temporary tables and then the system catalog are searched first
+	// unless the path says otherwise.
+	schemas := []string{schema}
+	if len(schema) == 0 {
+		schemas = s.schemas()
+		for _, implicit := range []string{"pg_catalog", "pg_temp"} {
+			onPath := false
+			for _, sch := range schemas {
+				onPath = onPath || sch == implicit
+			}
+			if !onPath {
+				schemas = append([]string{implicit}, schemas...)
+			}
 		}
 	}
 
+	for _, sch := range schemas {
+		t := s.findTable(sch, table)
+		if t == nil {
+			continue
+		}
+
+		s.aliases = append(s.aliases, alias)
+		s.tables = append(s.tables, t)
+		s.nullable = append(s.nullable, false)
+		return true
+	}
+
 	return false
 }
 
+// findTable finds a table in the database info or the system catalog
+func (s *Scope) findTable(schema, table string) *drivers.Table {
+	for i := range s.info.Tables {
+		if t := &s.info.Tables[i]; t.Name == table && s.schemaOf(t) == schema {
+			return t
+		}
+	}
+
+	return systemTable(schema, table)
+}
+
+// schemas returns the search path, it defaults to the schema the driver
+// was configured with. Entries like $user can't be known and are skipped.
+func (s *Scope) schemas() []string {
+	if len(s.searchPath) == 0 {
+		if len(s.info.Schema) != 0 {
+			return []string{s.info.Schema}
+		}
+		return []string{"public"}
+	}
+
+	schemas := make([]string, 0, len(s.searchPath))
+	for _, sch := range s.searchPath {
+		if !strings.HasPrefix(sch, "$") {
+			schemas = append(schemas, sch)
+		}
+	}
+	return schemas
+}
+
+// schemaOf returns the schema of a table from the database info, they're
+// in the driver's schema unless they say otherwise. Tables that aren't in
+// the database info or system catalog like common table expressions have
+// no schema.
+func (s *Scope) schemaOf(t *drivers.Table) string {
+	schema, ok := s.infoSchemas.get(s.info)[t]
+	if !ok && isSystemTable(t) {
+		return t.SchemaName
+	}
+
+	return schema
+}
+
+// tableSchemas is the schema of each table in the database info. DDL that
+// adds or drops tables moves them around so it's rebuilt when the tables
+// aren't the ones it was built from.
+type tableSchemas struct {
+	first   *drivers.Table
+	n       int
+	schemas map[*drivers.Table]string
+}
+
+// get returns the schemas of the info's tables
+func (ts *tableSchemas) get(info *drivers.DBInfo) map[*drivers.Table]string {
+	var first *drivers.Table
+	if len(info.Tables) != 0 {
+		first = &info.Tables[0]
+	}
+	if ts.schemas != nil && ts.first == first && ts.n == len(info.Tables) {
+		return ts.schemas
+	}
+
+	ts.first, ts.n = first, len(info.Tables)
+	ts.schemas = make(map[*drivers.Table]string, len(info.Tables))
+	for i := range info.Tables {
+		t := &info.Tables[i]
+		ts.schemas[t] = tableSchema(info, t)
+	}
+
+	return ts.schemas
+}
+
+// tableSchema returns the schema of one of the info's tables, the info's
+// schema unless the table names its own.
+func tableSchema(info *drivers.DBInfo, t *drivers.Table) string {
+	if len(t.SchemaName) != 0 {
+		return t.SchemaName
+	}
+	if len(info.Schema) != 0 {
+		return info.Schema
+	}
+
+	return "public"
+}
+
+// schemasWith returns the schemas on the search path that have a table
+// with the name.
+func (s *Scope) schemasWith(table string) []string {
+	var found []string
+	for _, sch := range s.schemas() {
+		for i := range s.info.Tables {
+			if t := &s.info.Tables[i]; t.Name == table && s.schemaOf(t) == sch {
+				found = append(found, sch)
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+// identSchema returns the schema of the table a column reference was
+// resolved against so errors can show it even when it wasn't written.
+func (s *Scope) identSchema(schema, table string) string {
+	if len(schema) != 0 || len(table) == 0 {
+		return schema
+	}
+	if t := s.getTable("", table); t != nil {
+		return s.schemaOf(t)
+	}
+
+	return ""
+}
+
+// schemaWarn warns about an unqualified table name that's in more than
+// one schema on the search path.
+func schemaWarn(fn Call, scope *Scope, schema, table string, location int) []error {
+	if len(schema) != 0 {
+		return nil
+	}
+	for _, cte := range scope.ctes {
+		if cte.Name == table {
+			return nil
+		}
+	}
+
+	schemas := scope.schemasWith(table)
+	if len(schemas) < 2 {
+		return nil
+	}
+
+	return []error{SchemaWarn{Table: table, Schemas: schemas, Location: location, Fn: fn}}
+}
+
 // pushPseudoTable is used for when we need to create a new table
 // out of thin air. Useful for subqueries etc.
 func (s *Scope) pushPseudoTable(alias string, data *drivers.Table) {
 	debugf("PUSH(P): a(%s)\n", alias)
 	s.aliases = append(s.aliases, alias)
 	s.tables = append(s.tables, data)
+	s.nullable = append(s.nullable, false)
 }
 
 func (s *Scope) popTable() {
 	debugf("POP: t(%s) a(%s)\n", s.tables[len(s.tables)-1].Name, s.aliases[len(s.aliases)-1])
 	s.aliases = s.aliases[:len(s.aliases)-1]
 	s.tables = s.tables[:len(s.tables)-1]
+	s.nullable = s.nullable[:len(s.nullable)-1]
+}
+
+// pushLevel starts a new nested select, tables pushed after this shadow the
+// columns of those pushed before it.
+func (s *Scope) pushLevel() {
+	s.levels = append(s.levels, len(s.tables))
+}
+
+func (s *Scope) popLevel() {
+	s.levels = s.levels[:len(s.levels)-1]
+}
+
+// setNullable marks the tables in the range [start, end) as being on the
+// nullable side of an outer join
+func (s *Scope) setNullable(start, end int) {
+	for i := start; i < end; i++ {
+		s.nullable[i] = true
+	}
+}
+
+// outputColumn returns the column a reference resolved to as it would be
+// output from the current scope, that is a nullable copy if the table it
+// came from is on the nullable side of an outer join. Tables are told apart
+// by their place in the scope since both sides of a self join share one.
+func (s *Scope) outputColumn(schema, table, column string, col *drivers.Column) *drivers.Column {
+	if col == nil || col.Nullable {
+		return col
+	}
+
+	index := s.columnSource(schema, table, column)
+	if index < 0 || !s.nullable[index] {
+		return col
+	}
+
+	// Columns merged by USING aren't the table's own
+	t := s.tables[index]
+	for j := range t.Columns {
+		if &t.Columns[j] == col {
+			nullable := *col
+			nullable.Nullable = true
+			return &nullable
+		}
+	}
+
+	return col
+}
+
+// noAggregates disallows aggregates in the clause being checked, an empty
+// clause allows them. It returns a function that restores what was allowed
+// before.
+func (s *Scope) noAggregates(clause string) (restore func()) {
+	old := s.aggClause
+	s.aggClause = clause
+	return func() { s.aggClause = old }
+}
+
+// columnKey finds the table a column belongs to and returns
+// schema.table.column or the empty string if it's not a column of a real
+// table.
+func (s *Scope) columnKey(col *drivers.Column) string {
+	for i := range s.info.Tables {
+		t := &s.info.Tables[i]
+		for j := range t.Columns {
+			if &t.Columns[j] == col {
+				return s.schemaOf(t) + "." + t.Name + "." + col.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// pushUsing records the columns merged by a USING clause between the tables
+// in the range [start, end)
+func (s *Scope) pushUsing(cols []outputColRef, start, end int) {
+	s.using = append(s.using, scopeUsing{cols: cols, start: start, end: end})
+}
+
+func (s *Scope) popUsing() {
+	s.using = s.using[:len(s.using)-1]
+}
+
+// merged checks if all the tables (indexes into s.tables) were joined with a
+// USING clause that merged the column and returns the merged column
+func (s *Scope) merged(column string, tables []int) (*drivers.Column, bool) {
+	for _, u := range s.using {
+		var col *drivers.Column
+		found := false
+		for _, c := range u.cols {
+			if c.name == column {
+				col = c.col
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		all := true
+		for _, t := range tables {
+			if t < u.start || t >= u.end {
+				all = false
+				break
+			}
+		}
+		if all {
+			return col, true
+		}
+	}
+
+	return nil, false
+}
+
+// pushCTE makes a common table expression available to pushTable
+func (s *Scope) pushCTE(name string, data *drivers.Table) {
+	debugf("PUSH(CTE): n(%s)\n", name)
+	s.ctes = append(s.ctes, data)
+}
+
+func (s *Scope) popCTE() {
+	debugf("POP(CTE): n(%s)\n", s.ctes[len(s.ctes)-1].Name)
+	s.ctes = s.ctes[:len(s.ctes)-1]
 }
 
 func (s *Scope) pushOutputName(name string, col *drivers.Column) {
@@ -760,22 +1980,7 @@ func (s *Scope) get(schema, table, column string) (*drivers.Column, int) {
 	if len(table) != 0 {
 		// Providing a table name means we know exactly what we're looking for
 		// and if it's something we've aliased even more so.
-		var inScope *drivers.Table
-		for i, t := range s.tables {
-			if s.aliases[i] == table {
-				inScope = t
-				break
-			}
-
-			if len(schema) != 0 && t.SchemaName != schema {
-				continue
-			}
-
-			if t.Name == table {
-				inScope = t
-				break
-			}
-		}
+		inScope := s.getTable(schema, table)
 
 		tname := ""
 		if inScope != nil {
@@ -788,13 +1993,26 @@ func (s *Scope) get(schema, table, column string) (*drivers.Column, int) {
 			return nil, scopeRetUnknown
 		}
 
+		// Real tables can't have duplicate column names but subqueries
+		// like (select * from a, b) can
+		var col *drivers.Column
 		for i, c := range inScope.Columns {
 			if c.Name == column {
-				return &inScope.Columns[i], scopeRetOk
+				if col != nil {
+					return nil, scopeRetAmbiguous
+				}
+				col = &inScope.Columns[i]
 			}
 		}
 
-		return nil, scopeRetUnknown
+		// Only relations have system columns, not subqueries or CTEs
+		if col == nil && len(s.schemaOf(inScope)) != 0 {
+			col = systemColumn(column)
+		}
+		if col == nil {
+			return nil, scopeRetUnknown
+		}
+		return col, scopeRetOk
 	}
 
 	// They did not provide a table name at all, so we're going to have to
@@ -802,17 +2020,54 @@ func (s *Scope) get(schema, table, column string) (*drivers.Column, int) {
 	// if there's an ambiguous identifier
 	ret := scopeRetUnknown
 
+	// Search from the innermost select outwards, only columns found at the
+	// same level can be ambiguous.
 	var col *drivers.Column
-	for _, inScope := range s.tables {
-		for i, c := range inScope.Columns {
-			if c.Name == column {
-				if ret == scopeRetOk {
-					return nil, scopeRetAmbiguous
+	end := len(s.tables)
+	for l := len(s.levels) - 1; l >= -1 && ret == scopeRetUnknown; l-- {
+		start := 0
+		if l >= 0 {
+			start = s.levels[l]
+		}
+
+		var matched []int
+		for t := start; t < end; t++ {
+			for i, c := range s.tables[t].Columns {
+				if c.Name == column {
+					if ret != scopeRetOk {
+						col = &s.tables[t].Columns[i]
+						ret = scopeRetOk
+					}
+					matched = append(matched, t)
 				}
-				col = &inScope.Columns[i]
-				ret = scopeRetOk
 			}
 		}
+
+		if len(matched) > 1 {
+			merged, ok := s.merged(column, matched)
+			if !ok {
+				return nil, scopeRetAmbiguous
+			}
+			col = merged
+		}
+
+		// System columns are ambiguous when more than one relation at the
+		// level has them
+		if sysCol := systemColumn(column); ret == scopeRetUnknown && sysCol != nil {
+			relations := 0
+			for t := start; t < end; t++ {
+				if len(s.schemaOf(s.tables[t])) != 0 {
+					relations++
+				}
+			}
+			switch {
+			case relations > 1:
+				return nil, scopeRetAmbiguous
+			case relations == 1:
+				col, ret = sysCol, scopeRetOk
+			}
+		}
+		end = start
 	}
 
 	// Finally check the outputNames to see if the column identifier is there
@@ -825,6 +2080,72 @@ func (s *Scope) get(schema, table, column string) (*drivers.Column, int) {
 	return col, ret
 }
 
+// getTable finds a table in scope by its alias or name, the innermost
+// table wins.
+func (s *Scope) getTable(schema, table string) *drivers.Table {
+	if i := s.tableIndex(schema, table); i >= 0 {
+		return s.tables[i]
+	}
+
+	return nil
+}
+
+// tableIndex is getTable but returns the table's index in the scope or -1
+func (s *Scope) tableIndex(schema, table string) int {
+	for i := len(s.tables) - 1; i >= 0; i-- {
+		t := s.tables[i]
+		if s.aliases[i] == table {
+			return i
+		}
+
+		if len(schema) != 0 && s.schemaOf(t) != schema {
+			continue
+		}
+
+		if t.Name == table {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// columnSource returns the index of the table in scope a column reference
+// resolves to, or -1 if it isn't a column of one like an output name.
+// Ambiguous references resolve to the first table that has the column.
+func (s *Scope) columnSource(schema, table, column string) int {
+	has := func(i int) bool {
+		t := s.tables[i]
+		if columnIndex(t, column) >= 0 {
+			return true
+		}
+		return systemColumn(column) != nil && len(s.schemaOf(t)) != 0
+	}
+
+	if len(table) != 0 {
+		if i := s.tableIndex(schema, table); i >= 0 && has(i) {
+			return i
+		}
+		return -1
+	}
+
+	end := len(s.tables)
+	for l := len(s.levels) - 1; l >= -1; l-- {
+		start := 0
+		if l >= 0 {
+			start = s.levels[l]
+		}
+		for i := start; i < end; i++ {
+			if has(i) {
+				return i
+			}
+		}
+		end = start
+	}
+
+	return -1
+}
+
 func (s *Scope) has(schema, table, column string) int {
 	_, ret := s.get(schema, table, column)
 	return ret
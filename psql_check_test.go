@@ -2,12 +2,22 @@ package main
 
 import (
 	"flag"
+	"go/constant"
 	"go/token"
+	"go/types"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/volatiletech/sqlboiler/v4/drivers"
 	"github.com/volatiletech/sqlboiler/v4/importers"
+
+	pgquery "github.com/lfittl/pg_query_go"
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
 )
 
 func TestMain(m *testing.M) {
@@ -279,7 +289,7 @@ func TestUnknownIdentifiers(t *testing.T) {
 		t.Run("Normal", func(t *testing.T) {
 			t.Parallel()
 
-			call := testCall(`update users set name = $1`)
+			call := testCall(`update users set name = $1`, "string")
 			errs := checkCallWrapper(call)
 			checkErrs(t, errs,
 				IdentErr{Table: "users", Location: 7},
@@ -289,7 +299,7 @@ func TestUnknownIdentifiers(t *testing.T) {
 		t.Run("Quoted", func(t *testing.T) {
 			t.Parallel()
 
-			call := testCall(`update "users" set "name" = $1`)
+			call := testCall(`update "users" set "name" = $1`, "string")
 			errs := checkCallWrapper(call)
 			checkErrs(t, errs,
 				IdentErr{Table: "users", Location: 7},
@@ -309,6 +319,19 @@ func TestUnknownIdentifiers(t *testing.T) {
 			checkErrs(t, errs,
 				IdentErr{Table: "users", Location: 12},
 				IdentErr{Column: "id", Location: 19},
+				// Double quotes make this an identifier, not a string
+				IdentErr{Column: "ok", Location: 33},
+			)
+		})
+		t.Run("Returning", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`insert into users (id) values (1) returning nope as id`)
+			errs := checkCallWrapper(call)
+			checkErrs(t, errs,
+				IdentErr{Table: "users", Location: 12},
+				IdentErr{Column: "id", Location: 19},
+				IdentErr{Column: "nope", Location: 44},
 			)
 		})
 	})
@@ -403,170 +426,4467 @@ func TestTypeErrors(t *testing.T) {
 			TypeErr{Parameter: 1, Column: "id", CallType: "bool", DriverType: "int", DBType: "integer", Location: 31},
 		)
 	})
-}
+	t.Run("Reversed", func(t *testing.T) {
+		t.Parallel()
 
-func checkCallWithState(s *State, fns ...Call) []error {
-	return checkCalls(s, fns)
-}
+		call := testCall(`select * from users where $1 = id`, "bool")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "id", CallType: "bool", DriverType: "int", DBType: "integer", Location: 26},
+		)
+	})
+	t.Run("In", func(t *testing.T) {
+		t.Parallel()
 
-func checkCallWrapper(fns ...Call) []error {
-	return checkCalls(&State{DBInfo: &drivers.DBInfo{}}, fns)
-}
+		call := testCall(`select * from users where id in (1, $1, $2)`, "int", "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 2, Column: "id", CallType: "string", DriverType: "int", Location: 40},
+		)
+	})
+	t.Run("Any", func(t *testing.T) {
+		t.Parallel()
 
-func testCall(sql string, argTypes ...string) Call {
-	return Call{
-		SQL:      sql,
-		ArgTypes: argTypes,
-		Package:  "pkg",
-		Pos:      token.Position{Filename: "t.go"},
-	}
-}
+		call := testCall(`select * from users where id = any($1)`, "github.com/lib/pq.StringArray")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
 
-func checkIdentErr(t *testing.T, i IdentErr, err error) {
-	t.Helper()
+		call = testCall(`select * from users where id = any($1)`, "github.com/lib/pq.BoolArray")
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "id", CallType: "github.com/lib/pq.BoolArray", DriverType: "types.Int64Array", DBType: "ARRAYinteger"},
+		)
+	})
+	t.Run("Qualified", func(t *testing.T) {
+		t.Parallel()
 
-	e, ok := err.(IdentErr)
-	if !ok {
-		t.Errorf("err was not of type UnkIdentErr: %T", err)
-		return
-	}
+		call := testCall(`select * from comments where comment = $1 and id = $2`,
+			"github.com/volatiletech/null/v8.String", "*int32")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
 
-	outputErr := func(format string, args ...interface{}) {
-		t.Helper()
-		t.Errorf("(%s.%s.%s) "+format, append([]interface{}{i.Schema, i.Table, i.Column}, args...)...)
-	}
+		call = testCall(`select * from comments where comment = $1`, "github.com/volatiletech/null/v8.Time")
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "comment", CallType: "github.com/volatiletech/null/v8.Time", DriverType: "null.String"},
+		)
+	})
+	t.Run("Between", func(t *testing.T) {
+		t.Parallel()
 
-	if i.Kind != 0 && i.Kind != e.Kind {
-		outputErr("kind wrong, want: %d, got: %d", i.Kind, e.Kind)
-	}
-	if len(i.Schema) != 0 && i.Schema != e.Schema {
-		outputErr("schema wrong, want: %s, got: %s", i.Schema, e.Schema)
-	}
-	if len(i.Table) != 0 && i.Table != e.Table {
-		outputErr("table wrong, want: %s, got: %s", i.Table, e.Table)
-	}
-	if len(i.Column) != 0 && i.Column != e.Column {
-		outputErr("column wrong, want: %s, got: %s", i.Column, e.Column)
-	}
-	if i.Location != 0 && i.Location != e.Location {
-		outputErr("location wrong, want: %d, got: %d", i.Location, e.Location)
-	}
-}
+		call := testCall(`select * from users where id between $1 and $2`, "int", "bool")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 2, Column: "id", CallType: "bool", DriverType: "int"},
+		)
+	})
+	t.Run("Like", func(t *testing.T) {
+		t.Parallel()
 
-func checkTypeErr(t *testing.T, te TypeErr, err error) {
-	t.Helper()
+		call := testCall(`select * from users where name like $1`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "name", CallType: "int", DriverType: "string"},
+		)
+	})
+	t.Run("Coalesce", func(t *testing.T) {
+		t.Parallel()
 
-	e, ok := err.(TypeErr)
-	if !ok {
-		t.Errorf("err was not of type TypeErr: %T", err)
-		return
-	}
+		call := testCall(`select * from users where name = coalesce($1, 'none')`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "name", CallType: "int", DriverType: "string"},
+		)
+	})
+	t.Run("LimitOffset", func(t *testing.T) {
+		t.Parallel()
 
-	outputErr := func(format string, args ...interface{}) {
-		t.Helper()
-		t.Errorf("(%s.%s.%s) "+format, append([]interface{}{e.Schema, e.Table, e.Column}, args...)...)
-	}
+		call := testCall(`select * from users limit $1 offset $2`, "int64", "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 2, Column: "OFFSET", CallType: "string", DriverType: "int64", DBType: "bigint"},
+		)
+	})
+	t.Run("Cast", func(t *testing.T) {
+		t.Parallel()
 
-	if len(te.Schema) != 0 && te.Schema != e.Schema {
-		outputErr("schema wrong, want: %s, got: %s", te.Schema, e.Schema)
-	}
-	if len(te.Table) != 0 && te.Table != e.Table {
-		outputErr("table wrong, want: %s, got: %s", te.Table, e.Table)
-	}
-	if len(te.Column) != 0 && te.Column != e.Column {
-		outputErr("column wrong, want: %s, got: %s", te.Column, e.Column)
-	}
-	if len(te.CallType) != 0 && te.CallType != e.CallType {
-		outputErr("call type wrong, want: %s, got: %s", te.CallType, e.CallType)
-	}
-	if len(te.DriverType) != 0 && te.DriverType != e.DriverType {
-		outputErr("go type wrong, want: %s, got: %s", te.DriverType, e.DriverType)
-	}
-	if len(te.DBType) != 0 && te.DBType != e.DBType {
-		outputErr("dbtype wrong, want: %s, got: %s", te.DBType, e.DBType)
-	}
-	if te.Parameter != 0 && te.Parameter != e.Parameter {
-		outputErr("parameter wrong, want: %d, got: %d", te.Parameter, e.Parameter)
-	}
-	if te.Location != 0 && te.Location != e.Location {
-		outputErr("location wrong, want: %d, got: %d", te.Location, e.Location)
-	}
-}
+		call := testCall(`select * from users where name = $1::int4`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "::integer", CallType: "string", DriverType: "int", DBType: "integer"},
+		)
+	})
+	t.Run("Function", func(t *testing.T) {
+		t.Parallel()
 
-func checkErrs(t *testing.T, errs []error, expect ...error) {
-	t.Helper()
+		call := testCall(`select * from users where name = lower($1)`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "lower()", CallType: "int", DBType: "text"},
+		)
+	})
+	t.Run("InsertValues", func(t *testing.T) {
+		t.Parallel()
 
-	defer func() {
-		if !t.Failed() {
-			return
-		}
+		call := testCall(`insert into users (name, id) values ($1, $2)`, "string", "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 2, Column: "id", CallType: "string", DriverType: "int", Location: 41},
+		)
+	})
+	t.Run("InsertValuesNoCols", func(t *testing.T) {
+		t.Parallel()
 
-		for i, err := range errs {
-			t.Errorf("[%d] %s", i, err.Error())
-		}
-	}()
+		call := testCall(`insert into users values ($1, $2)`, "int", "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 2, Column: "name", CallType: "int", DriverType: "string"},
+		)
+	})
+	t.Run("UpdateSet", func(t *testing.T) {
+		t.Parallel()
 
-	if len(expect) != len(errs) {
-		t.Errorf("want: %d errors, got: %d", len(expect), len(errs))
-		return
-	}
+		call := testCall(`update users set name = $1 where id = $2`, "int", "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "name", CallType: "int", DriverType: "string"},
+		)
+	})
+	t.Run("SameTwice", func(t *testing.T) {
+		t.Parallel()
 
-	for i, exp := range expect {
-		switch expectErr := exp.(type) {
-		case IdentErr:
-			checkIdentErr(t, expectErr, errs[i])
-		case TypeErr:
-			checkTypeErr(t, expectErr, errs[i])
-		default:
-			t.Fatalf("unknown error type found: %T", expectErr)
-		}
-	}
+		call := testCall(`select * from users u join videos v on v.id = $1 where u.id = $1`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Table: "v", Column: "id", CallType: "string"},
+		)
+	})
+	t.Run("Conflict", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users where id = $1 or name = $1`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			ParamErr{Kind: ParamConflict, Parameter: 1, Location: 31, DBTypes: []string{"integer", "text"}},
+		)
+	})
 }
 
-func TestBugNestSelect(t *testing.T) {
+func TestParamArity(t *testing.T) {
 	t.Parallel()
 
-	state := &State{
-		DBInfo: &drivers.DBInfo{
-			Tables: []drivers.Table{
-				{
-					Name: "tag_videos",
-					Columns: []drivers.Column{
-						{
-							Name:       "tag_id",
-							Type:       "int",
-							DBType:     "integer",
-							Unique:     true,
-							UDTName:    "int4",
-							FullDBType: "int4",
-						},
-						{
-							Name:       "video_id",
-							Type:       "int",
-							DBType:     "integer",
-							Unique:     true,
-							UDTName:    "int4",
-							FullDBType: "int4",
-						},
-					},
-				},
-			},
-		},
-	}
+	t.Run("TooFew", func(t *testing.T) {
+		t.Parallel()
 
-	const query = `
-		select "tv"."tag_id", (
-			select count("tag_videos"."video_id")
-			from "tag_videos"
-			where "tag_videos"."tag_id" = "tv"."tag_id"
+		call := testCall(`select $1, $2, $3`, "int")
+		errs := checkCallWrapper(call)
+		checkErrs(t, errs,
+			ParamErr{Kind: ParamTooFew, Parameter: 3, Location: 15},
 		)
-		from "tag_videos" "tv"
-		where "tv"."video_id" = $1`
+	})
+	t.Run("TooMany", func(t *testing.T) {
+		t.Parallel()
 
-	call := testCall(query, "int")
-	errs := checkCallWithState(state, call)
+		call := testCall(`select $1`, "int", "string")
+		errs := checkCallWrapper(call)
+		checkErrs(t, errs,
+			ParamErr{Kind: ParamTooMany, Parameter: 1},
+		)
 
-	if len(errs) != 0 {
-		t.Error(errs)
-	}
+		call = testCall(`select 1`, "int")
+		errs = checkCallWrapper(call)
+		checkErrs(t, errs,
+			ParamErr{Kind: ParamTooMany},
+		)
+	})
+	t.Run("Gap", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select $1, $4`, "int", "int", "int", "int")
+		errs := checkCallWrapper(call)
+		checkErrs(t, errs,
+			ParamErr{Kind: ParamGap, Parameter: 2},
+			ParamErr{Kind: ParamGap, Parameter: 3},
+		)
+	})
+	t.Run("Reused", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select $1, $2, $1`, "int", "int")
+		errs := checkCallWrapper(call)
+		checkErrs(t, errs)
+	})
+	t.Run("Question", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 'what?', ?, ?`, "int", "int")
+		errs := checkCallWrapper(call)
+		checkErrs(t, errs,
+			ParamErr{Kind: ParamQuestion, Location: 16},
+			ParamErr{Kind: ParamQuestion, Location: 19},
+		)
+
+		// This is the jsonb key exists operator
+		call = testCall(`select '{}'::jsonb ? 'key'`)
+		errs = checkCallWrapper(call)
+		checkErrs(t, errs)
+	})
+	t.Run("Message", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select $1, $2`, "int")
+		errs := checkCallWrapper(call)
+		if len(errs) != 1 {
+			t.Fatal("want one error, got:", errs)
+		}
+		if msg := errs[0].Error(); !strings.Contains(msg, "(args: int)") {
+			t.Error("message should contain the argument types:", msg)
+		}
+
+		call = testCall(`select 1`, "int")
+		errs = checkCallWrapper(call)
+		if len(errs) != 1 {
+			t.Fatal("want one error, got:", errs)
+		}
+		if msg := errs[0].Error(); !strings.Contains(msg, "has no parameters") {
+			t.Error("message should say there are no parameters:", msg)
+		}
+	})
+	t.Run("Clauses", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{
+			DBInfo: &drivers.DBInfo{
+				Tables: []drivers.Table{
+					{Name: "users", Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "string", DBType: "text"},
+					}},
+					{Name: "videos", Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "user_id", Type: "int", DBType: "integer"},
+						{Name: "title", Type: "string", DBType: "text"},
+					}},
+				},
+			},
+			// The inserts leave columns out on purpose
+			Nullability: nullabilityOff,
+		}
+
+		t.Run("OnConflict", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`insert into users (id, name) values ($1, $2) on conflict (id) do update set name = $3 where users.id > $4`,
+				"int", "string", "string", "int")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs)
+		})
+		t.Run("OnConflictExcluded", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`insert into users as u (id, name) values ($1, $2) on conflict (id) do update set name = excluded.name || u.name`,
+				"int", "string")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs)
+		})
+		t.Run("OnConflictUnknown", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`insert into users (id) values ($1) on conflict (id) do update set nope = excluded.nope`, "int")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs,
+				IdentErr{Column: "nope", Location: 66},
+				IdentErr{Table: "excluded", Column: "nope", Location: 73},
+			)
+		})
+		t.Run("OnConflictType", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`insert into users (id) values ($1) on conflict (id) do update set name = $2`, "int", "int")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs,
+				TypeErr{Parameter: 2, Column: "name", DBType: "text"},
+			)
+		})
+		t.Run("UpdateFrom", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`update users set name = v.title from videos v where v.user_id = users.id and v.id = $1`, "int")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs)
+		})
+		t.Run("UpdateFromUnknown", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`update users set title = $1 from videos v where v.nope = users.id`, "string")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs,
+				IdentErr{Column: "title", Location: 17},
+				IdentErr{Table: "v", Column: "nope", Location: 48},
+			)
+		})
+		t.Run("DeleteUsing", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`delete from videos using users u where u.id = videos.user_id and u.name = $1`, "string")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs)
+		})
+		t.Run("With", func(t *testing.T) {
+			t.Parallel()
+
+			call := testCall(`with x as (select id from users where name = $1) delete from videos where user_id in (select id from x)`, "string")
+			errs := checkCallWithState(state, call)
+			checkErrs(t, errs)
+
+			call = testCall(`with x as (select id from users where name = $1) update videos set title = $2 where user_id in (select id from x)`,
+				"string", "string")
+			errs = checkCallWithState(state, call)
+			checkErrs(t, errs)
+
+			call = testCall(`with x as (select $1::int as id) insert into videos (id, user_id) select id, $2 from x`, "int", "int")
+			errs = checkCallWithState(state, call)
+			checkErrs(t, errs)
+		})
+	})
+}
+
+func TestTypeAliases(t *testing.T) {
+	t.Parallel()
+
+	cfgFile := filepath.Join(t.TempDir(), "sqlboiler.toml")
+	err := ioutil.WriteFile(cfgFile, []byte(`
+[psql]
+dbname = "test"
+
+[boilcheck.types]
+"users.name" = ["example.com/app/names.Name"]
+"boolean" = ["example.com/app/flags.Flag"]
+
+[[types]]
+  [types.match]
+    name = "id"
+    db_type = "integer"
+  [types.replace]
+    type = "ids.ID"
+  [types.imports]
+    third_party = ['"example.com/app/ids/v2"']
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadBoilcheckConfig(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{
+				Name: "users",
+				Columns: []drivers.Column{
+					{Name: "id", Type: "int", DBType: "integer"},
+					{Name: "name", Type: "string", DBType: "text"},
+					{Name: "admin", Type: "bool", DBType: "boolean"},
+				},
+			},
+			{
+				Name:       "users",
+				SchemaName: "tenant",
+				Columns: []drivers.Column{
+					{Name: "id", Type: "int64", DBType: "bigint"},
+					{Name: "name", Type: "string", DBType: "text"},
+				},
+			},
+		},
+	}
+
+	replaced := replaceTypes(cfg, info)
+	if got := info.Tables[0].Columns[0].Type; got != "ids.ID" {
+		t.Error("replaced column type wrong:", got)
+	}
+	aliases := typeAliases(cfg, replaced)
+	if got := aliases["public.users.id"]; !reflect.DeepEqual(got, []string{"example.com/app/ids/v2.ID"}) {
+		t.Error("replaced column alias wrong:", got)
+	}
+
+	// All of these have underlying types that don't suit their columns
+	named := func(pkgPath, name string, underlying types.Type) types.Type {
+		pkg := types.NewPackage(pkgPath, path.Base(pkgPath))
+		return types.NewNamed(types.NewTypeName(0, pkg, name, nil), underlying, nil)
+	}
+	nameType := named("example.com/app/names", "Name", types.Typ[types.Int])
+	flagType := named("example.com/app/flags", "Flag", types.Typ[types.String])
+	idType := named("example.com/app/ids/v2", "ID", types.Typ[types.String])
+
+	call := testCall(`update users set name = $1, admin = $2 where id = $3`)
+	call.Args = []types.Type{types.NewPointer(nameType), flagType, idType}
+	for _, a := range call.Args {
+		call.ArgTypes = append(call.ArgTypes, a.String())
+	}
+
+	errs := checkCallWithState(&State{DBInfo: info, TypeAliases: aliases}, call)
+	checkErrs(t, errs)
+
+	errs = checkCallWithState(&State{DBInfo: info}, call)
+	checkErrs(t, errs,
+		TypeErr{Parameter: 1, Column: "name"},
+		TypeErr{Parameter: 2, Column: "admin"},
+		TypeErr{Parameter: 3, Column: "id", DriverType: "ids.ID"},
+	)
+
+	// Aliases for one column don't spill over to others
+	call = testCall(`select * from users where admin = $1`)
+	call.Args = []types.Type{nameType}
+	call.ArgTypes = []string{nameType.String()}
+	errs = checkCallWithState(&State{DBInfo: info, TypeAliases: aliases}, call)
+	checkErrs(t, errs,
+		TypeErr{Parameter: 1, Column: "admin", CallType: "example.com/app/names.Name"},
+	)
+
+	// Replacements stay with the schema of the table they matched, config
+	// keys without a schema apply to the table in every schema
+	call = testCall(`update tenant.users set name = $1 where id = $2`)
+	call.Args = []types.Type{nameType, idType}
+	call.ArgTypes = []string{nameType.String(), idType.String()}
+	errs = checkCallWithState(&State{DBInfo: info, TypeAliases: aliases}, call)
+	checkErrs(t, errs,
+		TypeErr{Parameter: 2, Column: "id", CallType: "example.com/app/ids/v2.ID"},
+	)
+}
+
+func TestGoTypeCompatible(t *testing.T) {
+	t.Parallel()
+
+	pkg := types.NewPackage("example.com/app", "app")
+	userID := types.NewNamed(types.NewTypeName(0, pkg, "UserID", nil), types.Typ[types.Int64], nil)
+
+	// A struct with a Value() (driver.Value, error) method on its pointer
+	valuer := types.NewNamed(types.NewTypeName(0, pkg, "Point", nil), types.NewStruct(nil, nil), nil)
+	results := types.NewTuple(
+		types.NewVar(0, pkg, "", types.NewInterfaceType(nil, nil).Complete()),
+		types.NewVar(0, pkg, "", types.Universe.Lookup("error").Type()),
+	)
+	recv := types.NewVar(0, pkg, "p", types.NewPointer(valuer))
+	valuer.AddMethod(types.NewFunc(0, pkg, "Value", types.NewSignature(recv, nil, results, false)))
+
+	compatible := func(t *testing.T, typ types.Type, dbType string, want bool) {
+		t.Helper()
+
+		if got := goValueCompatible(classifyGoType(typ), dbType); got != want {
+			t.Errorf("%s as %s, want: %t, got: %t", typ, dbType, want, got)
+		}
+	}
+
+	t.Run("Numbers", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("int32"), "integer", true)
+		compatible(t, parseGoType("int"), "bigint", true)
+		compatible(t, parseGoType("float64"), "integer", false)
+		compatible(t, parseGoType("float64"), "numeric", true)
+	})
+	t.Run("Strings", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("string"), "integer", false)
+		compatible(t, parseGoType("string"), "uuid", true)
+		compatible(t, parseGoType("string"), "timestamp with time zone", true)
+		compatible(t, parseGoType("string"), "boolean", false)
+		compatible(t, parseGoType("string"), "enum.mood('happy','sad')", true)
+		compatible(t, parseGoType("*string"), "text", true)
+	})
+	t.Run("Bool", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("bool"), "boolean", true)
+		compatible(t, parseGoType("bool"), "text", false)
+	})
+	t.Run("Bytes", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("[]byte"), "bytea", true)
+		compatible(t, parseGoType("[]byte"), "jsonb", true)
+		compatible(t, parseGoType("[]byte"), "text", false)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("[]int64"), "ARRAYbigint", false)
+		compatible(t, parseGoType("map[string]string"), "jsonb", false)
+		compatible(t, parseGoType("interface{}"), "boolean", true)
+	})
+	t.Run("Time", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("time.Time"), "timestamp with time zone", true)
+		compatible(t, parseGoType("*time.Time"), "date", true)
+		compatible(t, parseGoType("time.Time"), "text", false)
+	})
+	t.Run("SQLNull", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("database/sql.NullString"), "text", true)
+		compatible(t, parseGoType("database/sql.NullInt64"), "text", false)
+	})
+	t.Run("NullPackage", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("github.com/volatiletech/null/v8.Int64"), "bigint", true)
+		compatible(t, parseGoType("github.com/volatiletech/null/v8.JSON"), "jsonb", true)
+		compatible(t, parseGoType("github.com/volatiletech/null/v8.JSON"), "text", false)
+	})
+	t.Run("Types", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("github.com/volatiletech/sqlboiler/v4/types.Decimal"), "numeric", true)
+		compatible(t, parseGoType("github.com/volatiletech/sqlboiler/v4/types.Int64Array"), "ARRAYinteger", true)
+		compatible(t, parseGoType("github.com/volatiletech/sqlboiler/v4/types.Int64Array"), "ARRAYtext", false)
+		compatible(t, parseGoType("github.com/volatiletech/sqlboiler/v4/types.StringArray"), "ARRAYuuid", true)
+	})
+	t.Run("Named", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, parseGoType("example.com/app.Unknown"), "integer", true)
+		compatible(t, userID, "bigint", true)
+		compatible(t, userID, "text", false)
+		compatible(t, types.NewPointer(userID), "integer", true)
+	})
+	t.Run("Valuer", func(t *testing.T) {
+		t.Parallel()
+
+		compatible(t, valuer, "point", true)
+		compatible(t, valuer, "integer", true)
+	})
+}
+
+func checkCallWithState(s *State, fns ...Call) []error {
+	return checkCalls(s, fns)
+}
+
+func checkCallWrapper(fns ...Call) []error {
+	return checkCalls(&State{DBInfo: &drivers.DBInfo{}}, fns)
+}
+
+func testCall(sql string, argTypes ...string) Call {
+	return Call{
+		SQL:      sql,
+		ArgTypes: argTypes,
+		Package:  "pkg",
+		Pos:      token.Position{Filename: "t.go"},
+	}
+}
+
+// selectOutputCols checks a select statement and returns its output columns
+func selectOutputCols(t *testing.T, s *State, sql string) []outputColRef {
+	t.Helper()
+
+	tree, err := pgquery.Parse(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sel := tree.Statements[0].(pgnodes.RawStmt).Stmt.(pgnodes.SelectStmt)
+	refs, errs := checkSelect(s, testCall(sql), NewScope(s.DBInfo), sel)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	return refs
+}
+
+// outputCol is what checkOutputCols compares a select's output columns
+// against, an empty DBType means the column had no type information.
+type outputCol struct {
+	Name     string
+	DBType   string
+	Nullable bool
+}
+
+func checkOutputCols(t *testing.T, s *State, sql string, want ...outputCol) {
+	t.Helper()
+
+	var got []outputCol
+	for _, r := range selectOutputCols(t, s, sql) {
+		c := outputCol{Name: r.name}
+		if r.col != nil {
+			c.DBType = r.col.DBType
+			c.Nullable = r.col.Nullable
+		}
+		got = append(got, c)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func checkOutputNames(t *testing.T, s *State, sql string, want ...string) {
+	t.Helper()
+
+	var got []string
+	for _, r := range selectOutputCols(t, s, sql) {
+		if r.col == nil {
+			t.Errorf("column %s had no type information", r.name)
+		}
+		got = append(got, r.name)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func checkIdentErr(t *testing.T, i IdentErr, err error) {
+	t.Helper()
+
+	e, ok := err.(IdentErr)
+	if !ok {
+		t.Errorf("err was not of type UnkIdentErr: %T", err)
+		return
+	}
+
+	outputErr := func(format string, args ...interface{}) {
+		t.Helper()
+		t.Errorf("(%s.%s.%s) "+format, append([]interface{}{i.Schema, i.Table, i.Column}, args...)...)
+	}
+
+	if i.Kind != 0 && i.Kind != e.Kind {
+		outputErr("kind wrong, want: %d, got: %d", i.Kind, e.Kind)
+	}
+	if len(i.Schema) != 0 && i.Schema != e.Schema {
+		outputErr("schema wrong, want: %s, got: %s", i.Schema, e.Schema)
+	}
+	if len(i.Table) != 0 && i.Table != e.Table {
+		outputErr("table wrong, want: %s, got: %s", i.Table, e.Table)
+	}
+	if len(i.Column) != 0 && i.Column != e.Column {
+		outputErr("column wrong, want: %s, got: %s", i.Column, e.Column)
+	}
+	if i.Location != 0 && i.Location != e.Location {
+		outputErr("location wrong, want: %d, got: %d", i.Location, e.Location)
+	}
+}
+
+func checkTypeErr(t *testing.T, te TypeErr, err error) {
+	t.Helper()
+
+	e, ok := err.(TypeErr)
+	if !ok {
+		t.Errorf("err was not of type TypeErr: %T", err)
+		return
+	}
+
+	outputErr := func(format string, args ...interface{}) {
+		t.Helper()
+		t.Errorf("(%s.%s.%s) "+format, append([]interface{}{e.Schema, e.Table, e.Column}, args...)...)
+	}
+
+	if len(te.Schema) != 0 && te.Schema != e.Schema {
+		outputErr("schema wrong, want: %s, got: %s", te.Schema, e.Schema)
+	}
+	if len(te.Table) != 0 && te.Table != e.Table {
+		outputErr("table wrong, want: %s, got: %s", te.Table, e.Table)
+	}
+	if len(te.Column) != 0 && te.Column != e.Column {
+		outputErr("column wrong, want: %s, got: %s", te.Column, e.Column)
+	}
+	if len(te.CallType) != 0 && te.CallType != e.CallType {
+		outputErr("call type wrong, want: %s, got: %s", te.CallType, e.CallType)
+	}
+	if len(te.DriverType) != 0 && te.DriverType != e.DriverType {
+		outputErr("go type wrong, want: %s, got: %s", te.DriverType, e.DriverType)
+	}
+	if len(te.DBType) != 0 && te.DBType != e.DBType {
+		outputErr("dbtype wrong, want: %s, got: %s", te.DBType, e.DBType)
+	}
+	if te.Parameter != 0 && te.Parameter != e.Parameter {
+		outputErr("parameter wrong, want: %d, got: %d", te.Parameter, e.Parameter)
+	}
+	if te.Location != 0 && te.Location != e.Location {
+		outputErr("location wrong, want: %d, got: %d", te.Location, e.Location)
+	}
+}
+
+func checkParamErr(t *testing.T, pe ParamErr, err error) {
+	t.Helper()
+
+	e, ok := err.(ParamErr)
+	if !ok {
+		t.Errorf("err was not of type ParamErr: %T", err)
+		return
+	}
+
+	if pe.Kind != e.Kind {
+		t.Errorf("($%d) kind wrong, want: %d, got: %d", e.Parameter, pe.Kind, e.Kind)
+	}
+	if pe.Parameter != 0 && pe.Parameter != e.Parameter {
+		t.Errorf("($%d) parameter wrong, want: %d, got: %d", e.Parameter, pe.Parameter, e.Parameter)
+	}
+	if pe.Location != 0 && pe.Location != e.Location {
+		t.Errorf("($%d) location wrong, want: %d, got: %d", e.Parameter, pe.Location, e.Location)
+	}
+	if pe.DBTypes != nil && !reflect.DeepEqual(pe.DBTypes, e.DBTypes) {
+		t.Errorf("($%d) db types wrong, want: %v, got: %v", e.Parameter, pe.DBTypes, e.DBTypes)
+	}
+}
+
+func checkFuncErr(t *testing.T, fe FuncErr, err error) {
+	t.Helper()
+
+	e, ok := err.(FuncErr)
+	if !ok {
+		t.Errorf("err was not of type FuncErr: %T", err)
+		return
+	}
+
+	if fe.Kind != e.Kind {
+		t.Errorf("(%s) kind wrong, want: %d, got: %d", e.Name, fe.Kind, e.Kind)
+	}
+	if len(fe.Name) != 0 && fe.Name != e.Name {
+		t.Errorf("(%s) name wrong, want: %s, got: %s", e.Name, fe.Name, e.Name)
+	}
+	if fe.Args != 0 && fe.Args != e.Args {
+		t.Errorf("(%s) args wrong, want: %d, got: %d", e.Name, fe.Args, e.Args)
+	}
+	if len(fe.Clause) != 0 && fe.Clause != e.Clause {
+		t.Errorf("(%s) clause wrong, want: %s, got: %s", e.Name, fe.Clause, e.Clause)
+	}
+	if fe.Location != 0 && fe.Location != e.Location {
+		t.Errorf("(%s) location wrong, want: %d, got: %d", e.Name, fe.Location, e.Location)
+	}
+}
+
+func checkEnumErr(t *testing.T, ee EnumErr, err error) {
+	t.Helper()
+
+	e, ok := err.(EnumErr)
+	if !ok {
+		t.Errorf("err was not of type EnumErr: %T", err)
+		return
+	}
+
+	if ee.Column != e.Column {
+		t.Errorf("column wrong, want: %s, got: %s", ee.Column, e.Column)
+	}
+	if ee.Enum != e.Enum {
+		t.Errorf("(%s) enum wrong, want: %s, got: %s", e.Column, ee.Enum, e.Enum)
+	}
+	if ee.Value != e.Value {
+		t.Errorf("(%s) value wrong, want: %s, got: %s", e.Column, ee.Value, e.Value)
+	}
+	if ee.Parameter != e.Parameter {
+		t.Errorf("(%s) parameter wrong, want: %d, got: %d", e.Column, ee.Parameter, e.Parameter)
+	}
+	if ee.Location != e.Location {
+		t.Errorf("(%s) location wrong, want: %d, got: %d", e.Column, ee.Location, e.Location)
+	}
+}
+
+func checkLiteralErr(t *testing.T, le LiteralErr, err error) {
+	t.Helper()
+
+	e, ok := err.(LiteralErr)
+	if !ok {
+		t.Errorf("err was not of type LiteralErr: %T", err)
+		return
+	}
+
+	if le.Column != e.Column {
+		t.Errorf("column wrong, want: %s, got: %s", le.Column, e.Column)
+	}
+	if le.Literal != e.Literal {
+		t.Errorf("(%s) literal wrong, want: %s, got: %s", e.Column, le.Literal, e.Literal)
+	}
+	if le.LiteralType != e.LiteralType {
+		t.Errorf("(%s) literal type wrong, want: %s, got: %s", e.Column, le.LiteralType, e.LiteralType)
+	}
+	if le.Location != e.Location {
+		t.Errorf("(%s) location wrong, want: %d, got: %d", e.Column, le.Location, e.Location)
+	}
+}
+
+func checkSetOpErr(t *testing.T, se SetOpErr, err error) {
+	t.Helper()
+
+	e, ok := err.(SetOpErr)
+	if !ok {
+		t.Errorf("err was not of type SetOpErr: %T", err)
+		return
+	}
+
+	if se.Op != e.Op {
+		t.Errorf("operation wrong, want: %s, got: %s", se.Op, e.Op)
+	}
+	if se.LeftCount != e.LeftCount || se.RightCount != e.RightCount {
+		t.Errorf("counts wrong, want: %d %d, got: %d %d", se.LeftCount, se.RightCount, e.LeftCount, e.RightCount)
+	}
+	if se.Column != e.Column || se.LeftType != e.LeftType || se.RightType != e.RightType {
+		t.Errorf("column wrong, want: %d %s %s, got: %d %s %s", se.Column, se.LeftType, se.RightType, e.Column, e.LeftType, e.RightType)
+	}
+	if se.Location != e.Location {
+		t.Errorf("location wrong, want: %d, got: %d", se.Location, e.Location)
+	}
+}
+
+func checkCompareErr(t *testing.T, ce CompareErr, err error) {
+	t.Helper()
+
+	e, ok := err.(CompareErr)
+	if !ok {
+		t.Errorf("err was not of type CompareErr: %T", err)
+		return
+	}
+
+	if ce.Left != e.Left || ce.Right != e.Right {
+		t.Errorf("columns wrong, want: %s %s, got: %s %s", ce.Left, ce.Right, e.Left, e.Right)
+	}
+	if ce.LeftType != e.LeftType || ce.RightType != e.RightType {
+		t.Errorf("types wrong, want: %s %s, got: %s %s", ce.LeftType, ce.RightType, e.LeftType, e.RightType)
+	}
+	if ce.Operator != e.Operator {
+		t.Errorf("operator wrong, want: %s, got: %s", ce.Operator, e.Operator)
+	}
+	if ce.Location != e.Location {
+		t.Errorf("location wrong, want: %d, got: %d", ce.Location, e.Location)
+	}
+}
+
+func checkErrs(t *testing.T, errs []error, expect ...error) {
+	t.Helper()
+
+	defer func() {
+		if !t.Failed() {
+			return
+		}
+
+		for i, err := range errs {
+			t.Errorf("[%d] %s", i, err.Error())
+		}
+	}()
+
+	if len(expect) != len(errs) {
+		t.Errorf("want: %d errors, got: %d", len(expect), len(errs))
+		return
+	}
+
+	for i, exp := range expect {
+		switch expectErr := exp.(type) {
+		case IdentErr:
+			checkIdentErr(t, expectErr, errs[i])
+		case TypeErr:
+			checkTypeErr(t, expectErr, errs[i])
+		case ParamErr:
+			checkParamErr(t, expectErr, errs[i])
+		case FuncErr:
+			checkFuncErr(t, expectErr, errs[i])
+		case EnumErr:
+			checkEnumErr(t, expectErr, errs[i])
+		case LiteralErr:
+			checkLiteralErr(t, expectErr, errs[i])
+		case CompareErr:
+			checkCompareErr(t, expectErr, errs[i])
+		case OperatorErr:
+			got, _ := errs[i].(OperatorErr)
+			got.Fn = Call{}
+			if !reflect.DeepEqual(expectErr, got) {
+				t.Errorf("operator error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case SetOpErr:
+			checkSetOpErr(t, expectErr, errs[i])
+		case OrdinalErr:
+			got, _ := errs[i].(OrdinalErr)
+			got.Fn = Call{}
+			if !reflect.DeepEqual(expectErr, got) {
+				t.Errorf("ordinal error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case GroupingErr:
+			got, _ := errs[i].(GroupingErr)
+			got.Fn = Call{}
+			if !reflect.DeepEqual(expectErr, got) {
+				t.Errorf("grouping error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case DistinctOnErr:
+			got, _ := errs[i].(DistinctOnErr)
+			got.Fn = Call{}
+			if !reflect.DeepEqual(expectErr, got) {
+				t.Errorf("distinct on error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case NullErr:
+			got, _ := errs[i].(NullErr)
+			got.Fn = Call{}
+			if !reflect.DeepEqual(expectErr, got) {
+				t.Errorf("null error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case ColumnWriteErr:
+			got, _ := errs[i].(ColumnWriteErr)
+			got.Fn = Call{}
+			if !reflect.DeepEqual(expectErr, got) {
+				t.Errorf("column write error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case WriteErr:
+			if !reflect.DeepEqual(expectErr, errs[i]) {
+				t.Errorf("write error wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		case SchemaWarn:
+			if !reflect.DeepEqual(expectErr, errs[i]) {
+				t.Errorf("warning wrong, want: %v, got: %v", expectErr, errs[i])
+			}
+		default:
+			t.Fatalf("unknown error type found: %T", expectErr)
+		}
+	}
+}
+
+func TestBugNestSelect(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "tag_videos",
+					Columns: []drivers.Column{
+						{
+							Name:       "tag_id",
+							Type:       "int",
+							DBType:     "integer",
+							Unique:     true,
+							UDTName:    "int4",
+							FullDBType: "int4",
+						},
+						{
+							Name:       "video_id",
+							Type:       "int",
+							DBType:     "integer",
+							Unique:     true,
+							UDTName:    "int4",
+							FullDBType: "int4",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	const query = `
+		select "tv"."tag_id", (
+			select count("tag_videos"."video_id")
+			from "tag_videos"
+			where "tag_videos"."tag_id" = "tv"."tag_id"
+		)
+		from "tag_videos" "tv"
+		where "tv"."video_id" = $1`
+
+	call := testCall(query, "int")
+	errs := checkCallWithState(state, call)
+
+	if len(errs) != 0 {
+		t.Error(errs)
+	}
+}
+
+func TestStarExpansion(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "string", DBType: "text"},
+					},
+				},
+				{
+					Name: "videos",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "user_id", Type: "int", DBType: "integer"},
+						{Name: "title", Type: "string", DBType: "text"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Star", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select * from users`, "id", "name")
+	})
+	t.Run("StarMultiple", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select * from users, videos`, "id", "name", "id", "user_id", "title")
+	})
+	t.Run("Qualified", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select v.*, u.name from users u, videos v`, "id", "user_id", "title", "name")
+	})
+	t.Run("Join", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select * from users u inner join videos v on v.user_id = u.id`, "id", "name", "id", "user_id", "title")
+	})
+	t.Run("Using", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select * from videos inner join users using (id)`, "id", "user_id", "title", "name")
+	})
+	t.Run("UsingQualified", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select users.* from videos inner join users using (id)`, "id", "name")
+	})
+	t.Run("Subquery", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select * from (select * from users) as u`, "id", "name")
+	})
+	t.Run("SubqueryAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `select * from (select * from users) as u(a)`, "a", "name")
+	})
+	t.Run("CTE", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `with u as (select * from users) select u.* from u`, "id", "name")
+	})
+	t.Run("CTEAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputNames(t, state, `with u(a, b) as (select * from users) select * from u`, "a", "b")
+	})
+	t.Run("SubqueryUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select s.title from (select * from users) as s`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "s", Column: "title", Location: 7},
+		)
+	})
+	t.Run("SubqueryAmbiguous", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select s.id from (select * from users, videos) as s`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Kind: Ambiguous, Table: "s", Column: "id", Location: 7},
+		)
+	})
+	t.Run("QualifiedUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select v.* from users u`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "v", Location: 7},
+		)
+	})
+	t.Run("RecursiveCTE", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`
+			with recursive tree as (
+				select users.id from users
+				union all
+				select tree.id from tree where tree.nope = 5
+			)
+			select tree.id from tree`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "tree", Column: "nope", Location: 109},
+		)
+	})
+}
+
+func TestFromFunctions(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "tags", Type: "types.StringArray", DBType: "ARRAYtext"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Series", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from generate_series(1, $1) as g(n)`,
+			outputCol{Name: "n", DBType: "integer"},
+		)
+	})
+	t.Run("SeriesAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select g from generate_series(1, 5) as g`,
+			outputCol{Name: "g", DBType: "integer"},
+		)
+	})
+	t.Run("SeriesNoAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from generate_series(1, 5)`,
+			outputCol{Name: "generate_series", DBType: "integer"},
+		)
+	})
+	t.Run("Ordinality", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from generate_series(1, 5) with ordinality as g(n, i)`,
+			outputCol{Name: "n", DBType: "integer"},
+			outputCol{Name: "i", DBType: "bigint"},
+		)
+	})
+	t.Run("Unnest", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from unnest($1::int[]) as ids(id)`,
+			outputCol{Name: "id", DBType: "integer", Nullable: true},
+		)
+	})
+	t.Run("UnnestLateral", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select t.tag from users, unnest(users.tags) as t(tag)`,
+			outputCol{Name: "tag", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("ColumnDefs", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from json_to_recordset($1) as x(a int, b text[])`,
+			outputCol{Name: "a", DBType: "integer", Nullable: true},
+			outputCol{Name: "b", DBType: "ARRAYtext", Nullable: true},
+		)
+	})
+	t.Run("RowsFrom", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from rows from (unnest($1::int8[]), generate_series(1, 2)) as x(a, b)`,
+			outputCol{Name: "a", DBType: "bigint", Nullable: true},
+			outputCol{Name: "b", DBType: "integer"},
+		)
+	})
+	t.Run("Each", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from jsonb_each($1)`,
+			outputCol{Name: "key", DBType: "text", Nullable: true},
+			outputCol{Name: "value", DBType: "jsonb", Nullable: true},
+		)
+	})
+	t.Run("Values", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from (values (1, 'a'), (2, 'b')) as v(id, name)`,
+			outputCol{Name: "id", DBType: "integer"},
+			outputCol{Name: "name", DBType: "text"},
+		)
+	})
+	t.Run("ValuesNoAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from (values (null, 1.5::float8)) as v`,
+			outputCol{Name: "column1"},
+			outputCol{Name: "column2", DBType: "double precision"},
+		)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select g.x from generate_series(1, 5) as g(n) where n > 2`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "g", Column: "x", Location: 7},
+		)
+	})
+	t.Run("UnknownArg", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from unnest(users.nope) as t`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "users", Column: "nope", Location: 21},
+		)
+	})
+}
+
+func TestJoins(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "string", DBType: "text"},
+					},
+				},
+				{
+					Name: "videos",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "user_id", Type: "int", DBType: "integer"},
+						{Name: "title", Type: "string", DBType: "text"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("UsingUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users inner join videos using (user_id)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "users", Column: "user_id", Location: 14},
+		)
+	})
+	t.Run("UsingMerged", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users inner join videos using (id) order by id`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("UsingStillAmbiguous", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users u inner join videos using (id), videos v2`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Kind: Ambiguous, Column: "id", Location: 7},
+		)
+	})
+	t.Run("Natural", func(t *testing.T) {
+		t.Parallel()
+
+		names := func(refs []outputColRef) (n []string) {
+			for _, r := range refs {
+				n = append(n, r.name)
+			}
+			return n
+		}
+
+		refs := selectOutputCols(t, state, `select * from videos natural join users`)
+		if got, want := names(refs), []string{"id", "user_id", "title", "name"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("want: %v, got: %v", want, got)
+		}
+
+		call := testCall(`select id from videos natural join users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("LeftNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select u.name, v.title from users u left join videos v on v.user_id = u.id`,
+			outputCol{Name: "name", DBType: "text"},
+			outputCol{Name: "title", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("RightNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select u.name, v.title from users u right join videos v on v.user_id = u.id`,
+			outputCol{Name: "name", DBType: "text", Nullable: true},
+			outputCol{Name: "title", DBType: "text"},
+		)
+	})
+	t.Run("FullNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select u.name, v.title from users u full join videos v on v.user_id = u.id`,
+			outputCol{Name: "name", DBType: "text", Nullable: true},
+			outputCol{Name: "title", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("LeftUsingNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select * from users left join videos using (id)`,
+			outputCol{Name: "id", DBType: "integer"},
+			outputCol{Name: "name", DBType: "text"},
+			outputCol{Name: "user_id", DBType: "integer", Nullable: true},
+			outputCol{Name: "title", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("LeftUsingQualifiedNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select videos.* from users left join videos using (id)`,
+			outputCol{Name: "id", DBType: "integer", Nullable: true},
+			outputCol{Name: "user_id", DBType: "integer", Nullable: true},
+			outputCol{Name: "title", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("SelfJoinNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select u1.id, u2.id, u2.* from users u1 left join users u2 on u2.id = u1.id`,
+			outputCol{Name: "id", DBType: "integer"},
+			outputCol{Name: "id", DBType: "integer", Nullable: true},
+			outputCol{Name: "id", DBType: "integer", Nullable: true},
+			outputCol{Name: "name", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("CTETwiceNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `with c as (select id from users) select a.*, b.id from c a right join c b on b.id = a.id`,
+			outputCol{Name: "id", DBType: "integer", Nullable: true},
+			outputCol{Name: "id", DBType: "integer"},
+		)
+	})
+	t.Run("FullUsingMerged", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select id from users full join videos using (id)`,
+			outputCol{Name: "id", DBType: "integer"},
+		)
+	})
+	t.Run("Alias", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select j.id, j.title, j.* from (users join videos using (id)) as j`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+
+		refs := selectOutputCols(t, state, `select j.* from (users join videos using (id)) as j`)
+		if len(refs) != 4 {
+			t.Errorf("want 4 columns, got: %d", len(refs))
+		}
+	})
+	t.Run("AliasColumns", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select j.a, j.title from (users join videos using (id)) as j (a, b)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AliasHidesTables", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select users.name, id from (users join videos using (id)) as j`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "users", Column: "name", Location: 7},
+		)
+	})
+}
+
+func TestExpressionWalking(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "string", DBType: "text"},
+						{Name: "tags", Type: "types.StringArray", DBType: "ARRAYtext"},
+					},
+				},
+			},
+		},
+		// The inserts leave columns out on purpose
+		Nullability: nullabilityOff,
+	}
+
+	// Each of these has exactly one misspelled column called nope
+	t.Run("SelectList", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select lower(nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Case", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select case when id > 1 then nope else name end from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("CaseArg", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select case nope when 1 then name end from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("CaseElse", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select case when id > 1 then name else nope end from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Coalesce", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select coalesce(name, nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("NullIf", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select nullif(name, nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Greatest", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select greatest(id, nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Least", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select least(id, nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("TypeCast", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select nope::text from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("NullTest", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users where nope is null`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("BooleanTest", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users where nope is true`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Indirection", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select tags[nope] from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("IndirectionSlice", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select tags[1:nope] from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("ArrayExpr", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select array[id, nope] from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("RowExpr", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users where (id, name) = (1, nope)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("In", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users where id in (1, nope)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("InSubquery", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users where nope in (select id from users)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Filter", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select count(*) filter (where nope > 1) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("AggOrder", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select string_agg(name, ',' order by nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("OverPartition", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select row_number() over (partition by nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("OverOrder", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select row_number() over (order by nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Window", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select row_number() over w from users window w as (order by nope)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("DistinctOn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (nope) id from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Collate", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users order by nope collate "C"`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("NamedArg", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select make_interval(days => nope) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("Limit", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users limit (select nope from users limit 1)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("UpdateReturning", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set name = 'a' returning nope`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("DeleteReturning", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`delete from users returning nope`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+	t.Run("InsertSelect", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (id) select nope from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs, IdentErr{Column: "nope"})
+	})
+}
+
+func TestExpressionTypes(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "null.String", DBType: "text", Nullable: true},
+						{Name: "score", Type: "float32", DBType: "real"},
+						{Name: "created_at", Type: "time.Time", DBType: "timestamp with time zone"},
+						{Name: "tags", Type: "types.StringArray", DBType: "ARRAYtext"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Cast", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select id::bigint from users`,
+			outputCol{Name: "id", DBType: "bigint"},
+		)
+	})
+	t.Run("CastConst", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select 1::int8`,
+			outputCol{Name: "int8", DBType: "bigint"},
+		)
+	})
+	t.Run("String", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select 'a'`,
+			outputCol{Name: "?column?", DBType: "text"},
+		)
+	})
+	t.Run("StringFromContext", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select coalesce('2020-01-01', created_at), created_at + '1 day', id + '1', case when id = 1 then 'a' else 'b' end, array['a'] from users`,
+			outputCol{Name: "coalesce", DBType: "timestamp with time zone"},
+			outputCol{Name: "?column?", DBType: "timestamp with time zone"},
+			outputCol{Name: "?column?", DBType: "integer"},
+			outputCol{Name: "case", DBType: "text"},
+			outputCol{Name: "array", DBType: "ARRAYtext"},
+		)
+	})
+	t.Run("StringValues", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `values ('a', 'b'), (1, 'c')`,
+			outputCol{Name: "column1", DBType: "integer"},
+			outputCol{Name: "column2", DBType: "text"},
+		)
+	})
+	t.Run("Numeric", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select 1.5`,
+			outputCol{Name: "?column?", DBType: "numeric"},
+		)
+	})
+	t.Run("Count", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select count(*) from users`,
+			outputCol{Name: "count", DBType: "bigint"},
+		)
+	})
+	t.Run("CountAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select count(*) as n from users`,
+			outputCol{Name: "n", DBType: "bigint"},
+		)
+	})
+	t.Run("Sum", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select sum(id) from users`,
+			outputCol{Name: "sum", DBType: "bigint", Nullable: true},
+		)
+	})
+	t.Run("Avg", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select avg(id) from users`,
+			outputCol{Name: "avg", DBType: "numeric", Nullable: true},
+		)
+	})
+	t.Run("Max", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select max(created_at) from users`,
+			outputCol{Name: "max", DBType: "timestamp with time zone", Nullable: true},
+		)
+	})
+	t.Run("ArrayAgg", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select array_agg(id) from users`,
+			outputCol{Name: "array_agg", DBType: "ARRAYinteger", Nullable: true},
+		)
+	})
+	t.Run("CoalesceDefault", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select coalesce(name, 'none') from users`,
+			outputCol{Name: "coalesce", DBType: "text"},
+		)
+	})
+	t.Run("CoalesceNullable", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select coalesce(name, name) from users`,
+			outputCol{Name: "coalesce", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("Greatest", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select greatest(id, 5) from users`,
+			outputCol{Name: "greatest", DBType: "integer"},
+		)
+	})
+	t.Run("ArithmeticPromote", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select id + score from users`,
+			outputCol{Name: "?column?", DBType: "real"},
+		)
+	})
+	t.Run("Division", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select id / 2 from users`,
+			outputCol{Name: "?column?", DBType: "integer"},
+		)
+	})
+	t.Run("Negate", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select -id from users`,
+			outputCol{Name: "?column?", DBType: "integer"},
+		)
+	})
+	t.Run("Concat", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select name || 'x' from users`,
+			outputCol{Name: "?column?", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("Equal", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select id = 1 from users`,
+			outputCol{Name: "?column?", DBType: "boolean"},
+		)
+	})
+	t.Run("IsNull", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select name is null from users`,
+			outputCol{Name: "?column?", DBType: "boolean"},
+		)
+	})
+	t.Run("BoolExpr", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select id in (1, 2) and name like 'a%' from users`,
+			outputCol{Name: "?column?", DBType: "boolean", Nullable: true},
+		)
+	})
+	t.Run("Exists", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select exists (select 1 from users)`,
+			outputCol{Name: "exists", DBType: "boolean"},
+		)
+	})
+	t.Run("TimeMinusTime", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select now() - created_at from users`,
+			outputCol{Name: "?column?", DBType: "interval"},
+		)
+	})
+	t.Run("TimePlusInterval", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select created_at + interval '1 day' from users`,
+			outputCol{Name: "?column?", DBType: "timestamp with time zone"},
+		)
+	})
+	t.Run("Now", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select now()`,
+			outputCol{Name: "now", DBType: "timestamp with time zone"},
+		)
+	})
+	t.Run("CurrentTimestamp", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select current_timestamp`,
+			outputCol{Name: "current_timestamp", DBType: "timestamp with time zone"},
+		)
+	})
+	t.Run("Lower", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select lower(name) from users`,
+			outputCol{Name: "lower", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("LengthAlias", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select length(name) as l from users`,
+			outputCol{Name: "l", DBType: "integer", Nullable: true},
+		)
+	})
+	t.Run("Case", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select case when id > 1 then 'a' else 'b' end from users`,
+			outputCol{Name: "case", DBType: "text"},
+		)
+	})
+	t.Run("CaseNoElse", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select case when id > 1 then 'a' end from users`,
+			outputCol{Name: "case", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("Nullif", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select nullif(id, 0) from users`,
+			outputCol{Name: "nullif", DBType: "integer", Nullable: true},
+		)
+	})
+	t.Run("Array", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select array[id, 2] from users`,
+			outputCol{Name: "array", DBType: "ARRAYinteger"},
+		)
+	})
+	t.Run("Subscript", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select tags[1] from users`,
+			outputCol{Name: "tags", DBType: "text", Nullable: true},
+		)
+	})
+	t.Run("Sublink", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select (select max(id) from users)`,
+			outputCol{Name: "max"},
+		)
+	})
+	t.Run("Subquery", func(t *testing.T) {
+		t.Parallel()
+
+		checkOutputCols(t, state, `select t.n from (select count(*) as n from users) t`,
+			outputCol{Name: "n", DBType: "bigint"},
+		)
+	})
+	t.Run("Params", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from (select count(*) as n, lower(name) as l from users group by name) t where n > $1 and l = $2`,
+			"string", "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, Column: "n", DBType: "bigint"},
+			TypeErr{Parameter: 2, Column: "l", DBType: "text"},
+		)
+	})
+}
+
+func TestFunctions(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "string", DBType: "text"},
+						{Name: "avatar", Type: "[]byte", DBType: "bytea"},
+						{Name: "timeout", Type: "string", DBType: "interval"},
+					},
+				},
+			},
+		},
+		Functions: map[string]pgFunction{
+			"slugify": {min: 1, max: 2},
+		},
+		ExtraFunctions: []string{"st_distance"},
+	}
+
+	t.Run("Known", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select lower(name), length(name), now(), substring(name from 1 for 2) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select lenght(name) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncUnknown, Name: "lenght", Location: 7},
+		)
+	})
+	t.Run("Arity", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select substring(), left(name) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncArity, Name: "substring", Args: 0, Location: 7},
+			FuncErr{Kind: FuncArity, Name: "left", Args: 1, Location: 20},
+		)
+	})
+	t.Run("Overloads", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select to_timestamp($1), to_timestamp($2, 'YYYY-MM-DD') from users`, "float64", "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+
+		call = testCall(`select to_timestamp($1, 'YYYY-MM-DD') from users`, "int")
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, DBType: "text"},
+		)
+
+		call = testCall(`select to_timestamp(1, 'a', 'b') from users`)
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncArity, Name: "to_timestamp", Args: 3, Location: 7},
+		)
+	})
+	t.Run("OverloadsByType", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select length(avatar), date_trunc('hour', timeout) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+
+		call = testCall(`select string_agg(avatar, $1) from users`, "[]byte")
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs)
+
+		call = testCall(`select length($1), date_trunc($2, timeout) from users`, "[]byte", "int")
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 2, DBType: "text"},
+		)
+
+		refs := selectOutputCols(t, state, `select date_trunc('hour', timeout) from users`)
+		if len(refs) != 1 || refs[0].col == nil || refs[0].col.DBType != "interval" {
+			t.Errorf("want an interval, got: %v", refs)
+		}
+	})
+	t.Run("Catalog", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select chr($1), sha256($2), pg_sleep($3) from users`, "int", "[]byte", "float64")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+
+		call = testCall(`select chr($1) from users`, "string")
+		errs = checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Parameter: 1, DBType: "integer"},
+		)
+
+		refs := selectOutputCols(t, state, `select ascii(name), regexp_split_to_array(name, ','), justify_hours(timeout), sqrt(id) from users`)
+		want := []string{"integer", "ARRAYtext", "interval", ""}
+		if len(refs) != len(want) {
+			t.Fatalf("want %d columns, got: %v", len(want), refs)
+		}
+		for i, ref := range refs {
+			got := ""
+			if ref.col != nil {
+				got = ref.col.DBType
+			}
+			if got != want[i] {
+				t.Errorf("column %d: want %q, got %q", i, want[i], got)
+			}
+		}
+
+		refs = selectOutputCols(t, state, `select mode() within group (order by name) from users`)
+		if len(refs) != 1 || refs[0].col == nil || refs[0].col.DBType != "text" {
+			t.Errorf("want text, got: %v", refs)
+		}
+	})
+	t.Run("UserOverridesBuiltin", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{
+			DBInfo: state.DBInfo,
+			Functions: map[string]pgFunction{
+				"lower": {min: 1, max: 1, args: []string{"integer"}, ret: "integer"},
+			},
+		}
+		call := testCall(`select lower($1) from users`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Ranges", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select tstzrange(now(), null), daterange($1, $2, '[]'), isempty(int4range(1, id)), upper_inf(numrange(1, 2)) from users`,
+			"time.Time", "time.Time")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("TypeNameCasts", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select date(now()), int4(name), text(id), timestamptz(1) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+
+		checkOutputCols(t, state, `select date(now())`,
+			outputCol{Name: "date", DBType: "date"},
+		)
+	})
+	t.Run("Variadic", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select concat(name, name, name, 'a') from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("UserDefined", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select slugify(name), slugify(name, '-'), st_distance(1, 2, 3) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("UserDefinedArity", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select slugify() from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncArity, Name: "slugify"},
+		)
+	})
+	t.Run("OtherSchema", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select util.whatever(name) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AggregateWhere", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where count(*) > 1`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncAggregate, Name: "count", Clause: "WHERE", Location: 27},
+		)
+	})
+	t.Run("AggregateGroupBy", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users group by max(id)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncAggregate, Clause: "GROUP BY"},
+		)
+	})
+	t.Run("AggregateJoin", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users u join users v on max(u.id) = v.id`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncAggregate, Clause: "JOIN conditions"},
+		)
+	})
+	t.Run("AggregateUpdate", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set id = max(id)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncAggregate, Clause: "UPDATE"},
+		)
+	})
+	t.Run("AggregateHaving", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users group by id having count(*) > 1 order by sum(id)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AggregateSubquery", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where id = (select max(id) from users)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Nested", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select max(count(*)) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncNestedAggregate, Name: "count", Location: 11},
+		)
+	})
+	t.Run("NestedSubquery", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select max((select count(*) from users)) from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Window", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select sum(count(*)) over () from users group by id`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestWindows(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{Name: "videos", Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "user_id", DBType: "integer"},
+				{Name: "created_at", DBType: "timestamp with time zone"},
+			}},
+		},
+	}
+
+	t.Run("Over", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select row_number() over (partition by user_id order by created_at) from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("OverUnknownColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over (partition by owner_id) from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "owner_id", Location: 33},
+		)
+	})
+	t.Run("Named", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over w, sum(id) over (w rows unbounded preceding) from videos window w as (partition by user_id order by id)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("NamedUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over w from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncUnknownWindow, Name: "w", Location: 19},
+		)
+	})
+	t.Run("NamedRefUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over (v order by id) from videos window w as (partition by user_id)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncUnknownWindow, Name: "v", Location: 19},
+		)
+	})
+	t.Run("NamedBuildsOnEarlier", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over b from videos window a as (partition by user_id), b as (a order by id)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("NamedBuildsOnLater", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over a from videos window a as (b order by id), b as (partition by user_id)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncUnknownWindow, Name: "b", Location: 45},
+		)
+	})
+	t.Run("NamedWindowColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over w from videos window w as (order by updated_at)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "updated_at", Location: 55},
+		)
+	})
+	t.Run("NamedOuterSelect", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select (select rank() over w from videos limit 1) from videos window w as ()`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncUnknownWindow, Name: "w", Location: 27},
+		)
+	})
+	t.Run("Where", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from videos where row_number() over () > 1`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindow, Name: "row_number", Clause: "WHERE", Location: 28},
+		)
+	})
+	t.Run("GroupBy", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos group by rank() over ()`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindow, Name: "rank", Clause: "GROUP BY", Location: 30},
+		)
+	})
+	t.Run("Having", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select user_id from videos group by user_id having count(*) over () > 1`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindow, Name: "count", Clause: "HAVING", Location: 51},
+		)
+	})
+	t.Run("InAggregate", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select max(rank() over ()) from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindow, Name: "rank", Clause: "aggregate function arguments", Location: 11},
+		)
+	})
+	t.Run("Nested", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select sum(rank() over ()) over () from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindow, Name: "rank", Clause: "window function arguments", Location: 11},
+		)
+	})
+	t.Run("InDefinition", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select rank() over (order by rank() over ()) from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindow, Name: "rank", Clause: "window definitions", Location: 29},
+		)
+	})
+	t.Run("OrderBy", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from videos order by row_number() over (order by id)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("SubqueryInWhere", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from videos where id in (select max(id) over () from videos)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("WithoutOver", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select row_number() from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			FuncErr{Kind: FuncWindowOver, Name: "row_number", Location: 7},
+		)
+	})
+	t.Run("AggregateOverGroup", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select user_id, sum(count(*)) over () from videos group by user_id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"001_functions.sql": `
+-- +migrate Up
+create function slugify(s text, sep text default '-') returns text as $$
+	select lower(s)
+$$ language sql;
+create function slugify(s text, sep text, max int) returns text as $$
+	select lower(s)
+$$ language sql;
+create function total(variadic nums int[]) returns int as $$
+	select 1
+$$ language sql;
+create aggregate product(numeric) (sfunc = numeric_mul, stype = numeric);
+`,
+		"002_broken.sql": `create function oops(`,
+		"003_identity.sql": `
+create table users (
+	id int generated always as identity,
+	uid int generated by default as identity,
+	gid int generated always as identity,
+	email text
+);
+alter table users alter column uid set generated always;
+alter table users alter column uid set increment by 2 restart;
+alter table users add constraint users_email_key unique (email);
+alter table users alter column gid drop identity;
+alter table users alter column email add generated always as identity;
+`,
+		"readme.md": `not sql`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, warns, err := loadMigrations([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warns) != 1 || !strings.Contains(warns[0].Error(), "002_broken.sql") {
+		t.Error("want a warning for the broken migration, got:", warns)
+	}
+
+	want := map[string]pgFunction{
+		"slugify": {min: 1, max: 3, ret: "text"},
+		"total":   {min: 1, max: -1, args: []string{"ARRAYinteger"}, ret: "integer"},
+		"product": {min: 1, max: 1, aggregate: true},
+	}
+	if !reflect.DeepEqual(info.functions, want) {
+		t.Errorf("functions wrong\nwant: %#v\ngot:  %#v", want, info.functions)
+	}
+
+	// The driver calls every identity column's default IDENTITY
+	dbInfo := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{
+				{Name: "id", Default: "IDENTITY"},
+				{Name: "uid", Default: "IDENTITY"},
+				{Name: "gid"},
+				{Name: "email", Default: "IDENTITY"},
+			}},
+		},
+	}
+	info.markIdentities(dbInfo, nil)
+
+	var defaults []string
+	for _, c := range dbInfo.Tables[0].Columns {
+		defaults = append(defaults, c.Default)
+	}
+	wantDefaults := []string{identityAlways, identityAlways, "", identityAlways}
+	if !reflect.DeepEqual(defaults, wantDefaults) {
+		t.Errorf("identity defaults wrong\nwant: %q\ngot:  %q", wantDefaults, defaults)
+	}
+}
+
+func TestEnums(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "videos",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "status", Type: "string", DBType: "enum.video_status('draft','published')"},
+						{Name: "old_status", Type: "null.String", DBType: "enum.video_status('draft','published')", Nullable: true},
+					},
+				},
+			},
+		},
+	}
+
+	withValues := func(c Call, values ...constant.Value) Call {
+		c.Values = values
+		return c
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from videos where status = 'draft' or 'published' = status`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Where", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from videos where status = 'actve'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			EnumErr{Column: "status", Enum: "video_status", Value: "actve", Location: 37},
+		)
+	})
+	t.Run("In", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from videos where status in ('draft', 'publisehd')`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			EnumErr{Column: "status", Enum: "video_status", Value: "publisehd", Location: 48},
+		)
+	})
+	t.Run("Insert", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into videos (id, status) values (1, 'drfat')`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			EnumErr{Column: "status", Enum: "video_status", Value: "drfat", Location: 43},
+		)
+	})
+	t.Run("Update", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update videos set status = 'pub', old_status = coalesce(old_status, 'x')`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			EnumErr{Column: "status", Enum: "video_status", Value: "pub", Location: 27},
+			EnumErr{Column: "old_status", Enum: "video_status", Value: "x", Location: 68},
+		)
+	})
+	t.Run("Null", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update videos set old_status = null`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Constant", func(t *testing.T) {
+		t.Parallel()
+
+		call := withValues(testCall(`select id from videos where status = $1`, "string"), constant.MakeString("published"))
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("BadConstant", func(t *testing.T) {
+		t.Parallel()
+
+		call := withValues(testCall(`select id from videos where status = $1`, "string"), constant.MakeString("deleted"))
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			EnumErr{Column: "status", Enum: "video_status", Value: "deleted", Parameter: 1, Location: 37},
+		)
+	})
+	t.Run("Variable", func(t *testing.T) {
+		t.Parallel()
+
+		call := withValues(testCall(`select id from videos where status = $1`, "string"), nil)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestLiterals(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "name", Type: "string", DBType: "text"},
+						{Name: "active", Type: "bool", DBType: "boolean"},
+						{Name: "uid", Type: "string", DBType: "uuid"},
+						{Name: "data", Type: "types.JSON", DBType: "jsonb"},
+						{Name: "tags", Type: "types.StringArray", DBType: "ARRAYtext"},
+						{Name: "created_at", Type: "time.Time", DBType: "timestamp with time zone"},
+					},
+				},
+			},
+		},
+		// The inserts leave columns out on purpose
+		Nullability: nullabilityOff,
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where id = '5' and active = 'yes' and uid = 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11' and data = '{"a": 1}' and created_at > 'now' and tags = '{a,b}' and id < 5.5`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("StringForInt", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where id = 'abc'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "id", Literal: "'abc'", LiteralType: "unknown", Location: 32},
+		)
+	})
+	t.Run("IntForTime", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where created_at > 5`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "created_at", Literal: "5", LiteralType: "integer", Location: 40},
+		)
+	})
+	t.Run("IntForText", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where 5 = name`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "name", Literal: "5", LiteralType: "integer", Location: 27},
+		)
+	})
+	t.Run("In", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where id in (1, 'x', 3)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "id", Literal: "'x'", LiteralType: "unknown", Location: 37},
+		)
+	})
+	t.Run("InsertValues", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (id, name, active) values (1.5, 5, 1)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "active", Literal: "1", LiteralType: "integer", Location: 53},
+		)
+	})
+	t.Run("UpdateSet", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set uid = 'not-a-uuid', data = '{', created_at = 'never'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "uid", Literal: "'not-a-uuid'", LiteralType: "unknown", Location: 23},
+			LiteralErr{Column: "data", Literal: "'{'", LiteralType: "unknown", Location: 44},
+			LiteralErr{Column: "created_at", Literal: "'never'", LiteralType: "unknown", Location: 62},
+		)
+	})
+	t.Run("Cast", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 'abc'::int, 5::text, 5::boolean, 5::uuid`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "::integer", Literal: "'abc'", LiteralType: "unknown", Location: 7},
+			LiteralErr{Column: "::uuid", Literal: "5", LiteralType: "integer", Location: 40},
+		)
+	})
+	t.Run("Limit", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users limit 'x'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			LiteralErr{Column: "LIMIT", Literal: "'x'", LiteralType: "unknown", Location: 27},
+		)
+	})
+	t.Run("FunctionArgs", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select lower(5), to_timestamp('2020', 'YYYY')`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Null", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set name = null where id = null`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestComparisons(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "uid", Type: "string", DBType: "uuid"},
+						{Name: "email", Type: "string", DBType: "text"},
+						{Name: "created_at", Type: "time.Time", DBType: "timestamp with time zone"},
+					},
+				},
+				{
+					Name: "videos",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int64", DBType: "bigint"},
+						{Name: "user_id", Type: "int", DBType: "integer"},
+						{Name: "user_uid", Type: "string", DBType: "uuid"},
+						{Name: "title", Type: "string", DBType: "character varying"},
+						{Name: "published_at", Type: "time.Time", DBType: "date"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Compatible", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos v join users u on v.user_id = u.id and v.id > u.id and v.user_uid = u.uid where v.title <> u.email and v.published_at < u.created_at`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Join", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos join users on videos.user_id = users.email`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "videos.user_id", LeftType: "integer", Right: "users.email", RightType: "text", Operator: "=", Location: 50},
+		)
+	})
+	t.Run("Where", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos, users where user_uid = email or user_id < created_at`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "user_uid", LeftType: "uuid", Right: "email", RightType: "text", Operator: "=", Location: 43},
+			CompareErr{Left: "user_id", LeftType: "integer", Right: "created_at", RightType: "timestamp with time zone", Operator: "<", Location: 62},
+		)
+	})
+	t.Run("In", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos, users where user_id in (users.id, email)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "user_id", LeftType: "integer", Right: "email", RightType: "text", Operator: "IN", Location: 42},
+		)
+	})
+	t.Run("Distinct", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos, users where user_uid is distinct from users.id`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "user_uid", LeftType: "uuid", Right: "users.id", RightType: "integer", Operator: "IS DISTINCT FROM", Location: 43},
+		)
+	})
+	t.Run("Arithmetic", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from videos, users where created_at - published_at > created_at - created_at`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestJSONOperators(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int", DBType: "integer"},
+						{Name: "email", Type: "string", DBType: "text"},
+						{Name: "data", Type: "types.JSON", DBType: "jsonb"},
+						{Name: "raw", Type: "types.JSON", DBType: "json"},
+						{Name: "tags", Type: "types.StringArray", DBType: "ARRAYtext"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Operators", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select data->'a', data->>'a', raw #> '{a,b}', raw #>> '{a,b}', data - 'a', data #- '{a}' from users where data ? 'a' and data ?| array['a'] and data @> '{}'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Nested", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select data->'a'->'b'->>'c' from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("TextColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email->>'a' from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "->>", Operand: "email", DBType: "text", Location: 12},
+		)
+	})
+	t.Run("IntegerColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where id ? 'a'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "?", Operand: "id", DBType: "integer", Location: 29},
+		)
+	})
+	t.Run("ExistsOnJSON", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where raw ? 'a'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "?", Operand: "raw", DBType: "json", Location: 30},
+		)
+	})
+	t.Run("ContainsOnText", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where email @> 'a'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "@>", Operand: "email", DBType: "text", Location: 32},
+		)
+	})
+	t.Run("ContainsOnArray", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where tags @> array['a']`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Expression", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select (email || 'a')->'b' from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "->", DBType: "text", Location: 21},
+		)
+	})
+	t.Run("ResultText", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where data->>'age' = $1`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("ResultTextInt", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where data->>'age' = $1`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "data->>'age'", CallType: "int", DBType: "text", Parameter: 1},
+		)
+	})
+	t.Run("ResultTextBytes", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where data->>'name' = $1`, "[]byte")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "data->>'name'", CallType: "[]byte", DBType: "text", Parameter: 1},
+		)
+	})
+	t.Run("ResultJSONBytes", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where data->'name' = $1`, "[]byte")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("KeyParam", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where data ? $1`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "?", CallType: "int", DBType: "text", Parameter: 1},
+		)
+	})
+	t.Run("PathParam", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select data #> $1 from users`, "github.com/lib/pq.StringArray")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("ContainsParam", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where data @> $1`, "[]byte")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestArrays(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{
+					Name: "users",
+					Columns: []drivers.Column{
+						{Name: "id", Type: "int64", DBType: "bigint"},
+						{Name: "email", Type: "string", DBType: "text"},
+						{Name: "tags", Type: "types.StringArray", DBType: "ARRAYtext"},
+						{Name: "friend_ids", Type: "types.Int64Array", DBType: "ARRAYbigint"},
+						{Name: "scores", Type: "types.Int64Array", DBType: "ARRAYinteger"},
+						{Name: "data", Type: "types.JSON", DBType: "jsonb"},
+						{Name: "attrs", Type: "string", DBType: "hstore"},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("Any", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where id = any($1)`, "github.com/lib/pq.Int64Array")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AnyWrongElem", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where id = any($1)`, "github.com/lib/pq.BoolArray")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "id", CallType: "github.com/lib/pq.BoolArray", DBType: "ARRAYbigint", Parameter: 1},
+		)
+	})
+	t.Run("NotAll", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where email <> all($1)`, "github.com/lib/pq.StringArray")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AnyColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where $1 = any(tags)`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AnyColumnWrongElem", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where $1 = any(tags)`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "tags", CallType: "int", DBType: "text", Parameter: 1},
+		)
+	})
+	t.Run("AnyColumnCompare", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where id = any(tags)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "id", LeftType: "bigint", Right: "tags", RightType: "ARRAYtext", Operator: "= ANY", Location: 29},
+		)
+	})
+	t.Run("AnyCompatibleElem", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where id = any(scores)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AnyNotArray", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where id = any(email)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "= ANY", Operand: "email", DBType: "text", Location: 29},
+		)
+	})
+	t.Run("Contains", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where tags @> $1 and friend_ids && $2`, "github.com/lib/pq.StringArray", "github.com/lib/pq.Int64Array")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("ContainsWrongType", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where tags @> $1`, "github.com/lib/pq.Int64Array")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "tags", CallType: "github.com/lib/pq.Int64Array", DBType: "ARRAYtext", Parameter: 1},
+		)
+	})
+	t.Run("ContainedBy", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where $1 <@ tags`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "tags", CallType: "string", DBType: "ARRAYtext", Parameter: 1},
+		)
+	})
+	t.Run("ContainsColumns", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where tags @> friend_ids`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "tags", LeftType: "ARRAYtext", Right: "friend_ids", RightType: "ARRAYbigint", Operator: "@>", Location: 31},
+		)
+	})
+	t.Run("OverlapsCompatible", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where friend_ids && scores`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("OverlapsNotArray", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where email && tags`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "&&", Operand: "email", DBType: "text", Location: 32},
+		)
+	})
+	t.Run("Subscript", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select tags[1], tags[1:2] from users where friend_ids[$1] = $2`, "int", "int64")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("SubscriptElem", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users where tags[1] = $1`, "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{CallType: "int", DBType: "text", Parameter: 1},
+		)
+	})
+	t.Run("SubscriptIndex", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select tags[$1] from users`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "array subscript", CallType: "string", DBType: "integer", Parameter: 1},
+		)
+	})
+	t.Run("SubscriptNotArray", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email[1] from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			OperatorErr{Operator: "[]", Operand: "email", DBType: "text", Location: 7},
+		)
+	})
+	t.Run("SubscriptKeys", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select data['a'], attrs['b'] from users where data[$1] is not null`, "string")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("UpdateSubscript", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set tags[1] = $1, friend_ids[$2:$3] = $4`, "string", "int", "int", "github.com/lib/pq.Int64Array")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("UpdateSubscriptElem", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set tags[$1] = $2`, "string", "int")
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			TypeErr{Column: "array subscript", CallType: "string", DBType: "integer", Parameter: 1},
+			TypeErr{Column: "tags", CallType: "int", DBType: "text", Parameter: 2},
+		)
+	})
+}
+
+func TestNotNull(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{
+				{Name: "id", Type: "int", DBType: "integer", Default: "nextval('users_id_seq'::regclass)"},
+				{Name: "email", Type: "string", DBType: "text"},
+				{Name: "name", Type: "null.String", DBType: "text", Nullable: true},
+				{Name: "active", Type: "bool", DBType: "boolean", Default: "true"},
+			}},
+		},
+	}
+
+	t.Run("Complete", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users (email) values ('a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("Missing", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users (name) values ('a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "users", Column: "email", Location: 12},
+		)
+	})
+	t.Run("MissingPositional", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users values (default)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "users", Column: "email", Location: 12},
+		)
+	})
+	t.Run("Positional", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users values (default, 'a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("DefaultValues", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users default values`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "users", Column: "email", Location: 12},
+		)
+	})
+	t.Run("DefaultWithout", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users (email) values (default)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "users", Column: "email", Location: 34},
+		)
+	})
+	t.Run("Null", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users (email, name, active) values (null, null, null::boolean)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullLiteral, Table: "users", Column: "email", Location: 48},
+			NullErr{Kind: NullLiteral, Table: "users", Column: "active", Location: 60},
+		)
+	})
+	t.Run("Select", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users select * from users`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("SelectNamed", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users (name) select name from users`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "users", Column: "email", Location: 12},
+		)
+	})
+	t.Run("CreatedTable", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`create temp table t (id serial, a int generated always as identity, b text default 'x', c text not null); insert into t (c) values ('a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("CreatedTableMissing", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`create temp table t (id serial, c text not null); insert into t (id) values (1)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "t", Column: "c", Location: 62},
+		)
+	})
+	t.Run("DroppedDefault", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`alter table users alter column active drop default; insert into users (email) values ('a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullMissing, Table: "users", Column: "active", Location: 64},
+		)
+	})
+	t.Run("Off", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, Nullability: nullabilityOff}
+		call := testCall(`insert into users (name) values (null)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("NullableParam", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`insert into users (email) values ($1)`, "*string")
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("StrictParam", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, Nullability: nullabilityStrict}
+		call := testCall(`insert into users (email, name) values ($1, $2)`, "*string", "*string")
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullParam, Table: "users", Column: "email", Parameter: 1, CallType: "*string", Location: 40},
+		)
+	})
+	t.Run("StrictNullType", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, Nullability: nullabilityStrict}
+		call := testCall(`update users set email = $1 where id = $2`, "database/sql.NullString", "github.com/volatiletech/null/v8.Int")
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			NullErr{Kind: NullParam, Table: "users", Column: "email", Parameter: 1, CallType: "database/sql.NullString", Location: 25},
+		)
+	})
+	t.Run("StrictPlain", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, Nullability: nullabilityStrict}
+		call := testCall(`insert into users (email) values ($1)`, "string")
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestColumnWrites(t *testing.T) {
+	t.Parallel()
+
+	state := &State{
+		DBInfo: &drivers.DBInfo{
+			Tables: []drivers.Table{
+				{Name: "users", Columns: []drivers.Column{
+					{Name: "id", Type: "int", DBType: "integer", Default: identityAlways},
+					{Name: "email", Type: "string", DBType: "text"},
+					{Name: "uid", Type: "int", DBType: "integer", Default: "IDENTITY"},
+					{Name: "created_at", Type: "time.Time", DBType: "timestamp with time zone", Default: "now()"},
+				}},
+			},
+		},
+		Immutable: []string{"users.created_at"},
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (email, created_at) values ('a', now())`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("InsertIdentity", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (id, email) values (1, 'a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Table: "users", Column: "id", Kind: "identity", Stmt: "INSERT", Location: 19},
+		)
+	})
+	t.Run("InsertIdentityOverriding", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (id, email) overriding system value values (1, 'a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("InsertIdentityDefault", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (id, email) values (default, 'a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("InsertIdentityByDefault", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (email, uid) values ('a', 1)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("InsertPositional", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users values (1, 'a')`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Table: "users", Column: "id", Kind: "identity", Stmt: "INSERT", Location: 12},
+		)
+	})
+	t.Run("InsertSelect", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (id, email) select id, email from users`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Table: "users", Column: "id", Kind: "identity", Stmt: "INSERT", Location: 19},
+		)
+	})
+	t.Run("Update", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set email = 'a'`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("UpdateIdentity", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set id = 2, email = 'a'`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Table: "users", Column: "id", Kind: "identity", Stmt: "UPDATE", Location: 17},
+		)
+	})
+	t.Run("UpdateImmutable", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update public.users set created_at = now()`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Schema: "public", Table: "users", Column: "created_at", Kind: "immutable", Stmt: "UPDATE", Location: 24},
+		)
+	})
+	t.Run("UpdateDefault", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update users set created_at = default, id = default`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs)
+	})
+	t.Run("OnConflict", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into users (email) values ('a') on conflict (email) do update set email = excluded.email, created_at = now()`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Table: "users", Column: "created_at", Kind: "immutable", Stmt: "UPDATE", Location: 97},
+		)
+	})
+	t.Run("Message", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update public.users set created_at = now()`)
+		errs := checkCallWithState(state.withTables(), call)
+		if len(errs) != 1 {
+			t.Fatal("want one error, got:", errs)
+		}
+		if msg := errs[0].Error(); !strings.Contains(msg, `"users.created_at"`) {
+			t.Error("message should leave out the public schema:", msg)
+		}
+	})
+	t.Run("CreatedIdentity", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create temp table t (a int generated always as identity, b int generated by default as identity, c text); insert into t (b, c) values (1, 'x'); insert into t (a) values (1)`)
+		errs := checkCallWithState(state.withTables(), call)
+		checkErrs(t, errs,
+			ColumnWriteErr{Table: "t", Column: "a", Kind: "identity", Stmt: "INSERT", Location: 159},
+		)
+	})
+}
+
+func TestSearchPath(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Schema: "public",
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{{Name: "email"}}},
+			{Name: "users", SchemaName: "tenant", Columns: []drivers.Column{{Name: "tenant_id"}}},
+			{Name: "orders", SchemaName: "tenant", Columns: []drivers.Column{{Name: "id"}}},
+		},
+	}
+	tenantPath := []string{"$user", "tenant", "public"}
+
+	t.Run("Default", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`select email, users.email, public.users.email from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DefaultOtherSchema", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`select tenant_id from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "tenant_id", Location: 7},
+		)
+	})
+	t.Run("DefaultUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`select id from orders`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "orders", Location: 15},
+			IdentErr{Column: "id", Location: 7},
+		)
+	})
+	t.Run("Qualified", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info}
+		call := testCall(`select tenant.users.tenant_id, tenant.orders.id from tenant.users, tenant.orders`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Path", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, SearchPath: tenantPath}
+		call := testCall(`select id from orders`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("PathAmbiguous", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, SearchPath: tenantPath}
+		call := testCall(`select tenant_id from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			SchemaWarn{Table: "users", Schemas: []string{"tenant", "public"}, Location: 22, Fn: testCall(`select tenant_id from users`)},
+		)
+	})
+	t.Run("PathQualified", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, SearchPath: tenantPath}
+		call := testCall(`select email from public.users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("PathUpdate", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, SearchPath: tenantPath}
+		call := testCall(`update users set tenant_id = 1`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			SchemaWarn{Table: "users", Schemas: []string{"tenant", "public"}, Location: 7, Fn: testCall(`update users set tenant_id = 1`)},
+		)
+	})
+	t.Run("CTE", func(t *testing.T) {
+		t.Parallel()
+
+		state := &State{DBInfo: info, SearchPath: tenantPath}
+		call := testCall(`with users as (select 1 as one) select one from users`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+
+	t.Run("IdentErrSchema", func(t *testing.T) {
+		t.Parallel()
+
+		errs := checkCallWithState(&State{DBInfo: info}, testCall(`select users.nope from tenant.users`))
+		checkErrs(t, errs, IdentErr{Schema: "tenant", Table: "users", Column: "nope", Location: 7})
+		if len(errs) == 1 && !strings.Contains(errs[0].Error(), "tenant.users.nope") {
+			t.Error("error should show the schema:", errs[0])
+		}
+
+		errs = checkCallWithState(&State{DBInfo: info}, testCall(`select users.nope from users`))
+		checkErrs(t, errs, IdentErr{Schema: "public", Table: "users", Column: "nope", Location: 7})
+		if len(errs) == 1 && strings.Contains(errs[0].Error(), "public.") {
+			t.Error("error should leave out the public schema:", errs[0])
+		}
+	})
+}
+
+func TestRelations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	migration := `
+create view active_users as select id, email from users where active;
+create view user_counts (email, n) as select email, count(*) from users group by email;
+create materialized view user_ids as select id from users;
+create foreign table remote_users (id int not null, name text) server remote;
+create table events (id bigint primary key, at timestamptz) partition by range (at);
+create view pairs as select u.id from users u join users v on u.id = v.id;
+create trigger pairs_insert instead of insert on pairs for each row execute procedure insert_pair();
+create view active_ids as select id from active_users;
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "001.sql"), []byte(migration), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, _, err := loadMigrations([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &drivers.DBInfo{
+		Schema: "public",
+		Tables: []drivers.Table{
+			{
+				Name: "users",
+				Columns: []drivers.Column{
+					{Name: "id", Type: "int", DBType: "integer"},
+					{Name: "email", Type: "string", DBType: "text"},
+					{Name: "active", Type: "bool", DBType: "boolean"},
+				},
+			},
+		},
+	}
+	relations, warns := addRelations(info, nil, migrations)
+	if len(warns) != 0 {
+		t.Error("want no warnings, got:", warns)
+	}
+	state := &State{DBInfo: info, Relations: relations}
+
+	wantTypes := map[string][]string{
+		"active_users": {"integer", "text"},
+		"user_counts":  {"text", "bigint"},
+		"user_ids":     {"integer"},
+		"remote_users": {"integer", "text"},
+		"events":       {"bigint", "timestamp with time zone"},
+		"pairs":        {"integer"},
+		"active_ids":   {"integer"},
+	}
+	for _, table := range info.Tables[1:] {
+		var got []string
+		for _, c := range table.Columns {
+			got = append(got, c.DBType)
+		}
+		if !reflect.DeepEqual(wantTypes[table.Name], got) {
+			t.Errorf("%s column types wrong, want: %v, got: %v", table.Name, wantTypes[table.Name], got)
+		}
+	}
+	if len(info.Tables) != len(wantTypes)+1 {
+		t.Error("wrong number of tables:", len(info.Tables))
+	}
+
+	writeErr := func(kind, stmt, table string, location int, sql string) WriteErr {
+		return WriteErr{Schema: "public", Table: table, Kind: kind, Stmt: stmt, Location: location, Fn: testCall(sql)}
+	}
+
+	t.Run("Select", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select n from user_counts uc join active_users au on au.email = uc.email join remote_users r on r.id = au.id`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Partitioned", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into events (id, at) values (1, now())`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Foreign", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update remote_users set name = 'a'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Updatable", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update active_users set email = 'a'; insert into active_ids (id) values (1)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Grouped", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`update user_counts set email = 'a'`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			writeErr("view", "UPDATE", "user_counts", 7, `update user_counts set email = 'a'`),
+		)
+	})
+	t.Run("MatView", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`delete from user_ids`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			writeErr("materialized view", "DELETE", "user_ids", 12, `delete from user_ids`),
+		)
+	})
+	t.Run("InsteadOf", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`insert into pairs (id) values (1)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Join", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`delete from pairs`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			writeErr("view", "DELETE", "pairs", 12, `delete from pairs`),
+		)
+	})
+}
+
+func TestRelationChanges(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	migration := `
+create view old_users as select id, email from users;
+create view gone as select id from users;
+alter view old_users rename to named_users;
+alter table named_users rename column email to address;
+drop view gone;
+create view broken as select nope from users;
+create view pairs as select u.id from users u join users v on u.id = v.id;
+create trigger pairs_insert instead of insert on pairs for each row execute procedure insert_pair();
+alter view pairs rename to user_pairs;
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "001.sql"), []byte(migration), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, _, err := loadMigrations([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &drivers.DBInfo{
+		Schema: "public",
+		Tables: []drivers.Table{
+			{
+				Name: "users",
+				Columns: []drivers.Column{
+					{Name: "id", Type: "int", DBType: "integer"},
+					{Name: "email", Type: "string", DBType: "text"},
+				},
+			},
+		},
+	}
+	relations, warns := addRelations(info, nil, migrations)
+	if len(warns) != 1 || !strings.Contains(warns[0].Error(), "001.sql:7:1 unknown identifier in sql statement: nope") {
+		t.Error("want a warning for the broken view, got:", warns)
+	}
+	state := &State{DBInfo: info, Relations: relations}
+
+	var names []string
+	for _, table := range info.Tables {
+		names = append(names, table.Name)
+	}
+	if want := []string{"users", "named_users", "user_pairs"}; !reflect.DeepEqual(want, names) {
+		t.Errorf("tables wrong, want: %v, got: %v", want, names)
+	}
+
+	t.Run("Renamed", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id, address from named_users; insert into user_pairs (id) values (1)`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Dropped", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from gone`)
+		errs := checkCallWithState(state, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "gone", Location: 15},
+			IdentErr{Column: "id", Location: 7},
+		)
+	})
+}
+
+func TestSystemCatalog(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Schema: "public",
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "email", DBType: "text"},
+			}},
+			{Name: "videos", Columns: []drivers.Column{{Name: "id", DBType: "integer"}}},
+		},
+	}
+
+	t.Run("PgCatalog", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select c.relname, n.nspname from pg_class c join pg_namespace n on n.oid = c.relnamespace`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("PgCatalogQualified", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select pg_catalog.pg_class.relname from pg_catalog.pg_class`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Activity", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select pid, query from pg_stat_activity where state = 'idle'`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Locks", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select granted from pg_locks where locktype = 'advisory' and objid = 1`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("InformationSchema", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select column_name, data_type from information_schema.columns where table_name = 'users'`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("InformationSchemaUnqualified", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select table_name from tables`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "tables", Location: 23},
+			IdentErr{Column: "table_name", Location: 7},
+		)
+	})
+	t.Run("SystemColumns", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select ctid, xmin, tableoid from users where id = 1`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("SystemColumnQualified", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select u.ctid from users u`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("SystemColumnCatalog", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select xmin from pg_class`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("SystemColumnAmbiguous", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select ctid from users, videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Kind: Ambiguous, Column: "ctid", Location: 7},
+		)
+	})
+	t.Run("SystemColumnSubquery", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select s.ctid from (select id from users) s`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "s", Column: "ctid", Location: 7},
+		)
+	})
+	t.Run("SystemColumnOuter", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where exists (select 1 from (select 1) s where ctid is not null)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("NotExpanded", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from (select * from users) u where u.xmax = 0`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "u", Column: "xmax", Location: 44},
+		)
+	})
+	t.Run("Types", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select 1 from users, pg_class where users.id = pg_class.relname`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			CompareErr{Left: "users.id", LeftType: "integer", Right: "pg_class.relname", RightType: "name", Operator: "=", Location: 45},
+		)
+	})
+}
+
+func TestDDL(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Schema: "public",
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "email", DBType: "text"},
+			}},
+		},
+	}
+
+	t.Run("CreateTemp", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create temp table t (id int not null, name text); insert into t (id, name) values (1, 'a'); select id, name from t`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("CreateExists", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create table users (x int)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Kind: Exists, Table: "users", Location: 13},
+		)
+
+		call = testCall(`select 1 into users`)
+		errs = checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Kind: Exists, Table: "users", Location: 14},
+		)
+
+		call = testCall(`create table if not exists users (x int); select x from users`)
+		errs = checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "x", Location: 49},
+		)
+	})
+	t.Run("CreateUnknownColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create table t (id int); select name from t`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "name", Location: 32},
+		)
+	})
+	t.Run("CreateTableAs", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create temp table t as select id, email from users; select * from t where email = 'a'`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("CreateTableAsColumns", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create table t (a, b) as select id, email from users; select a, b from t`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("CreateTableAsErrors", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create table t as select nope from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "nope", Location: 25},
+		)
+	})
+	t.Run("SelectInto", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id as user_id into temp t from users; select user_id from t`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("TempShadows", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create temp table users (other int); select other from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AlterAdd", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table users add column age int; select age from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("AlterDrop", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table users drop column email; select email from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "email", Location: 44},
+		)
+	})
+	t.Run("AlterUnknownTable", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table nope add column x int`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "nope", Location: 12},
+		)
+	})
+	t.Run("AlterUnknownColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table users drop column nope`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Schema: "public", Table: "users", Column: "nope", Location: 12},
+		)
+	})
+	t.Run("AlterIfExists", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table if exists nope add column x int; alter table users drop column if exists nope`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("RenameColumn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table users rename column email to mail; select mail from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("RenameTable", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`alter table users rename to people; select id from people`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Drop", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`drop table users; select id from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "users", Location: 33},
+			IdentErr{Column: "id", Location: 25},
+		)
+	})
+	t.Run("DropIfExists", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`drop table if exists nope`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+
+	t.Run("Isolated", func(t *testing.T) {
+		t.Parallel()
+
+		errs := checkCallWithState(&State{DBInfo: info},
+			testCall(`alter table users add column age int`),
+			testCall(`select age from users`),
+		)
+		checkErrs(t, errs, IdentErr{Column: "age", Location: 7})
+		if len(info.Tables[0].Columns) != 2 {
+			t.Error("the database info should not change")
+		}
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		t.Parallel()
+
+		create := testCall(`create temp table t (id int)`)
+		create.Session = "import"
+		insert := testCall(`insert into t (id) select id from users`)
+		insert.Session = "import"
+		other := testCall(`select id from t`)
+		other.Session = "other"
+
+		errs := checkCallWithState(&State{DBInfo: info}, create, insert, other)
+		checkErrs(t, errs,
+			IdentErr{Table: "t", Location: 15},
+			IdentErr{Column: "id", Location: 7},
+		)
+	})
+}
+
+func TestSetOperations(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "email", DBType: "text"},
+			}},
+			{Name: "videos", Columns: []drivers.Column{
+				{Name: "id", DBType: "bigint"},
+				{Name: "user_id", DBType: "integer"},
+			}},
+		},
+	}
+
+	t.Run("Union", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users union select id from videos order by id limit 10`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Count", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id, email from users union select id from videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			SetOpErr{Op: "UNION", LeftCount: 2, RightCount: 1, Location: 41},
+		)
+	})
+	t.Run("Types", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users union all select email from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			SetOpErr{Op: "UNION", LeftCount: 1, RightCount: 1, Column: 1, LeftType: "integer", RightType: "text", Location: 38},
+		)
+	})
+	t.Run("Intersect", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users intersect select id from videos except select email from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			SetOpErr{Op: "EXCEPT", LeftCount: 1, RightCount: 1, Column: 1, LeftType: "integer", RightType: "text", Location: 67},
+		)
+	})
+	t.Run("UnknownLiteral", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email from users union select 'none'`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("UnresolvedSide", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users union select id from nope`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "nope", Location: 42},
+			IdentErr{Column: "id", Location: 34},
+		)
+
+		call = testCall(`select id, nope from users union select id from users`)
+		errs = checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "nope", Location: 11},
+		)
+	})
+	t.Run("Values", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id, email from users union values (1, 'a')`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("OrderByLeftNames", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id as n from users union select user_id from videos order by n`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("OrderByRightNames", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users union select user_id from videos order by user_id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Column: "user_id", Location: 63},
+		)
+	})
+	t.Run("Subquery", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select s.id from (select id from users union select user_id from videos) s`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("SubqueryUnknown", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select s.user_id from (select id from users union select user_id from videos) s`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "s", Column: "user_id", Location: 7},
+		)
+	})
+	t.Run("SubqueryStar", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email from (select * from users union select * from users) s`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("CTE", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`with u as (select id from users union select user_id from videos) select id from u`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestGrouping(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{Name: "users", PKey: &drivers.PrimaryKey{Columns: []string{"id"}}, Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "email", DBType: "text"},
+				{Name: "name", DBType: "text"},
+			}},
+			{Name: "videos", PKey: &drivers.PrimaryKey{Columns: []string{"id"}}, Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "user_id", DBType: "integer"},
+				{Name: "title", DBType: "text"},
+			}},
+			{Name: "tags", Columns: []drivers.Column{
+				{Name: "name", DBType: "text"},
+				{Name: "n", DBType: "integer"},
+			}},
+		},
+	}
+
+	t.Run("OrderByOrdinal", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id, email from users order by 2, 3`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			OrdinalErr{Clause: "ORDER BY", Position: 3, Count: 2, Location: 40},
+		)
+	})
+	t.Run("OrderByOrdinalStar", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users order by 3`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("OrderByOrdinalZero", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users order by 0`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			OrdinalErr{Clause: "ORDER BY", Position: 0, Count: 1, Location: 30},
+		)
+	})
+	t.Run("OrderByOrdinalUnion", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users union select id from videos order by 2`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			OrdinalErr{Clause: "ORDER BY", Position: 2, Count: 1, Location: 58},
+		)
+	})
+	t.Run("GroupByOrdinal", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, name from users group by 1, 3`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			OrdinalErr{Clause: "GROUP BY", Position: 3, Count: 2, Location: 42},
+			GroupingErr{Table: "users", Column: "name", Location: 14},
+		)
+	})
+	t.Run("Grouped", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, count(*) from users group by email`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("GroupedOrdinal", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, count(*) from users group by 1`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("GroupedAlias", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select lower(email) as e, count(*) from users group by e`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("GroupedExpression", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select lower(email), count(*) from users group by lower(email)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("GroupingSets", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, name, count(*) from users group by rollup (email, name)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Ungrouped", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, name, count(*) from users group by email`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "users", Column: "name", Location: 14},
+		)
+	})
+	t.Run("AggregateWithoutGroup", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, count(*) from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "users", Column: "email", Location: 7},
+		)
+	})
+	t.Run("InsideExpression", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select upper(name) || email from users group by email`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "users", Column: "name", Location: 13},
+		)
+	})
+	t.Run("Having", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email from users group by email having count(*) > 1 and name = 'a'`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "users", Column: "name", Location: 63},
+		)
+	})
+	t.Run("PrimaryKey", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select u.id, u.email, count(v.id) from users u join videos v on v.user_id = u.id group by u.id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("PrimaryKeyOtherTable", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select u.email, v.title from users u join videos v on v.user_id = u.id group by u.id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "v", Column: "title", Location: 16},
+		)
+	})
+	t.Run("SelfJoin", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select u.email, w.email from users u join users w on u.id = w.id group by u.id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "w", Column: "email", Location: 16},
+		)
+	})
+	t.Run("NoPrimaryKey", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select name, n from tags group by name`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "tags", Column: "n", Location: 13},
+		)
+	})
+	t.Run("Star", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from users group by id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("StarUngrouped", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select * from tags group by name`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			GroupingErr{Table: "tags", Column: "n", Location: 7},
+		)
+	})
+	t.Run("OuterReference", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id, (select count(*) from videos where user_id = users.id) from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("Window", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select email, count(*) over () from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("CreatedPrimaryKey", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`create temp table t (id int primary key, v text); select id, v from t group by id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+}
+
+func TestLockingAndDistinctOn(t *testing.T) {
+	t.Parallel()
+
+	info := &drivers.DBInfo{
+		Tables: []drivers.Table{
+			{Name: "users", Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "email", DBType: "text"},
+				{Name: "created_at", DBType: "timestamp with time zone"},
+			}},
+			{Name: "videos", Columns: []drivers.Column{
+				{Name: "id", DBType: "integer"},
+				{Name: "user_id", DBType: "integer"},
+			}},
+		},
+	}
+
+	t.Run("ForUpdate", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users for update`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("ForUpdateOf", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select users.id from users join videos on videos.user_id = users.id for update of users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("ForUpdateOfAlias", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select u.id from users u for share of u`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("ForUpdateOfAliasedName", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select u.id from users u for update of users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "users", Location: 39},
+		)
+	})
+	t.Run("ForUpdateOfMissing", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users for update of videos`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "videos", Location: 35},
+		)
+	})
+	t.Run("ForUpdateOfOuter", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select id from users where exists (select 1 from videos for update of users)`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			IdentErr{Table: "users", Location: 70},
+		)
+	})
+	t.Run("ForUpdateOfSubquery", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select s.id from (select id from users) s for update of s`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOn", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (email) email, id from users order by email, created_at desc`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOnUnordered", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (email) email, id from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOnReordered", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (id, email) * from users order by email, id, created_at`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOnShorterOrder", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (id, email) * from users order by id`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOnOrdinal", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (1) email, id from users order by email`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOnAlias", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (lower(email)) lower(email) as e from users order by e`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
+	t.Run("DistinctOnMismatch", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (email) email, id from users order by created_at`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			DistinctOnErr{Location: 20},
+		)
+	})
+	t.Run("DistinctOnMismatchLater", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (id, email) * from users order by id, created_at, email`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			DistinctOnErr{Location: 24},
+		)
+	})
+	t.Run("DistinctOnOrdinalRange", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct on (3) email, id from users`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs,
+			OrdinalErr{Clause: "DISTINCT ON", Position: 3, Count: 2, Location: 20},
+		)
+	})
+	t.Run("Distinct", func(t *testing.T) {
+		t.Parallel()
+
+		call := testCall(`select distinct email from users order by email`)
+		errs := checkCallWithState(&State{DBInfo: info}, call)
+		checkErrs(t, errs)
+	})
 }
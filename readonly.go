@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// identityAlways is the default of columns the migrations or DDL create as
+// GENERATED ALWAYS AS IDENTITY. The psql driver gives every identity column
+// the default IDENTITY whether it's ALWAYS or BY DEFAULT, so only these are
+// known to refuse values.
+const identityAlways = "GENERATED ALWAYS AS IDENTITY"
+
+// ColumnWriteErr occurs when a statement writes to a column that can't or
+// shouldn't be written to. Identity columns can only be inserted into with
+// OVERRIDING SYSTEM VALUE and immutable columns are the ones the config
+// says are never updated.
+type ColumnWriteErr struct {
+	Schema string
+	Table  string
+	Column string
+	// Kind is the kind of column: identity, immutable
+	Kind string
+	// Stmt is INSERT/UPDATE
+	Stmt     string
+	Location int
+
+	Fn Call
+}
+
+func (c ColumnWriteErr) Error() string {
+	ident := errIdent(c.Schema, c.Table, c.Column)
+
+	var hint string
+	if c.Kind == "identity" && c.Stmt == "INSERT" {
+		hint = " without OVERRIDING SYSTEM VALUE"
+	}
+
+	return fmt.Sprintf("%s:%d:%d cannot %s %s column %q%s (pos %d)",
+		c.Fn.Pos.Filename,
+		c.Fn.Pos.Line,
+		c.Fn.Pos.Column,
+		c.Stmt,
+		c.Kind,
+		ident,
+		hint,
+		c.Location,
+	)
+}
+
+func (c ColumnWriteErr) call() Call {
+	return c.Fn
+}
+
+// readOnlyKind returns why a statement can't write a column or the empty
+// string if it can. The schema is the table's real schema.
+func readOnlyKind(state *State, schema string, t *drivers.Table, col *drivers.Column, stmt string, override bool) string {
+	switch {
+	case col.Default == identityAlways && !override:
+		return "identity"
+	case stmt == "UPDATE" && immutableColumn(state, schema, t.Name, col.Name):
+		return "immutable"
+	}
+
+	return ""
+}
+
+// immutableColumn checks if the config lists a column as immutable, either
+// as table.column or schema.table.column
+func immutableColumn(state *State, schema, table, column string) bool {
+	for _, c := range state.Immutable {
+		if c == table+"."+column || c == schema+"."+table+"."+column {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkInsertWrites checks the columns an insert gives values to can be
+// written. Columns only given DEFAULT aren't written.
+func checkInsertWrites(state *State, fn Call, scope *Scope, ins pgnodes.InsertStmt, into *drivers.Table) (errs []error) {
+	var schema string
+	if ins.Relation.Schemaname != nil {
+		schema = *ins.Relation.Schemaname
+	}
+	override := ins.Override == pgnodes.OVERRIDING_SYSTEM_VALUE
+
+	var values [][]pgnodes.Node
+	if sel, ok := ins.SelectStmt.(pgnodes.SelectStmt); ok {
+		values = sel.ValuesLists
+	} else if ins.SelectStmt == nil {
+		// DEFAULT VALUES
+		return nil
+	}
+
+	// written reports if anything but DEFAULT is put in the i'th column
+	written := func(i int) bool {
+		if len(values) == 0 {
+			return true
+		}
+		for _, row := range values {
+			if i < len(row) && !isDefault(row[i]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cols := insertColumns(into, ins.Cols)
+	for i, col := range cols {
+		if col == nil || !written(i) {
+			continue
+		}
+
+		location := ins.Relation.Location
+		if i < len(ins.Cols.Items) {
+			location = ins.Cols.Items[i].(pgnodes.ResTarget).Location
+		} else if len(values) == 0 || i >= len(values[0]) {
+			// Without names a select's columns can't be followed and
+			// values only fill as many columns as they have
+			break
+		}
+
+		kind := readOnlyKind(state, scope.schemaOf(into), into, col, "INSERT", override)
+		if len(kind) == 0 {
+			continue
+		}
+		errs = append(errs, ColumnWriteErr{
+			Schema:   schema,
+			Table:    into.Name,
+			Column:   col.Name,
+			Kind:     kind,
+			Stmt:     "INSERT",
+			Location: location,
+			Fn:       fn,
+		})
+	}
+
+	return errs
+}
+
+// checkUpdateWrite checks a column set by an update can be written, setting
+// it to DEFAULT is always allowed. into is the index of the updated table in
+// the scope.
+func checkUpdateWrite(state *State, fn Call, scope *Scope, schema string, into int, target pgnodes.ResTarget) []error {
+	if target.Name == nil || isDefault(target.Val) {
+		return nil
+	}
+
+	t := scope.tables[into]
+	index := columnIndex(t, *target.Name)
+	if index < 0 {
+		return nil
+	}
+
+	col := &t.Columns[index]
+	kind := readOnlyKind(state, scope.schemaOf(t), t, col, "UPDATE", false)
+	if len(kind) == 0 {
+		return nil
+	}
+
+	return []error{ColumnWriteErr{
+		Schema:   schema,
+		Table:    t.Name,
+		Column:   col.Name,
+		Kind:     kind,
+		Stmt:     "UPDATE",
+		Location: target.Location,
+		Fn:       fn,
+	}}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// Kinds of relations, tables are what sqlboiler loads itself
+const (
+	relTable = iota
+	relView
+	relMatView
+	relForeignTable
+	relPartitioned
+)
+
+// relKindNames are how the kinds of relations are called in errors
+var relKindNames = map[int]string{
+	relTable:        "table",
+	relView:         "view",
+	relMatView:      "materialized view",
+	relForeignTable: "foreign table",
+	relPartitioned:  "partitioned table",
+}
+
+// relation is what's known about a relation other than its columns.
+// Views that postgres can't update automatically can still be written to
+// by the statements in writable, which have INSTEAD OF triggers or rules.
+type relation struct {
+	kind      int
+	updatable bool
+	writable  []string
+}
+
+// WriteErr occurs when a statement writes to a relation that can't be
+// written to like a materialized view or a view that isn't updatable.
+type WriteErr struct {
+	Schema string
+	Table  string
+	// Kind is the kind of relation: view, materialized view
+	Kind string
+	// Stmt is INSERT/UPDATE/DELETE
+	Stmt     string
+	Location int
+
+	Fn Call
+}
+
+func (w WriteErr) Error() string {
+	ident := errIdent(w.Schema, w.Table)
+
+	return fmt.Sprintf("%s:%d:%d cannot %s %s %q, it is not updatable (pos %d)",
+		w.Fn.Pos.Filename,
+		w.Fn.Pos.Line,
+		w.Fn.Pos.Column,
+		w.Stmt,
+		w.Kind,
+		ident,
+		w.Location,
+	)
+}
+
+func (w WriteErr) call() Call {
+	return w.Fn
+}
+
+// addRelations adds the relations created by migrations that the database
+// info doesn't have to it. It returns what's known about each of them
+// keyed by schema.name.
+//
+// Views get their columns from the output of their queries which are
+// checked against everything created before them, the problems found are
+// returned as warnings and those views aren't added.
+func addRelations(info *drivers.DBInfo, searchPath []string, m *migrationInfo) (map[string]relation, []error) {
+	var warns []error
+	relations := make(map[string]relation)
+	state := &State{DBInfo: info, SearchPath: searchPath, Functions: m.functions}
+
+	for _, r := range m.relations {
+		scope := NewScope(info)
+		scope.searchPath = searchPath
+		schema := r.schema
+		if len(schema) == 0 {
+			schema = scope.schemas()[0]
+		}
+
+		rel := relation{kind: r.kind}
+		columns := r.columns
+		if sel, ok := r.query.(pgnodes.SelectStmt); ok {
+			refs, errs := checkSelect(state, Call{Pos: r.pos}, scope, sel)
+			if len(errs) != 0 {
+				// Columns from a query that didn't check can't be
+				// trusted
+				warns = append(warns, errs...)
+				continue
+			}
+			if len(r.aliases.Items) != 0 {
+				refs = renameOutputCols(refs, r.aliases)
+			}
+			columns = outputColsToPseudoTable(r.name, refs).Columns
+			rel.updatable = r.kind == relView && viewUpdatable(scope, relations, sel)
+		}
+		columns, pkey := renameColumns(columns, r.pkey, r.renames)
+
+		relations[schema+"."+r.name] = rel
+
+		exists := false
+		for i := range info.Tables {
+			if t := &info.Tables[i]; t.Name == r.name && scope.schemaOf(t) == schema {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+
+		info.Tables = append(info.Tables, drivers.Table{
+			SchemaName: schema,
+			Name:       r.name,
+			Columns:    columns,
+			PKey:       pkey,
+		})
+	}
+
+	for key, stmts := range m.insteadOf {
+		if key[0] == '.' {
+			key = NewScope(info).schemas()[0] + key
+		}
+		rel := relations[key]
+		rel.writable = append(rel.writable, stmts...)
+		relations[key] = rel
+	}
+
+	return relations, warns
+}
+
+// renameColumns applies column renames to copies of the columns and
+// primary key of a relation.
+func renameColumns(columns []drivers.Column, pkey *drivers.PrimaryKey, renames [][2]string) ([]drivers.Column, *drivers.PrimaryKey) {
+	if len(renames) == 0 {
+		return columns, pkey
+	}
+
+	columns = append([]drivers.Column(nil), columns...)
+	if pkey != nil {
+		pkey = &drivers.PrimaryKey{Name: pkey.Name, Columns: append([]string(nil), pkey.Columns...)}
+	}
+
+	for _, rename := range renames {
+		for i := range columns {
+			if columns[i].Name == rename[0] {
+				columns[i].Name = rename[1]
+			}
+		}
+		if pkey == nil {
+			continue
+		}
+		for i, c := range pkey.Columns {
+			if c == rename[0] {
+				pkey.Columns[i] = rename[1]
+			}
+		}
+	}
+
+	return columns, pkey
+}
+
+// viewUpdatable checks if postgres can write through a view by itself, it
+// has to select from a single table or updatable view without anything that
+// combines rows.
+func viewUpdatable(scope *Scope, relations map[string]relation, sel pgnodes.SelectStmt) bool {
+	if sel.Op != pgnodes.SETOP_NONE || sel.WithClause != nil ||
+		len(sel.DistinctClause.Items) != 0 || len(sel.GroupClause.Items) != 0 ||
+		sel.HavingClause != nil || sel.LimitCount != nil || sel.LimitOffset != nil ||
+		len(sel.ValuesLists) != 0 || len(sel.FromClause.Items) != 1 {
+		return false
+	}
+
+	rv, ok := sel.FromClause.Items[0].(pgnodes.RangeVar)
+	if !ok {
+		return false
+	}
+
+	var schema string
+	if rv.Schemaname != nil {
+		schema = *rv.Schemaname
+	}
+	if !scope.pushTable(schema, *rv.Relname, "") {
+		return false
+	}
+	t := scope.tables[len(scope.tables)-1]
+	scope.popTable()
+
+	if rel, ok := relations[scope.schemaOf(t)+"."+t.Name]; ok {
+		switch rel.kind {
+		case relView:
+			if !rel.updatable {
+				return false
+			}
+		case relMatView:
+			return false
+		}
+	}
+
+	// Aggregates and window functions make it a grouped view
+	for _, target := range sel.TargetList.Items {
+		if fc, ok := target.(pgnodes.ResTarget).Val.(pgnodes.FuncCall); ok {
+			if fc.Over != nil || pgFunctions[funcCallName(fc)].aggregate {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// checkWritable checks that the table last pushed to the scope can be
+// written to by the statement.
+func checkWritable(state *State, fn Call, scope *Scope, stmt string, location int) []error {
+	t := scope.tables[len(scope.tables)-1]
+	schema := scope.schemaOf(t)
+	rel, ok := state.Relations[schema+"."+t.Name]
+	if !ok {
+		return nil
+	}
+
+	switch rel.kind {
+	case relView:
+		if rel.updatable {
+			return nil
+		}
+		for _, w := range rel.writable {
+			if w == stmt {
+				return nil
+			}
+		}
+	case relMatView:
+	default:
+		return nil
+	}
+
+	return []error{WriteErr{
+		Schema:   schema,
+		Table:    t.Name,
+		Kind:     relKindNames[rel.kind],
+		Stmt:     stmt,
+		Location: location,
+		Fn:       fn,
+	}}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// SetOpErr occurs when the two sides of a UNION, INTERSECT or EXCEPT don't
+// produce the same number of columns or have a column whose types can't be
+// matched.
+type SetOpErr struct {
+	// Op is UNION/INTERSECT/EXCEPT
+	Op string
+
+	LeftCount  int
+	RightCount int
+
+	// Column is the position of the column whose types differ, it's 0 when
+	// the number of columns differ
+	Column    int
+	LeftType  string
+	RightType string
+
+	Location int
+
+	Fn Call
+}
+
+func (s SetOpErr) Error() string {
+	if s.Column == 0 {
+		return fmt.Sprintf("%s:%d:%d each %s query must have the same number of columns, left has %d but right has %d (pos %d)",
+			s.Fn.Pos.Filename,
+			s.Fn.Pos.Line,
+			s.Fn.Pos.Column,
+			s.Op,
+			s.LeftCount,
+			s.RightCount,
+			s.Location,
+		)
+	}
+
+	return fmt.Sprintf("%s:%d:%d %s types %q and %q cannot be matched for column %d (pos %d)",
+		s.Fn.Pos.Filename,
+		s.Fn.Pos.Line,
+		s.Fn.Pos.Column,
+		s.Op,
+		s.LeftType,
+		s.RightType,
+		s.Column,
+		s.Location,
+	)
+}
+
+func (s SetOpErr) call() Call {
+	return s.Fn
+}
+
+// setOpNames are how set operations are written
+var setOpNames = map[pgnodes.SetOperation]string{
+	pgnodes.SETOP_UNION:     "UNION",
+	pgnodes.SETOP_INTERSECT: "INTERSECT",
+	pgnodes.SETOP_EXCEPT:    "EXCEPT",
+}
+
+// checkSetOp checks that both sides of a set operation agree on their
+// columns and returns the combined output. The names and types come from
+// the left side, a column is nullable if it is on either side.
+func checkSetOp(fn Call, sel pgnodes.SelectStmt, left, right []outputColRef) ([]outputColRef, []error) {
+	op := setOpNames[sel.Op]
+	rightExprs, rightLocations := leafTargets(*sel.Rarg)
+	leftExprs, _ := leafTargets(*sel.Larg)
+
+	if len(left) != len(right) {
+		location := -1
+		if len(rightLocations) != 0 {
+			location = rightLocations[0]
+		}
+
+		return left, []error{SetOpErr{
+			Op:         op,
+			LeftCount:  len(left),
+			RightCount: len(right),
+			Location:   location,
+			Fn:         fn,
+		}}
+	}
+
+	var errs []error
+	output := make([]outputColRef, len(left))
+	for i, l := range left {
+		output[i] = l
+
+		r := right[i]
+		if l.col == nil || unknownLiteral(leftExprs, i) {
+			output[i].col = r.col
+			continue
+		}
+		if r.col == nil || unknownLiteral(rightExprs, i) {
+			continue
+		}
+
+		if !dbTypesCompatible(l.col.DBType, r.col.DBType) {
+			location := -1
+			if i < len(rightLocations) {
+				location = rightLocations[i]
+			}
+
+			errs = append(errs, SetOpErr{
+				Op:         op,
+				LeftCount:  len(left),
+				RightCount: len(right),
+				Column:     i + 1,
+				LeftType:   l.col.DBType,
+				RightType:  r.col.DBType,
+				Location:   location,
+				Fn:         fn,
+			})
+			continue
+		}
+
+		if r.col.Nullable && !l.col.Nullable {
+			col := *l.col
+			col.Nullable = true
+			col.Type = translateDBType(col.DBType, true)
+			output[i].col = &col
+		}
+	}
+
+	return output, errs
+}
+
+// leafTargets returns the expressions and locations of the columns of the
+// leftmost select in a set operation, which is where postgres reports
+// problems. Nothing is returned when a * makes the positions unknowable.
+func leafTargets(sel pgnodes.SelectStmt) ([]pgnodes.Node, []int) {
+	for sel.Larg != nil {
+		sel = *sel.Larg
+	}
+
+	if len(sel.ValuesLists) != 0 {
+		return sel.ValuesLists[0], nil
+	}
+
+	exprs := make([]pgnodes.Node, 0, len(sel.TargetList.Items))
+	locations := make([]int, 0, len(sel.TargetList.Items))
+	for _, item := range sel.TargetList.Items {
+		target := item.(pgnodes.ResTarget)
+		if colRef, ok := target.Val.(pgnodes.ColumnRef); ok {
+			if _, ok := colRef.Fields.Items[len(colRef.Fields.Items)-1].(pgnodes.A_Star); ok {
+				return nil, nil
+			}
+		}
+
+		exprs = append(exprs, target.Val)
+		locations = append(locations, target.Location)
+	}
+
+	return exprs, locations
+}
+
+// unknownLiteral checks if the i'th expression is a string constant, its
+// type isn't known until it's matched with the other side.
+func unknownLiteral(exprs []pgnodes.Node, i int) bool {
+	if i >= len(exprs) {
+		return false
+	}
+
+	c, ok := exprs[i].(pgnodes.A_Const)
+	if !ok {
+		return false
+	}
+	_, ok = c.Val.(pgnodes.String)
+	return ok
+}
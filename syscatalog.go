@@ -0,0 +1,304 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+)
+
+// systemColumns are the columns every table has that aren't part of its
+// definition, they're not included when a * is expanded.
+var systemColumns = []drivers.Column{
+	*pseudoColumn("tableoid", "oid", false),
+	*pseudoColumn("cmax", "cid", false),
+	*pseudoColumn("xmax", "xid", false),
+	*pseudoColumn("cmin", "cid", false),
+	*pseudoColumn("xmin", "xid", false),
+	*pseudoColumn("ctid", "tid", false),
+}
+
+// systemColumn finds a system column by name
+func systemColumn(name string) *drivers.Column {
+	for i, c := range systemColumns {
+		if c.Name == name {
+			return &systemColumns[i]
+		}
+	}
+
+	return nil
+}
+
+// catalogTable creates a table from columns written as "name type"
+func catalogTable(schema, name string, nullable bool, cols ...string) drivers.Table {
+	t := drivers.Table{SchemaName: schema, Name: name}
+	for _, c := range cols {
+		space := strings.IndexByte(c, ' ')
+		t.Columns = append(t.Columns, *pseudoColumn(c[:space], c[space+1:], nullable))
+	}
+
+	return t
+}
+
+// systemCatalog is the commonly used tables and views of pg_catalog and
+// information_schema. The catalog tables are never null, the information
+// schema views use nullable domains for everything and are given the types
+// the domains are based on.
+var systemCatalog = []drivers.Table{
+	catalogTable("pg_catalog", "pg_namespace", false,
+		"oid oid", "nspname name", "nspowner oid", "nspacl ARRAYaclitem"),
+	catalogTable("pg_catalog", "pg_class", false,
+		"oid oid", "relname name", "relnamespace oid", "reltype oid", "relowner oid",
+		"relam oid", "relfilenode oid", "reltablespace oid", "relpages integer",
+		"reltuples real", "relallvisible integer", "reltoastrelid oid", "relhasindex boolean",
+		"relisshared boolean", `relpersistence "char"`, `relkind "char"`, "relnatts smallint",
+		"relchecks smallint", "relhasrules boolean", "relhastriggers boolean",
+		"relhassubclass boolean", "relrowsecurity boolean", "relforcerowsecurity boolean",
+		"relispopulated boolean", `relreplident "char"`, "relispartition boolean",
+		"relfrozenxid xid", "relminmxid xid", "relacl ARRAYaclitem", "reloptions ARRAYtext"),
+	catalogTable("pg_catalog", "pg_attribute", false,
+		"attrelid oid", "attname name", "atttypid oid", "attstattarget integer",
+		"attlen smallint", "attnum smallint", "attndims integer", "atttypmod integer",
+		"attbyval boolean", `attstorage "char"`, `attalign "char"`, "attnotnull boolean",
+		"atthasdef boolean", `attidentity "char"`, "attisdropped boolean", "attislocal boolean",
+		"attinhcount integer", "attcollation oid", "attacl ARRAYaclitem", "attoptions ARRAYtext"),
+	catalogTable("pg_catalog", "pg_attrdef", false,
+		"oid oid", "adrelid oid", "adnum smallint", "adbin pg_node_tree", "adsrc text"),
+	catalogTable("pg_catalog", "pg_type", false,
+		"oid oid", "typname name", "typnamespace oid", "typowner oid", "typlen smallint",
+		"typbyval boolean", `typtype "char"`, `typcategory "char"`, "typispreferred boolean",
+		"typisdefined boolean", `typdelim "char"`, "typrelid oid", "typelem oid", "typarray oid",
+		"typinput regproc", "typoutput regproc", "typnotnull boolean", "typbasetype oid",
+		"typtypmod integer", "typndims integer", "typcollation oid", "typdefault text"),
+	catalogTable("pg_catalog", "pg_enum", false,
+		"oid oid", "enumtypid oid", "enumsortorder real", "enumlabel name"),
+	catalogTable("pg_catalog", "pg_index", false,
+		"indexrelid oid", "indrelid oid", "indnatts smallint", "indisunique boolean",
+		"indisprimary boolean", "indisexclusion boolean", "indimmediate boolean",
+		"indisclustered boolean", "indisvalid boolean", "indisready boolean",
+		"indislive boolean", "indisreplident boolean", "indkey int2vector", "indclass oidvector"),
+	catalogTable("pg_catalog", "pg_constraint", false,
+		"oid oid", "conname name", "connamespace oid", `contype "char"`, "condeferrable boolean",
+		"condeferred boolean", "convalidated boolean", "conrelid oid", "contypid oid",
+		"conindid oid", "confrelid oid", `confupdtype "char"`, `confdeltype "char"`,
+		`confmatchtype "char"`, "conislocal boolean", "coninhcount integer",
+		"connoinherit boolean", "conkey ARRAYsmallint", "confkey ARRAYsmallint", "consrc text"),
+	catalogTable("pg_catalog", "pg_proc", false,
+		"oid oid", "proname name", "pronamespace oid", "proowner oid", "prolang oid",
+		"procost real", "prorows real", "provariadic oid", "proisagg boolean",
+		"proiswindow boolean", "prosecdef boolean", "proleakproof boolean",
+		"proisstrict boolean", "proretset boolean", `provolatile "char"`, `proparallel "char"`,
+		"pronargs smallint", "pronargdefaults smallint", "prorettype oid",
+		"proargtypes oidvector", "prosrc text"),
+	catalogTable("pg_catalog", "pg_trigger", false,
+		"oid oid", "tgrelid oid", "tgname name", "tgfoid oid", "tgtype smallint",
+		`tgenabled "char"`, "tgisinternal boolean", "tgconstrrelid oid", "tgconstrindid oid",
+		"tgconstraint oid", "tgdeferrable boolean", "tginitdeferred boolean", "tgnargs smallint"),
+	catalogTable("pg_catalog", "pg_inherits", false,
+		"inhrelid oid", "inhparent oid", "inhseqno integer"),
+	catalogTable("pg_catalog", "pg_depend", false,
+		"classid oid", "objid oid", "objsubid integer", "refclassid oid", "refobjid oid",
+		"refobjsubid integer", `deptype "char"`),
+	catalogTable("pg_catalog", "pg_description", false,
+		"objoid oid", "classoid oid", "objsubid integer", "description text"),
+	catalogTable("pg_catalog", "pg_sequence", false,
+		"seqrelid oid", "seqtypid oid", "seqstart bigint", "seqincrement bigint",
+		"seqmax bigint", "seqmin bigint", "seqcache bigint", "seqcycle boolean"),
+	catalogTable("pg_catalog", "pg_am", false,
+		"oid oid", "amname name", "amhandler regproc", `amtype "char"`),
+	catalogTable("pg_catalog", "pg_extension", false,
+		"oid oid", "extname name", "extowner oid", "extnamespace oid",
+		"extrelocatable boolean", "extversion text"),
+	catalogTable("pg_catalog", "pg_database", false,
+		"oid oid", "datname name", "datdba oid", "encoding integer", "datcollate name",
+		"datctype name", "datistemplate boolean", "datallowconn boolean",
+		"datconnlimit integer", "datlastsysoid oid", "datfrozenxid xid", "datminmxid xid",
+		"dattablespace oid", "datacl ARRAYaclitem"),
+	catalogTable("pg_catalog", "pg_tablespace", false,
+		"oid oid", "spcname name", "spcowner oid", "spcacl ARRAYaclitem", "spcoptions ARRAYtext"),
+	catalogTable("pg_catalog", "pg_roles", false,
+		"rolname name", "rolsuper boolean", "rolinherit boolean", "rolcreaterole boolean",
+		"rolcreatedb boolean", "rolcanlogin boolean", "rolreplication boolean",
+		"rolconnlimit integer", "rolpassword text", "rolvaliduntil timestamp with time zone",
+		"rolbypassrls boolean", "rolconfig ARRAYtext", "oid oid"),
+	catalogTable("pg_catalog", "pg_user", false,
+		"usename name", "usesysid oid", "usecreatedb boolean", "usesuper boolean",
+		"userepl boolean", "usebypassrls boolean", "passwd text",
+		"valuntil timestamp with time zone", "useconfig ARRAYtext"),
+
+	// Views
+	catalogTable("pg_catalog", "pg_tables", false,
+		"schemaname name", "tablename name", "tableowner name", "tablespace name",
+		"hasindexes boolean", "hasrules boolean", "hastriggers boolean", "rowsecurity boolean"),
+	catalogTable("pg_catalog", "pg_views", false,
+		"schemaname name", "viewname name", "viewowner name", "definition text"),
+	catalogTable("pg_catalog", "pg_matviews", false,
+		"schemaname name", "matviewname name", "matviewowner name", "tablespace name",
+		"hasindexes boolean", "ispopulated boolean", "definition text"),
+	catalogTable("pg_catalog", "pg_indexes", false,
+		"schemaname name", "tablename name", "indexname name", "tablespace name",
+		"indexdef text"),
+	catalogTable("pg_catalog", "pg_sequences", false,
+		"schemaname name", "sequencename name", "sequenceowner name", "data_type regtype",
+		"start_value bigint", "min_value bigint", "max_value bigint", "increment_by bigint",
+		"cycle boolean", "cache_size bigint", "last_value bigint"),
+	catalogTable("pg_catalog", "pg_settings", false,
+		"name text", "setting text", "unit text", "category text", "short_desc text",
+		"extra_desc text", "context text", "vartype text", "source text", "min_val text",
+		"max_val text", "enumvals ARRAYtext", "boot_val text", "reset_val text",
+		"sourcefile text", "sourceline integer", "pending_restart boolean"),
+	catalogTable("pg_catalog", "pg_locks", false,
+		"locktype text", "database oid", "relation oid", "page integer", "tuple smallint",
+		"virtualxid text", "transactionid xid", "classid oid", "objid oid",
+		"objsubid smallint", "virtualtransaction text", "pid integer", "mode text",
+		"granted boolean", "fastpath boolean"),
+	catalogTable("pg_catalog", "pg_prepared_xacts", false,
+		"transaction xid", "gid text", "prepared timestamp with time zone", "owner name",
+		"database name"),
+	catalogTable("pg_catalog", "pg_stat_activity", true,
+		"datid oid", "datname name", "pid integer", "usesysid oid", "usename name",
+		"application_name text", "client_addr inet", "client_hostname text",
+		"client_port integer", "backend_start timestamp with time zone",
+		"xact_start timestamp with time zone", "query_start timestamp with time zone",
+		"state_change timestamp with time zone", "wait_event_type text", "wait_event text",
+		"state text", "backend_xid xid", "backend_xmin xid", "query text", "backend_type text"),
+	catalogTable("pg_catalog", "pg_stat_database", true,
+		"datid oid", "datname name", "numbackends integer", "xact_commit bigint",
+		"xact_rollback bigint", "blks_read bigint", "blks_hit bigint", "tup_returned bigint",
+		"tup_fetched bigint", "tup_inserted bigint", "tup_updated bigint",
+		"tup_deleted bigint", "conflicts bigint", "temp_files bigint", "temp_bytes bigint",
+		"deadlocks bigint", "stats_reset timestamp with time zone"),
+	statTablesView("pg_stat_all_tables"),
+	statTablesView("pg_stat_user_tables"),
+	statTablesView("pg_stat_sys_tables"),
+	statIndexesView("pg_stat_all_indexes"),
+	statIndexesView("pg_stat_user_indexes"),
+	statIndexesView("pg_stat_sys_indexes"),
+
+	catalogTable("information_schema", "schemata", true,
+		"catalog_name name", "schema_name name", "schema_owner name",
+		"sql_path character varying"),
+	catalogTable("information_schema", "tables", true,
+		"table_catalog name", "table_schema name", "table_name name",
+		"table_type character varying", "self_referencing_column_name name",
+		"reference_generation character varying", "user_defined_type_catalog name",
+		"user_defined_type_schema name", "user_defined_type_name name",
+		"is_insertable_into character varying", "is_typed character varying",
+		"commit_action character varying"),
+	catalogTable("information_schema", "columns", true,
+		"table_catalog name", "table_schema name", "table_name name", "column_name name",
+		"ordinal_position integer", "column_default character varying",
+		"is_nullable character varying", "data_type character varying",
+		"character_maximum_length integer", "character_octet_length integer",
+		"numeric_precision integer", "numeric_precision_radix integer",
+		"numeric_scale integer", "datetime_precision integer",
+		"interval_type character varying", "interval_precision integer",
+		"character_set_catalog name", "character_set_schema name",
+		"character_set_name name", "collation_catalog name", "collation_schema name",
+		"collation_name name", "domain_catalog name", "domain_schema name",
+		"domain_name name", "udt_catalog name", "udt_schema name", "udt_name name",
+		"scope_catalog name", "scope_schema name", "scope_name name",
+		"maximum_cardinality integer", "dtd_identifier name",
+		"is_self_referencing character varying", "is_identity character varying",
+		"identity_generation character varying", "identity_start character varying",
+		"identity_increment character varying", "identity_maximum character varying",
+		"identity_minimum character varying", "identity_cycle character varying",
+		"is_generated character varying", "generation_expression character varying",
+		"is_updatable character varying"),
+	catalogTable("information_schema", "views", true,
+		"table_catalog name", "table_schema name", "table_name name",
+		"view_definition character varying", "check_option character varying",
+		"is_updatable character varying", "is_insertable_into character varying",
+		"is_trigger_updatable character varying", "is_trigger_deletable character varying",
+		"is_trigger_insertable_into character varying"),
+	catalogTable("information_schema", "table_constraints", true,
+		"constraint_catalog name", "constraint_schema name", "constraint_name name",
+		"table_catalog name", "table_schema name", "table_name name",
+		"constraint_type character varying", "is_deferrable character varying",
+		"initially_deferred character varying"),
+	catalogTable("information_schema", "key_column_usage", true,
+		"constraint_catalog name", "constraint_schema name", "constraint_name name",
+		"table_catalog name", "table_schema name", "table_name name", "column_name name",
+		"ordinal_position integer", "position_in_unique_constraint integer"),
+	catalogTable("information_schema", "constraint_column_usage", true,
+		"table_catalog name", "table_schema name", "table_name name", "column_name name",
+		"constraint_catalog name", "constraint_schema name", "constraint_name name"),
+	catalogTable("information_schema", "referential_constraints", true,
+		"constraint_catalog name", "constraint_schema name", "constraint_name name",
+		"unique_constraint_catalog name", "unique_constraint_schema name",
+		"unique_constraint_name name", "match_option character varying",
+		"update_rule character varying", "delete_rule character varying"),
+	catalogTable("information_schema", "check_constraints", true,
+		"constraint_catalog name", "constraint_schema name", "constraint_name name",
+		"check_clause character varying"),
+	catalogTable("information_schema", "routines", true,
+		"specific_catalog name", "specific_schema name", "specific_name name",
+		"routine_catalog name", "routine_schema name", "routine_name name",
+		"routine_type character varying", "data_type character varying",
+		"type_udt_name name", "routine_body character varying",
+		"routine_definition character varying", "external_language character varying",
+		"is_deterministic character varying", "security_type character varying"),
+	catalogTable("information_schema", "parameters", true,
+		"specific_catalog name", "specific_schema name", "specific_name name",
+		"ordinal_position integer", "parameter_mode character varying",
+		"is_result character varying", "as_locator character varying",
+		"parameter_name name", "data_type character varying", "udt_name name",
+		"parameter_default character varying"),
+	catalogTable("information_schema", "sequences", true,
+		"sequence_catalog name", "sequence_schema name", "sequence_name name",
+		"data_type character varying", "numeric_precision integer",
+		"numeric_precision_radix integer", "numeric_scale integer",
+		"start_value character varying", "minimum_value character varying",
+		"maximum_value character varying", "increment character varying",
+		"cycle_option character varying"),
+	catalogTable("information_schema", "triggers", true,
+		"trigger_catalog name", "trigger_schema name", "trigger_name name",
+		"event_manipulation character varying", "event_object_catalog name",
+		"event_object_schema name", "event_object_table name", "action_order integer",
+		"action_condition character varying", "action_statement character varying",
+		"action_orientation character varying", "action_timing character varying",
+		"created timestamp with time zone"),
+	catalogTable("information_schema", "enabled_roles", true,
+		"role_name name"),
+}
+
+// statTablesView creates one of the pg_stat_*_tables views
+func statTablesView(name string) drivers.Table {
+	return catalogTable("pg_catalog", name, true,
+		"relid oid", "schemaname name", "relname name", "seq_scan bigint",
+		"seq_tup_read bigint", "idx_scan bigint", "idx_tup_fetch bigint", "n_tup_ins bigint",
+		"n_tup_upd bigint", "n_tup_del bigint", "n_tup_hot_upd bigint", "n_live_tup bigint",
+		"n_dead_tup bigint", "n_mod_since_analyze bigint",
+		"last_vacuum timestamp with time zone", "last_autovacuum timestamp with time zone",
+		"last_analyze timestamp with time zone", "last_autoanalyze timestamp with time zone",
+		"vacuum_count bigint", "autovacuum_count bigint", "analyze_count bigint",
+		"autoanalyze_count bigint")
+}
+
+// statIndexesView creates one of the pg_stat_*_indexes views
+func statIndexesView(name string) drivers.Table {
+	return catalogTable("pg_catalog", name, true,
+		"relid oid", "indexrelid oid", "schemaname name", "relname name",
+		"indexrelname name", "idx_scan bigint", "idx_tup_read bigint", "idx_tup_fetch bigint")
+}
+
+// systemTable finds a table of the system catalog
+func systemTable(schema, name string) *drivers.Table {
+	for i, t := range systemCatalog {
+		if t.SchemaName == schema && t.Name == name {
+			return &systemCatalog[i]
+		}
+	}
+
+	return nil
+}
+
+// isSystemTable checks if a table is one of the system catalog's
+func isSystemTable(t *drivers.Table) bool {
+	for i := range systemCatalog {
+		if &systemCatalog[i] == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/volatiletech/sqlboiler/v4/drivers"
+	"github.com/volatiletech/sqlboiler/v4/importers"
+)
+
+// replaceTypes gives the columns that sqlboiler replaces the types of that
+// type like sqlboiler does, in the order the replacements are configured.
+// It returns the Go types each replaced column (schema.table.column) was
+// given.
+func replaceTypes(cfg boilcheckConfig, info *drivers.DBInfo) map[string][]string {
+	replaced := make(map[string][]string)
+	for _, r := range cfg.Types {
+		if len(r.Replace.Type) == 0 {
+			continue
+		}
+		goType := qualifyGoType(r.Replace.Type, r.Imports)
+
+		for i := range info.Tables {
+			t := &info.Tables[i]
+			for j := range t.Columns {
+				c := &t.Columns[j]
+				if !matchColumn(*c, r.Match) {
+					continue
+				}
+
+				c.Type = r.Replace.Type
+				key := tableSchema(info, t) + "." + t.Name + "." + c.Name
+				replaced[key] = append(replaced[key], goType)
+			}
+		}
+	}
+
+	return replaced
+}
+
+// typeAliases builds the Go types that are acceptable for db types and
+// columns on top of the ones we understand ourselves, from the config and
+// the types replaceTypes gave columns.
+func typeAliases(cfg boilcheckConfig, replaced map[string][]string) map[string][]string {
+	aliases := make(map[string][]string)
+	for key, goTypes := range cfg.Boilcheck.Types {
+		aliases[key] = append(aliases[key], goTypes...)
+	}
+	for key, goTypes := range replaced {
+		aliases[key] = append(aliases[key], goTypes...)
+	}
+
+	return aliases
+}
+
+// matchColumn checks a column against an sqlboiler type replacement's match,
+// every string field set in m must be equal and at least one must be set.
+// Like sqlboiler the boolean fields are always compared.
+func matchColumn(c, m drivers.Column) bool {
+	matched := false
+	matches := func(matcher, value string) bool {
+		if len(matcher) == 0 {
+			return true
+		}
+		matched = true
+		return matcher == value
+	}
+
+	if !matches(m.Name, c.Name) ||
+		!matches(m.Type, c.Type) ||
+		!matches(m.DBType, c.DBType) ||
+		!matches(m.UDTName, c.UDTName) ||
+		!matches(m.FullDBType, c.FullDBType) {
+		return false
+	}
+	if m.ArrType != nil && (c.ArrType == nil || !matches(*m.ArrType, *c.ArrType)) {
+		return false
+	}
+	if m.DomainName != nil && (c.DomainName == nil || !matches(*m.DomainName, *c.DomainName)) {
+		return false
+	}
+
+	return matched && m.AutoGenerated == c.AutoGenerated && m.Nullable == c.Nullable
+}
+
+// qualifyGoType turns a type as it's written in Go source (money.Cents) into
+// the name go/types gives it (github.com/me/money.Cents) using the imports
+// that go with it.
+func qualifyGoType(goType string, imports importers.Set) string {
+	dot := strings.LastIndexByte(goType, '.')
+	if dot < 0 {
+		return goType
+	}
+	pkgName, name := goType[:dot], goType[dot+1:]
+
+	allImps := append(append([]string(nil), imports.Standard...), imports.ThirdParty...)
+	for _, imp := range allImps {
+		// Imports can be named: money "github.com/me/money-go"
+		var alias string
+		if fields := strings.Fields(imp); len(fields) == 2 {
+			alias, imp = fields[0], fields[1]
+		}
+
+		imp = strings.Trim(imp, `"`)
+		if alias == pkgName || (len(alias) == 0 && path.Base(unversionedPath(imp)) == pkgName) {
+			return imp + "." + name
+		}
+	}
+
+	return goType
+}
+
+// typeAliased checks if the Go type was declared acceptable for the column
+// (schema.table.column, or table.column in any schema) or its db type.
+// Pointers to acceptable types are also acceptable since they're only a way
+// to pass NULL.
+func typeAliased(aliases map[string][]string, column, dbType, goType string) bool {
+	goType = strings.TrimLeft(goType, "*")
+
+	var tableColumn string
+	if i := strings.IndexByte(column, '.'); i >= 0 {
+		tableColumn = column[i+1:]
+	}
+
+	for _, key := range []string{column, tableColumn, dbType} {
+		if len(key) == 0 {
+			continue
+		}
+		for _, alias := range aliases[key] {
+			if alias == goType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
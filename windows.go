@@ -0,0 +1,88 @@
+package main
+
+import (
+	pgnodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// checkWindowCall checks a function called with an OVER clause is somewhere
+// window functions are allowed and that the window it names exists.
+func checkWindowCall(fn Call, scope *Scope, fc pgnodes.FuncCall) (errs []error) {
+	name := funcCallName(fc)
+
+	// Wherever aggregates aren't allowed window functions aren't either
+	clause := scope.windowClause
+	if len(clause) == 0 {
+		clause = scope.aggClause
+	}
+	if len(clause) == 0 && scope.inAggregate {
+		clause = "aggregate function arguments"
+	}
+	if len(clause) != 0 {
+		errs = append(errs, FuncErr{
+			Kind:     FuncWindow,
+			Name:     name,
+			Location: fc.Location,
+			Clause:   clause,
+			Fn:       fn,
+		})
+	}
+
+	// OVER w names a window, OVER (w ORDER BY ...) builds on one
+	over := fc.Over
+	ref := over.Refname
+	if over.Name != nil {
+		ref = over.Name
+	}
+	if ref != nil && !scope.hasWindow(*ref) {
+		errs = append(errs, FuncErr{
+			Kind:     FuncUnknownWindow,
+			Name:     *ref,
+			Location: over.Location,
+			Fn:       fn,
+		})
+	}
+
+	return errs
+}
+
+// defineWindows makes the windows of a select's WINDOW clause available to
+// its window functions. A window can only build on the ones before it.
+func defineWindows(fn Call, scope *Scope, sel pgnodes.SelectStmt) (errs []error) {
+	for _, item := range sel.WindowClause.Items {
+		w, ok := item.(pgnodes.WindowDef)
+		if !ok || w.Name == nil {
+			continue
+		}
+
+		if w.Refname != nil && !scope.hasWindow(*w.Refname) {
+			errs = append(errs, FuncErr{
+				Kind:     FuncUnknownWindow,
+				Name:     *w.Refname,
+				Location: w.Location,
+				Fn:       fn,
+			})
+		}
+		scope.windows = append(scope.windows, *w.Name)
+	}
+
+	return errs
+}
+
+// hasWindow checks if the select being checked defines a window
+func (s *Scope) hasWindow(name string) bool {
+	for _, w := range s.windows {
+		if w == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// noWindows disallows window functions in the clause being checked on top
+// of wherever aggregates aren't allowed, an empty clause allows them.
+func (s *Scope) noWindows(clause string) (restore func()) {
+	old := s.windowClause
+	s.windowClause = clause
+	return func() { s.windowClause = old }
+}